@@ -17,9 +17,12 @@ type client struct {
 	readChan chan []byte
 	stopChan chan struct{}
 	stopOnce sync.Once
+	logger   *slog.Logger
+	metrics  *adapterMetrics
 }
 
-func newClient(terminal Terminal, groupStopChan <-chan struct{}, replyFunc func(data []byte)) (*client, error) {
+func newClient(terminal Terminal, groupStopChan <-chan struct{}, replyFunc func(data []byte),
+	logger *slog.Logger, metrics *adapterMetrics) (*client, error) {
 	conn, err := net.Dial("tcp", terminal.TargetAddr)
 	if err != nil {
 		return nil, err
@@ -32,6 +35,8 @@ func newClient(terminal Terminal, groupStopChan <-chan struct{}, replyFunc func(
 		readChan:      make(chan []byte, 10),
 		stopChan:      make(chan struct{}),
 		stopOnce:      sync.Once{},
+		logger:        logger,
+		metrics:       metrics,
 	}
 	go tmp.run()
 	return tmp, nil
@@ -51,7 +56,8 @@ func (c *client) run() {
 		case data, ok := <-c.readChan:
 			if ok {
 				if _, err := c.conn.Write(data); err != nil {
-					slog.Warn("write",
+					c.logger.Warn("write",
+						slog.String("addr", c.terminal.TargetAddr),
 						slog.String("data", fmt.Sprintf("%x", data)),
 						slog.Any("err", err))
 				}
@@ -96,6 +102,7 @@ func (c *client) reader() {
 				for _, msg := range msgs {
 					command := consts.JT808CommandType(msg.JTMessage.Header.ID)
 					if c.terminal.allowReply(command) {
+						c.metrics.onReply(c.terminal.TargetAddr, command)
 						c.replyFunc(msg.originalData)
 					}
 				}
@@ -17,9 +17,12 @@ type group struct {
 	writeMsgChan chan []byte
 	stopChan     chan struct{}
 	stopOnce     sync.Once
+	logger       *slog.Logger
+	metrics      *adapterMetrics
 }
 
-func newGroup(conn *net.TCPConn, timeoutRetry time.Duration, terminals []Terminal) *group {
+func newGroup(conn *net.TCPConn, timeoutRetry time.Duration, logger *slog.Logger,
+	metrics *adapterMetrics, terminals []Terminal) *group {
 	g := &group{
 		conn:         conn,
 		timeoutRetry: timeoutRetry,
@@ -27,12 +30,14 @@ func newGroup(conn *net.TCPConn, timeoutRetry time.Duration, terminals []Termina
 		writeMsgChan: make(chan []byte, 10),
 		stopChan:     make(chan struct{}),
 		stopOnce:     sync.Once{},
+		logger:       logger,
+		metrics:      metrics,
 	}
 	for _, terminal := range terminals {
-		if c, err := newClient(terminal, g.stopChan, g.sendData); err == nil {
+		if c, err := newClient(terminal, g.stopChan, g.sendData, g.logger, g.metrics); err == nil {
 			g.clients.Store(&terminal, c)
 		} else {
-			slog.Error("init client error",
+			g.logger.Error("init client error",
 				slog.String("addr", terminal.TargetAddr),
 				slog.Any("err", err))
 			g.clients.Store(&terminal, nil)
@@ -42,6 +47,7 @@ func newGroup(conn *net.TCPConn, timeoutRetry time.Duration, terminals []Termina
 }
 
 func (g *group) run() {
+	g.metrics.onGroup(1)
 	go g.reader()
 	go g.write()
 }
@@ -58,11 +64,13 @@ func (g *group) reader() {
 	for {
 		if n, err := g.conn.Read(curData); err != nil {
 			if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
-				slog.Debug("client close",
+				g.logger.Debug("client close",
+					slog.Any("remote", g.conn.RemoteAddr()),
 					slog.Any("err", err))
 				return
 			}
-			slog.Error("read data",
+			g.logger.Error("read data",
+				slog.Any("remote", g.conn.RemoteAddr()),
 				slog.Any("err", err))
 			return
 		} else if n > 0 {
@@ -73,8 +81,10 @@ func (g *group) reader() {
 				go func() {
 					defer wg.Done()
 					if v, ok := value.(*client); ok {
-						if ok := v.sendData(curData[:n]); !ok {
-							slog.Warn("send data",
+						sent := v.sendData(curData[:n])
+						g.metrics.onForward(v.terminal.TargetAddr, sent)
+						if !sent {
+							g.logger.Warn("send data",
 								slog.Any("addr", v.terminal.TargetAddr),
 								slog.String("data", fmt.Sprintf("%x", curData[:n])),
 								slog.Any("err", err))
@@ -111,7 +121,7 @@ func (g *group) write() {
 		case data, ok := <-g.writeMsgChan:
 			if ok {
 				if _, err := g.conn.Write(data); err != nil {
-					slog.Warn("write",
+					g.logger.Warn("write",
 						slog.String("data", fmt.Sprintf("%x", data)),
 						slog.Any("err", err))
 				}
@@ -122,6 +132,7 @@ func (g *group) write() {
 
 func (g *group) stop() {
 	g.stopOnce.Do(func() {
+		g.metrics.onGroup(-1)
 		close(g.stopChan)
 		_ = g.conn.Close()
 		g.clients.Clear()
@@ -136,15 +147,17 @@ func (g *group) addClient(terminal Terminal) {
 			return
 		default:
 			// 隔一段时间试一试 重新和808服务建立连接
-			if c, err := newClient(terminal, g.stopChan, g.sendData); err == nil {
+			if c, err := newClient(terminal, g.stopChan, g.sendData, g.logger, g.metrics); err == nil {
 				g.clients.Store(terminal.TargetAddr, c)
-				slog.Info("rejoin",
+				g.metrics.onReconnect(terminal.TargetAddr, true)
+				g.logger.Info("rejoin",
 					slog.String("addr", terminal.TargetAddr))
 				return
 			} else {
-				slog.Warn("new client error",
+				g.logger.Warn("new client error",
 					slog.String("addr", terminal.TargetAddr),
 					slog.Any("err", err))
+				g.metrics.onReconnect(terminal.TargetAddr, false)
 				time.Sleep(g.timeoutRetry)
 			}
 		}
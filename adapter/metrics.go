@@ -0,0 +1,73 @@
+package adapter
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"sync/atomic"
+)
+
+// adapterMetrics 转发服务的指标 没有设置Registry的时候为空 方法都可以在为空的时候调用.
+type adapterMetrics struct {
+	// groups 当前终端的连接数
+	groups atomic.Int64
+	// forwards 终端数据转发给808服务的结果
+	forwards *metrics.CounterVec
+	// replies 808服务回复给终端的数量
+	replies *metrics.CounterVec
+	// reconnects 和808服务重新建立连接的结果
+	reconnects *metrics.CounterVec
+}
+
+func newAdapterMetrics(registry *metrics.Registry) *adapterMetrics {
+	if registry == nil {
+		return nil
+	}
+	m := &adapterMetrics{
+		forwards: registry.NewCounter("jt808_adapter_forwards_total",
+			"terminal data forwarded to target by outcome(ok/fail)", "addr", "outcome"),
+		replies: registry.NewCounter("jt808_adapter_replies_total",
+			"target replies sent back to terminal by command", "addr", "command"),
+		reconnects: registry.NewCounter("jt808_adapter_reconnects_total",
+			"target reconnects by outcome(ok/fail)", "addr", "outcome"),
+	}
+	registry.NewGaugeFunc("jt808_adapter_groups", "adapter terminal connections", func() float64 {
+		return float64(m.groups.Load())
+	})
+	return m
+}
+
+func (a *adapterMetrics) onGroup(delta int64) {
+	if a == nil {
+		return
+	}
+	a.groups.Add(delta)
+}
+
+func (a *adapterMetrics) onForward(addr string, ok bool) {
+	if a == nil {
+		return
+	}
+	a.forwards.Inc(addr, outcomeLabel(ok))
+}
+
+func (a *adapterMetrics) onReply(addr string, command consts.JT808CommandType) {
+	if a == nil {
+		return
+	}
+	a.replies.Inc(addr, fmt.Sprintf("0x%04x", uint16(command)))
+}
+
+func (a *adapterMetrics) onReconnect(addr string, ok bool) {
+	if a == nil {
+		return
+	}
+	a.reconnects.Inc(addr, outcomeLabel(ok))
+}
+
+func outcomeLabel(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "fail"
+}
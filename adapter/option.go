@@ -2,6 +2,8 @@ package adapter
 
 import (
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"log/slog"
 	"time"
 )
 
@@ -19,6 +21,10 @@ type Options struct {
 	TimeoutRetry time.Duration
 	Terminals    []Terminal
 	AllowCommand []consts.JT808CommandType
+	// Logger 日志 默认slog.Default()
+	Logger *slog.Logger
+	// Metrics 指标 Registry实现了http.Handler 输出Prometheus文本格式 默认不统计
+	Metrics *metrics.Registry
 }
 
 func newOptions(opts []Option) *Options {
@@ -44,6 +50,9 @@ func newOptions(opts []Option) *Options {
 	for _, op := range opts {
 		op.F(options)
 	}
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
 	return options
 }
 
@@ -74,3 +83,17 @@ func WithTimeoutRetry(timeout time.Duration) Option {
 		o.TimeoutRetry = timeout
 	}}
 }
+
+// WithLogger 自定义日志 默认slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return Option{F: func(o *Options) {
+		o.Logger = logger
+	}}
+}
+
+// WithMetrics 统计转发 回复和重连的指标 默认不统计.
+func WithMetrics(registry *metrics.Registry) Option {
+	return Option{F: func(o *Options) {
+		o.Metrics = registry
+	}}
+}
@@ -6,13 +6,15 @@ import (
 )
 
 type Adapter struct {
-	opts *Options
+	opts    *Options
+	metrics *adapterMetrics
 }
 
 func New(opts ...Option) *Adapter {
 	options := newOptions(opts)
 	g := &Adapter{
-		opts: options,
+		opts:    options,
+		metrics: newAdapterMetrics(options.Metrics),
 	}
 	return g
 }
@@ -20,14 +22,14 @@ func New(opts ...Option) *Adapter {
 func (a *Adapter) Run() {
 	addr, err := net.ResolveTCPAddr("tcp", a.opts.Addr)
 	if err != nil {
-		slog.Error("resolve tcp addr error",
+		a.opts.Logger.Error("resolve tcp addr error",
 			slog.String("addr", a.opts.Addr),
 			slog.Any("err", err))
 	}
 
 	in, err := net.ListenTCP("tcp", addr)
 	if err != nil {
-		slog.Error("tcp listen fail",
+		a.opts.Logger.Error("tcp listen fail",
 			slog.Any("addr", addr),
 			slog.Any("err", err))
 		return
@@ -36,12 +38,12 @@ func (a *Adapter) Run() {
 	for {
 		c, err := in.AcceptTCP()
 		if err != nil {
-			slog.Warn("accept fail",
+			a.opts.Logger.Warn("accept fail",
 				slog.Any("err", err))
 			continue
 		}
 
-		g := newGroup(c, a.opts.TimeoutRetry, a.createTerminals())
+		g := newGroup(c, a.opts.TimeoutRetry, a.opts.Logger, a.metrics, a.createTerminals())
 		go g.run()
 	}
 }
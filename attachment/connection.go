@@ -5,6 +5,7 @@ import (
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
 	"io"
 	"net"
+	"time"
 )
 
 type connection struct {
@@ -12,6 +13,8 @@ type connection struct {
 	activeSafetyType consts.ActiveSafetyType
 	dataHandleFunc   func() DataHandler
 	fileEventer      FileEventer
+	// metrics 服务的指标 没有设置的为空
+	metrics *attachmentMetrics
 }
 
 func newConnection(conn net.Conn, activeSafetyType consts.ActiveSafetyType,
@@ -46,12 +49,15 @@ func (c *connection) run() {
 			},
 		}
 	)
+	c.metrics.onJoin()
+	joinTime := time.Now()
 	defer func() {
 		progress.ProgressStage = ProgressStageSuccessQuit
 		if progress.ExtensionFields.Err != nil {
 			progress.ProgressStage = ProgressStageFailQuit
 		}
-		c.fileEventer.OnEvent(progress)
+		c.fileEvent(progress)
+		c.metrics.onLeave(progress.ExtensionFields.Err, time.Since(joinTime))
 		clear(curData)
 		_ = c.conn.Close()
 	}()
@@ -64,6 +70,7 @@ func (c *connection) run() {
 			progress.ExtensionFields.Err = err
 			return
 		} else if n > 0 {
+			c.metrics.onRead(n)
 			progress.historyData = append(progress.historyData, curData[:n]...)
 			for err := range progress.iter() {
 				if err == nil {
@@ -75,7 +82,7 @@ func (c *connection) run() {
 							progress.ExtensionFields.Err = errors.Join(err, progress.ExtensionFields.Err)
 						}
 					}
-					c.fileEventer.OnEvent(progress)
+					c.fileEvent(progress)
 				} else if errors.Is(err, ErrInsufficientDataLen) {
 					// 是数据长度不够的错误情况 就结束
 					break
@@ -87,3 +94,8 @@ func (c *connection) run() {
 		}
 	}
 }
+
+func (c *connection) fileEvent(progress *PackageProgress) {
+	c.metrics.onEvent(progress.ProgressStage)
+	c.fileEventer.OnEvent(progress)
+}
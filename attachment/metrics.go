@@ -0,0 +1,110 @@
+package attachment
+
+import (
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// attachmentMetrics 附件服务的指标 没有设置Registry的时候为空 方法都可以在为空的时候调用.
+type attachmentMetrics struct {
+	// connections 当前的连接数
+	connections atomic.Int64
+	// bytes 收到的数据量
+	bytes *metrics.CounterVec
+	// events 文件事件的数量 按照进度状态区分
+	events *metrics.CounterVec
+	// errors 异常退出的数量
+	errors *metrics.CounterVec
+	// duration 连接从建立到退出的耗时
+	duration *metrics.HistogramVec
+}
+
+func newAttachmentMetrics(registry *metrics.Registry) *attachmentMetrics {
+	if registry == nil {
+		return nil
+	}
+	m := &attachmentMetrics{
+		bytes: registry.NewCounter("jt808_attachment_bytes_total",
+			"attachment bytes received"),
+		events: registry.NewCounter("jt808_attachment_events_total",
+			"attachment file events by progress stage", "stage"),
+		errors: registry.NewCounter("jt808_attachment_errors_total",
+			"attachment connections quit with error by kind", "kind"),
+		duration: registry.NewHistogram("jt808_attachment_connection_duration_seconds",
+			"attachment connection duration by outcome(success/fail)",
+			[]float64{1, 5, 10, 30, 60, 120, 300, 600}, "outcome"),
+	}
+	registry.NewGaugeFunc("jt808_attachment_connections", "attachment connections", func() float64 {
+		return float64(m.connections.Load())
+	})
+	return m
+}
+
+func (a *attachmentMetrics) onJoin() {
+	if a == nil {
+		return
+	}
+	a.connections.Add(1)
+}
+
+func (a *attachmentMetrics) onRead(n int) {
+	if a == nil {
+		return
+	}
+	a.bytes.Add(float64(n))
+}
+
+func (a *attachmentMetrics) onEvent(stage ProgressStage) {
+	if a == nil {
+		return
+	}
+	a.events.Inc(stageLabel(stage))
+}
+
+func (a *attachmentMetrics) onLeave(err error, duration time.Duration) {
+	if a == nil {
+		return
+	}
+	a.connections.Add(-1)
+	outcome := "success"
+	if err != nil {
+		outcome = "fail"
+		a.errors.Inc(errorKind(err))
+	}
+	a.duration.Observe(duration.Seconds(), outcome)
+}
+
+func stageLabel(stage ProgressStage) string {
+	switch stage {
+	case ProgressStageInit:
+		return "init"
+	case ProgressStageStart:
+		return "start"
+	case ProgressStageStreamData:
+		return "stream_data"
+	case ProgressStageSupplementary:
+		return "supplementary"
+	case ProgressStageStreamDataComplete:
+		return "stream_data_complete"
+	case ProgressStageComplete:
+		return "complete"
+	case ProgressStageSuccessQuit:
+		return "success_quit"
+	case ProgressStageFailQuit:
+		return "fail_quit"
+	}
+	return "unknown"
+}
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownCommand):
+		return "unknown_command"
+	case errors.Is(err, ErrDataInconsistency):
+		return "data_inconsistency"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,41 @@
+package attachment
+
+import (
+	"bytes"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachmentMetrics(t *testing.T) {
+	var empty *attachmentMetrics
+	empty.onJoin()
+	empty.onEvent(ProgressStageInit)
+	empty.onLeave(nil, time.Second)
+
+	registry := metrics.NewRegistry()
+	m := newAttachmentMetrics(registry)
+	m.onJoin()
+	m.onJoin()
+	m.onRead(100)
+	m.onEvent(ProgressStageInit)
+	m.onEvent(ProgressStageStreamData)
+	m.onEvent(ProgressStageStreamData)
+	m.onLeave(ErrDataInconsistency, 2*time.Second)
+
+	var buf bytes.Buffer
+	_, _ = registry.WriteTo(&buf)
+	for _, want := range []string{
+		"jt808_attachment_bytes_total 100",
+		`jt808_attachment_events_total{stage="init"} 1`,
+		`jt808_attachment_events_total{stage="stream_data"} 2`,
+		`jt808_attachment_errors_total{kind="data_inconsistency"} 1`,
+		`jt808_attachment_connection_duration_seconds_count{outcome="fail"} 1`,
+		"jt808_attachment_connections 1",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics [%s] want [%s]", buf.String(), want)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package attachment
 
-import "github.com/cuteLittleDevil/go-jt808/shared/consts"
+import (
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"log/slog"
+)
 
 type Option struct {
 	F func(o *Options)
@@ -18,6 +22,10 @@ type Options struct {
 	FileEventerFunc  func() FileEventer
 	ActiveSafetyType consts.ActiveSafetyType
 	DataHandleFunc   func() DataHandler
+	// Logger 服务的日志 默认slog.Default()
+	Logger *slog.Logger
+	// Metrics 服务的指标 Registry实现了http.Handler 输出Prometheus文本格式 默认不统计
+	Metrics *metrics.Registry
 }
 
 func newOptions(opts []Option) *Options {
@@ -32,6 +40,9 @@ func newOptions(opts []Option) *Options {
 	for _, op := range opts {
 		op.F(options)
 	}
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
 	return options
 }
 
@@ -69,3 +80,17 @@ func WithDataHandleFunc(handleFunc func() DataHandler) Option {
 		o.DataHandleFunc = handleFunc
 	}}
 }
+
+// WithLogger 服务的日志 默认slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return Option{F: func(o *Options) {
+		o.Logger = logger
+	}}
+}
+
+// WithMetrics 统计连接 数据量 文件事件和异常退出的指标 默认不统计.
+func WithMetrics(registry *metrics.Registry) Option {
+	return Option{F: func(o *Options) {
+		o.Metrics = registry
+	}}
+}
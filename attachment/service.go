@@ -6,13 +6,15 @@ import (
 )
 
 type GoJT808 struct {
-	opts *Options
+	opts    *Options
+	metrics *attachmentMetrics
 }
 
 func New(opts ...Option) *GoJT808 {
 	options := newOptions(opts)
 	g := &GoJT808{
-		opts: options,
+		opts:    options,
+		metrics: newAttachmentMetrics(options.Metrics),
 	}
 	return g
 }
@@ -20,7 +22,7 @@ func New(opts ...Option) *GoJT808 {
 func (g *GoJT808) Run() {
 	in, err := net.Listen(g.opts.Network, g.opts.Addr)
 	if err != nil {
-		slog.Error("listen fail",
+		g.opts.Logger.Error("listen fail",
 			slog.Any("addr", g.opts.Addr),
 			slog.Any("err", err))
 		return
@@ -29,11 +31,12 @@ func (g *GoJT808) Run() {
 	for {
 		c, err := in.Accept()
 		if err != nil {
-			slog.Warn("accept fail",
+			g.opts.Logger.Warn("accept fail",
 				slog.Any("err", err))
 			continue
 		}
 		conn := newConnection(c, g.opts.ActiveSafetyType, g.opts.DataHandleFunc, g.opts.FileEventerFunc())
+		conn.metrics = g.metrics
 		go conn.run()
 	}
 }
@@ -0,0 +1,11 @@
+module replay
+
+go 1.23.2
+
+require github.com/cuteLittleDevil/go-jt808/service v1.8.0
+
+require (
+	github.com/cuteLittleDevil/go-jt808/protocol v1.14.0 // indirect
+	github.com/cuteLittleDevil/go-jt808/shared v1.6.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
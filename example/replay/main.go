@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/service"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+func main() {
+	var (
+		files   = flag.String("files", "capture.log", "抓包文件 多个用逗号分隔 按照从旧到新的顺序 例如capture.log.1,capture.log")
+		addr    = flag.String("addr", "", "回放给808服务的地址 为空的只解析 打印解析后的报文")
+		network = flag.String("network", "tcp", "808服务的协议 支持tcp udp")
+		speed   = flag.Float64("speed", 1, "回放速度的倍数 小于等于0的不等待")
+	)
+	flag.Parse()
+
+	records := make([]service.CaptureRecord, 0)
+	for _, file := range strings.Split(*files, ",") {
+		v, err := service.ReadCaptureFile(strings.TrimSpace(file))
+		if err != nil {
+			slog.Error("read capture", slog.Any("err", err))
+			os.Exit(1)
+		}
+		records = append(records, v...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *addr != "" {
+		if err := service.ReplayNetwork(ctx, *network, *addr, records, *speed); err != nil {
+			slog.Error("replay", slog.String("addr", *addr), slog.String("network", *network), slog.Any("err", err))
+			os.Exit(1)
+		}
+		return
+	}
+	msgs, err := service.ReplayParse(ctx, records, *speed)
+	for _, msg := range msgs {
+		fmt.Println(msg.Header.String())
+		fmt.Printf("body: [%x]\n\n", msg.Body)
+	}
+	if err != nil {
+		slog.Error("replay parse", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
@@ -7,4 +7,8 @@ var (
 	ErrHeaderLength2Short      = errors.New("header length too short")
 	ErrBodyLengthInconsistency = errors.New("body length inconsistency")
 	ErrCheckCode               = errors.New("check code fail")
+	ErrDecrypt                 = errors.New("decrypt fail")
+	ErrEncryptKeyNotExist      = errors.New("encrypt key not exist")
+	ErrEncrypt                 = errors.New("encrypt fail")
+	ErrRSAKeySize              = errors.New("rsa key size not supported")
 )
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/cuteLittleDevil/go-jt808/protocol/utils"
+	"log/slog"
 	"strings"
 )
 
@@ -60,11 +61,19 @@ type (
 		videoFrame bool
 		// headEnd 头部是到哪里结束的
 		headEnd int
+		// logger 解析异常数据的日志 为空的时候使用slog.Default()
+		logger *slog.Logger
 	}
 )
 
-func NewPacket() *Packet {
-	return &Packet{}
+func NewPacket(opts ...Option) *Packet {
+	options := &Options{}
+	for _, op := range opts {
+		op.F(options)
+	}
+	p := &Packet{}
+	p.customAttributes.logger = options.Logger
+	return p
 }
 
 func (p *Packet) Decode(data []byte) (remainData []byte, err error) {
@@ -88,7 +97,9 @@ func (p *Packet) decodeHead(data []byte) error {
 	}
 	p.ID = string(data[:4])
 	if p.ID != "01cd" { // 1078协议固定
-		fmt.Println(fmt.Sprintf("%x", data[:16]))
+		p.log().Debug("unqualified head",
+			slog.String("sim", utils.Bcd2Dec(data[8:14])),
+			slog.String("head", fmt.Sprintf("%x", data[:16])))
 		return errors.Join(fmt.Errorf("id is [%s]", p.ID), ErrUnqualifiedData)
 	}
 
@@ -136,6 +147,13 @@ func (p *Packet) decodeHead(data []byte) error {
 	return nil
 }
 
+func (p *Packet) log() *slog.Logger {
+	if p.customAttributes.logger == nil {
+		return slog.Default()
+	}
+	return p.customAttributes.logger
+}
+
 func (p *Packet) String() string {
 	str := ""
 	if p.DataType != DataTypePenetrate {
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +128,16 @@ func TestPacketDecode(t *testing.T) {
 	}
 
 }
+
+func TestPacketWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	data, _ := hex.DecodeString("2031636481e20000295696659617010000000000000000000000000000020000")
+	p := NewPacket(WithLogger(logger))
+	if _, err := p.Decode(data); !errors.Is(err, ErrUnqualifiedData) {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "unqualified head") || !strings.Contains(buf.String(), "sim=295696659617") {
+		t.Errorf("log = %s", buf.String())
+	}
+}
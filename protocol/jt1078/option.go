@@ -0,0 +1,19 @@
+package jt1078
+
+import "log/slog"
+
+type Option struct {
+	F func(o *Options)
+}
+
+type Options struct {
+	// Logger 解析异常数据的日志 默认slog.Default()
+	Logger *slog.Logger
+}
+
+// WithLogger 设置解析异常数据的日志 默认slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return Option{F: func(o *Options) {
+		o.Logger = logger
+	}}
+}
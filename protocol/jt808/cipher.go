@@ -0,0 +1,77 @@
+package jt808
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"sync/atomic"
+)
+
+// Cipher 消息体的加解密 消息体属性的加密标识第10位为1的时候使用.
+type Cipher interface {
+	// Encrypt 加密平台下发的消息体.
+	Encrypt(body []byte) ([]byte, error)
+	// Decrypt 解密终端上传的消息体.
+	Decrypt(body []byte) ([]byte, error)
+}
+
+// RSACipher RSA加密 终端上传的用平台私钥解密 平台下发的用终端公钥(0x0A00)加密.
+// 按照密钥长度分块 填充方式PKCS1v15.
+type RSACipher struct {
+	privateKey *rsa.PrivateKey
+	publicKey  atomic.Pointer[rsa.PublicKey]
+}
+
+// NewRSACipher privateKey是平台的私钥 对应的公钥通过0x8A00下发给终端.
+func NewRSACipher(privateKey *rsa.PrivateKey) *RSACipher {
+	return &RSACipher{
+		privateKey: privateKey,
+	}
+}
+
+// SetPublicKey 设置终端的公钥 终端上传0x0A00后设置.
+func (r *RSACipher) SetPublicKey(publicKey *rsa.PublicKey) {
+	r.publicKey.Store(publicKey)
+}
+
+// PublicKey 终端的公钥 还没有上传的为空.
+func (r *RSACipher) PublicKey() *rsa.PublicKey {
+	return r.publicKey.Load()
+}
+
+func (r *RSACipher) Encrypt(body []byte) ([]byte, error) {
+	publicKey := r.publicKey.Load()
+	if publicKey == nil {
+		return nil, protocol.ErrEncryptKeyNotExist
+	}
+	size := publicKey.Size() - 11 // PKCS1v15填充占用11个字节
+	data := make([]byte, 0, (len(body)/size+1)*publicKey.Size())
+	for start := 0; start < len(body); start += size {
+		end := min(start+size, len(body))
+		block, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, body[start:end])
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, block...)
+	}
+	return data, nil
+}
+
+func (r *RSACipher) Decrypt(body []byte) ([]byte, error) {
+	if r.privateKey == nil {
+		return nil, protocol.ErrEncryptKeyNotExist
+	}
+	size := r.privateKey.Size()
+	if len(body)%size != 0 {
+		return nil, protocol.ErrDecrypt
+	}
+	data := make([]byte, 0, len(body))
+	for start := 0; start < len(body); start += size {
+		block, err := rsa.DecryptPKCS1v15(nil, r.privateKey, body[start:start+size])
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, block...)
+	}
+	return data, nil
+}
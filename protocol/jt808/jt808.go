@@ -19,6 +19,7 @@ type (
 		// Property 消息属性
 		Property *BodyProperty `json:"property"`
 		// ProtocolVersion 协议版本 1-2011 2-2013 3-2019
+		// 2011和2013版本的请求头一样 解析的时候都是2013 2011版本需要根据注册消息(0x0100)判断后设置
 		ProtocolVersion consts.ProtocolVersionType `json:"protocolVersion,omitempty"`
 		// TerminalPhoneNo 根据安装后终端自身的手机号转换。手机号不足 12 位，则在前补充数字
 		// 大陆手机号补充数字 0，港澳台则根据其区号进行位数补充
@@ -36,6 +37,8 @@ type (
 		PlatformSerialNumber uint16 `json:"platformSerialNumber,omitempty"`
 		// ReplyID 平台回复的消息ID
 		ReplyID uint16 `json:"replyID,omitempty"`
+		// Cipher 消息体的加解密 为空的时候不处理 分包的情况需要合并后再解密
+		Cipher Cipher `json:"-"`
 
 		// headEnd 请求头结束位置
 		headEnd int
@@ -103,9 +106,28 @@ func (j *JTMessage) Decode(data []byte) error {
 	}
 	j.Body = escapeData[start:end]
 	j.VerifyCode = escapeData[end]
+	if j.Header.Property.EncryptMethod == 1 && !j.Header.Property.isSubPackage {
+		body, err := j.Header.Decrypt(j.Body)
+		if err != nil {
+			return err
+		}
+		j.Body = body
+	}
 	return nil
 }
 
+// Decrypt 加密标识为1并且设置了Cipher的时候解密 分包的情况合并后使用.
+func (h *Header) Decrypt(body []byte) ([]byte, error) {
+	if h.Property.EncryptMethod != 1 || h.Cipher == nil {
+		return body, nil
+	}
+	data, err := h.Cipher.Decrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", protocol.ErrDecrypt, err)
+	}
+	return data, nil
+}
+
 func (h *Header) decode(data []byte) error {
 	if len(data) < 4 {
 		return protocol.ErrHeaderLength2Short
@@ -142,6 +164,7 @@ func (h *Header) decode(data []byte) error {
 	return nil
 }
 
+// Encode 消息体不会加密 加密标识为1的需要先使用Encrypt加密消息体.
 func (h *Header) Encode(body []byte) []byte {
 	if len(body) < maxBodyLength {
 		return h.createPackage(body, 0, 0)
@@ -150,6 +173,28 @@ func (h *Header) Encode(body []byte) []byte {
 	}
 }
 
+// EncodeSubPackage 单独编码第no个分包 总包数是sum 流水号是PlatformSerialNumber+no-1.
+// 用于分包逐个下发等待应答的情况(如升级包0x8108) 消息体不加密.
+func (h *Header) EncodeSubPackage(body []byte, no, sum uint16) []byte {
+	h.Property.EncryptMethod = 0
+	return h.createPackage(body, no, sum)
+}
+
+// Encrypt 加密标识为1的时候使用Cipher加密 没有Cipher或者加密失败的返回错误 不会使用明文下发.
+func (h *Header) Encrypt(body []byte) ([]byte, error) {
+	if h.Property.EncryptMethod != 1 {
+		return body, nil
+	}
+	if h.Cipher == nil {
+		return nil, fmt.Errorf("%w: %w", protocol.ErrEncrypt, protocol.ErrEncryptKeyNotExist)
+	}
+	data, err := h.Cipher.Encrypt(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", protocol.ErrEncrypt, err)
+	}
+	return data, nil
+}
+
 func (h *Header) subPackageStatistics(body []byte) []byte {
 	bodyLen := len(body)
 	data := make([]byte, 0, bodyLen+maxBodyLength)
@@ -185,7 +230,7 @@ func (h *Header) createPackage(body []byte, num, sum uint16) []byte {
 	}
 	binary.BigEndian.PutUint16(data[2:4], h.Property.encode()) // 写消息属性
 	if h.ProtocolVersion == consts.JT808Protocol2019 {
-		// 2019版本的标识
+		// 2019版本的标识 2011和2013版本的请求头一样
 		data = append(data, 0x01)
 	}
 	data = append(data, h.bcdTerminalPhoneNo...) // 写终端手机号
@@ -2,6 +2,8 @@ package jt808
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -163,6 +165,78 @@ func TestEncode2(t *testing.T) {
 	}
 }
 
+func TestEncodeSubPackage(t *testing.T) {
+	jtMsg := NewJTMessage()
+	data, _ := hex.DecodeString("7e0002400001000000000172998417380000027e")
+	_ = jtMsg.Decode(data)
+	jtMsg.Header.ReplyID = uint16(consts.P8108DistributeTerminalUpgradePackage)
+	jtMsg.Header.PlatformSerialNumber = 10
+
+	body := bytes.Repeat([]byte{0x01}, 2500)
+	want := splitFrames(jtMsg.Header.Encode(body))
+	if len(want) != 3 {
+		t.Fatalf("Encode() sum=[%d] want 3", len(want))
+	}
+	for i := range want {
+		start := i * maxBodyLength
+		end := min(start+maxBodyLength, len(body))
+		got := jtMsg.Header.EncodeSubPackage(body[start:end], uint16(i+1), 3)
+		if !bytes.Equal(got, want[i]) {
+			t.Errorf("EncodeSubPackage() no=[%d]\ngot = %x\nwant= %x", i+1, got, want[i])
+		}
+	}
+}
+
+func splitFrames(data []byte) [][]byte {
+	frames := make([][]byte, 0)
+	for _, v := range bytes.Split(data, []byte{0x7e, 0x7e}) {
+		v = bytes.Trim(v, "\x7e")
+		frames = append(frames, append(append([]byte{0x7e}, v...), 0x7e))
+	}
+	return frames
+}
+
+func TestRSACipher(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher := NewRSACipher(privateKey)
+	head, _ := hex.DecodeString("7e0002000001234567890100008a7e")
+	jtMsg := NewJTMessage()
+	_ = jtMsg.Decode(head)
+	jtMsg.Header.Property.EncryptMethod = 1
+	jtMsg.Header.Cipher = cipher
+	body := bytes.Repeat([]byte{0x7e, 0x01}, 100)
+
+	// 没有终端的公钥 返回错误 不使用明文
+	if _, err := jtMsg.Header.Encrypt(body); !errors.Is(err, protocol.ErrEncrypt) || !errors.Is(err, protocol.ErrEncryptKeyNotExist) {
+		t.Fatalf("Encrypt() err = %v", err)
+	}
+
+	cipher.SetPublicKey(&privateKey.PublicKey)
+	encryptedBody, err := jtMsg.Header.Encrypt(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := jtMsg.Header.Encode(encryptedBody)
+	encrypted := NewJTMessage()
+	if err := encrypted.Decode(data); err != nil || bytes.Equal(encrypted.Body, body) {
+		t.Fatalf("Decode() without cipher err = %v", err)
+	}
+	decrypted := NewJTMessage()
+	decrypted.Header.Cipher = cipher
+	if err := decrypted.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted.Body, body) {
+		t.Errorf("Decode() body = %x\n want %x", decrypted.Body, body)
+	}
+	if _, err := cipher.Decrypt(body[:10]); !errors.Is(err, protocol.ErrDecrypt) {
+		t.Errorf("Decrypt() err = %v", err)
+	}
+}
+
 func TestString(t *testing.T) {
 	tests := []struct {
 		name string
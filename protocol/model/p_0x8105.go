@@ -0,0 +1,321 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/utils"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strconv"
+	"strings"
+)
+
+// P8105CommandWord 终端控制命令字.
+type P8105CommandWord byte
+
+const (
+	// P8105WirelessUpgrade 无线升级 参数之间采用半角分号分隔
+	P8105WirelessUpgrade P8105CommandWord = 1
+	// P8105ConnectServer 控制终端连接指定服务器 参数之间采用半角分号分隔
+	P8105ConnectServer P8105CommandWord = 2
+	// P8105Shutdown 终端关机
+	P8105Shutdown P8105CommandWord = 3
+	// P8105Reset 终端复位
+	P8105Reset P8105CommandWord = 4
+	// P8105FactoryReset 终端恢复出厂设置
+	P8105FactoryReset P8105CommandWord = 5
+	// P8105CloseDataCommunication 关闭数据通信
+	P8105CloseDataCommunication P8105CommandWord = 6
+	// P8105CloseWirelessCommunication 关闭所有无线通信
+	P8105CloseWirelessCommunication P8105CommandWord = 7
+)
+
+func (c P8105CommandWord) String() string {
+	switch c {
+	case P8105WirelessUpgrade:
+		return "无线升级"
+	case P8105ConnectServer:
+		return "控制终端连接指定服务器"
+	case P8105Shutdown:
+		return "终端关机"
+	case P8105Reset:
+		return "终端复位"
+	case P8105FactoryReset:
+		return "终端恢复出厂设置"
+	case P8105CloseDataCommunication:
+		return "关闭数据通信"
+	case P8105CloseWirelessCommunication:
+		return "关闭所有无线通信"
+	}
+	return "未知命令"
+}
+
+type (
+	P0x8105 struct {
+		BaseHandle
+		// CommandWord 命令字 1-无线升级 2-控制终端连接指定服务器 3-终端关机
+		// 4-终端复位 5-终端恢复出厂设置 6-关闭数据通信 7-关闭所有无线通信
+		CommandWord P8105CommandWord `json:"commandWord"`
+		// CommandParams 命令参数 参数之间采用半角分号分隔 GBK编码发送给终端
+		// 编码的时候 命令字1和2优先使用UpgradeParam和ConnectParam生成
+		CommandParams string `json:"commandParams,omitempty"`
+		// UpgradeParam 无线升级参数 命令字为1的时候使用
+		UpgradeParam *P0x8105UpgradeParam `json:"upgradeParam,omitempty"`
+		// ConnectParam 控制终端连接指定服务器参数 命令字为2的时候使用
+		ConnectParam *P0x8105ConnectParam `json:"connectParam,omitempty"`
+	}
+
+	// P0x8105UpgradeParam 无线升级参数 见表20 数值为0的时候参数为空.
+	P0x8105UpgradeParam struct {
+		// URL 完整的地址
+		URL string `json:"url"`
+		// DialPointName 拨号点名称 一般为服务器APN
+		DialPointName string `json:"dialPointName"`
+		// DialUserName 拨号用户名
+		DialUserName string `json:"dialUserName"`
+		// DialPassword 拨号密码
+		DialPassword string `json:"dialPassword"`
+		// Address 服务器地址 IP或域名
+		Address string `json:"address"`
+		// TCPPort 服务器TCP端口
+		TCPPort uint16 `json:"tcpPort"`
+		// UDPPort 服务器UDP端口
+		UDPPort uint16 `json:"udpPort"`
+		// ManufacturerID 制造商ID 终端制造商编码
+		ManufacturerID string `json:"manufacturerID"`
+		// HardwareVersion 硬件版本
+		HardwareVersion string `json:"hardwareVersion"`
+		// FirmwareVersion 固件版本
+		FirmwareVersion string `json:"firmwareVersion"`
+		// ConnectTimeLimit 连接到指定服务器时限 单位分钟 值非0的时候表示在终端接收到升级命令后
+		// 在有效期截止前终端应连回原地址 若值为0 则表示一直连接指定服务器
+		ConnectTimeLimit uint16 `json:"connectTimeLimit"`
+	}
+
+	// P0x8105ConnectParam 控制终端连接指定服务器参数 见表21 数值为0的时候参数为空.
+	P0x8105ConnectParam struct {
+		// ConnectControl 连接控制 0-切换到指定监管平台服务器 连接到该服务器后即进入应急状态
+		// 此状态下仅有下发控制指令的监管平台可发送包括短信在内的控制指令
+		// 1-切换回原缺省监控平台服务器 并恢复正常状态 此时没有后续参数
+		ConnectControl byte `json:"connectControl"`
+		// AuthCode 监管平台鉴权码 监管平台下发的鉴权码 仅用于终端连接到监管平台之后的鉴权
+		// 终端连接回原监控平台还用原鉴权码
+		AuthCode string `json:"authCode"`
+		// DialPointName 拨号点名称 一般为服务器APN
+		DialPointName string `json:"dialPointName"`
+		// DialUserName 拨号用户名
+		DialUserName string `json:"dialUserName"`
+		// DialPassword 拨号密码
+		DialPassword string `json:"dialPassword"`
+		// Address 服务器地址 IP或域名
+		Address string `json:"address"`
+		// TCPPort 服务器TCP端口
+		TCPPort uint16 `json:"tcpPort"`
+		// UDPPort 服务器UDP端口
+		UDPPort uint16 `json:"udpPort"`
+		// ConnectTimeLimit 连接到指定服务器时限 单位分钟 值非0的时候表示在终端接收到控制命令后
+		// 在有效期截止前终端应连回原地址 若值为0 则表示一直连接指定服务器
+		ConnectTimeLimit uint16 `json:"connectTimeLimit"`
+	}
+)
+
+func (p *P0x8105) Protocol() consts.JT808CommandType {
+	return consts.P8105TerminalControl
+}
+
+func (p *P0x8105) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8105) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 1 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.CommandWord = P8105CommandWord(body[0])
+	p.CommandParams = string(utils.GBK2UTF8(body[1:]))
+	p.UpgradeParam = nil
+	p.ConnectParam = nil
+	switch p.CommandWord {
+	case P8105WirelessUpgrade:
+		p.UpgradeParam = &P0x8105UpgradeParam{}
+		return p.UpgradeParam.parse(p.CommandParams)
+	case P8105ConnectServer:
+		p.ConnectParam = &P0x8105ConnectParam{}
+		return p.ConnectParam.parse(p.CommandParams)
+	}
+	return nil
+}
+
+func (p *P0x8105) Encode() []byte {
+	params := p.CommandParams
+	switch {
+	case p.CommandWord == P8105WirelessUpgrade && p.UpgradeParam != nil:
+		params = p.UpgradeParam.encode()
+	case p.CommandWord == P8105ConnectServer && p.ConnectParam != nil:
+		params = p.ConnectParam.encode()
+	}
+	data := make([]byte, 1, 1+len(params))
+	data[0] = byte(p.CommandWord)
+	data = append(data, utils.UTF82GBK([]byte(params))...)
+	return data
+}
+
+func (p *P0x8105) HasReply() bool {
+	return false
+}
+
+func (p *P0x8105) String() string {
+	str := ""
+	switch {
+	case p.CommandWord == P8105WirelessUpgrade && p.UpgradeParam != nil:
+		str = p.UpgradeParam.String()
+	case p.CommandWord == P8105ConnectServer && p.ConnectParam != nil:
+		str = p.ConnectParam.String()
+	}
+	body := p.Encode()
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), body),
+		fmt.Sprintf("\t[%02x] 命令字:[%d] [%s]", byte(p.CommandWord), p.CommandWord, p.CommandWord),
+		fmt.Sprintf("\t[%x] 命令参数:[%s]", body[1:], utils.GBK2UTF8(body[1:])),
+		str,
+		"}",
+	}, "\n")
+}
+
+func (u *P0x8105UpgradeParam) parse(params string) error {
+	values := splitP8105Params(params, 11)
+	var err error
+	u.URL = values[0]
+	u.DialPointName = values[1]
+	u.DialUserName = values[2]
+	u.DialPassword = values[3]
+	u.Address = values[4]
+	if u.TCPPort, err = parseP8105Number(values[5]); err != nil {
+		return err
+	}
+	if u.UDPPort, err = parseP8105Number(values[6]); err != nil {
+		return err
+	}
+	u.ManufacturerID = values[7]
+	u.HardwareVersion = values[8]
+	u.FirmwareVersion = values[9]
+	u.ConnectTimeLimit, err = parseP8105Number(values[10])
+	return err
+}
+
+func (u *P0x8105UpgradeParam) encode() string {
+	return strings.Join([]string{
+		u.URL,
+		u.DialPointName,
+		u.DialUserName,
+		u.DialPassword,
+		u.Address,
+		formatP8105Number(u.TCPPort),
+		formatP8105Number(u.UDPPort),
+		u.ManufacturerID,
+		u.HardwareVersion,
+		u.FirmwareVersion,
+		formatP8105Number(u.ConnectTimeLimit),
+	}, ";")
+}
+
+func (u *P0x8105UpgradeParam) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\tURL地址:[%s]", u.URL),
+		fmt.Sprintf("\t\t拨号点名称:[%s]", u.DialPointName),
+		fmt.Sprintf("\t\t拨号用户名:[%s]", u.DialUserName),
+		fmt.Sprintf("\t\t拨号密码:[%s]", u.DialPassword),
+		fmt.Sprintf("\t\t地址:[%s]", u.Address),
+		fmt.Sprintf("\t\tTCP端口:[%d]", u.TCPPort),
+		fmt.Sprintf("\t\tUDP端口:[%d]", u.UDPPort),
+		fmt.Sprintf("\t\t制造商ID:[%s]", u.ManufacturerID),
+		fmt.Sprintf("\t\t硬件版本:[%s]", u.HardwareVersion),
+		fmt.Sprintf("\t\t固件版本:[%s]", u.FirmwareVersion),
+		fmt.Sprintf("\t\t连接到指定服务器时限:[%d]分钟", u.ConnectTimeLimit),
+	}, "\n")
+}
+
+func (c *P0x8105ConnectParam) parse(params string) error {
+	values := splitP8105Params(params, 9)
+	control, err := parseP8105Number(values[0])
+	if err != nil || control > 0xff {
+		return protocol.ErrUnqualifiedData
+	}
+	c.ConnectControl = byte(control)
+	c.AuthCode = values[1]
+	c.DialPointName = values[2]
+	c.DialUserName = values[3]
+	c.DialPassword = values[4]
+	c.Address = values[5]
+	if c.TCPPort, err = parseP8105Number(values[6]); err != nil {
+		return err
+	}
+	if c.UDPPort, err = parseP8105Number(values[7]); err != nil {
+		return err
+	}
+	c.ConnectTimeLimit, err = parseP8105Number(values[8])
+	return err
+}
+
+func (c *P0x8105ConnectParam) encode() string {
+	if c.ConnectControl == 1 {
+		// 切换回原缺省监控平台服务器 没有后续参数
+		return "1"
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(c.ConnectControl)),
+		c.AuthCode,
+		c.DialPointName,
+		c.DialUserName,
+		c.DialPassword,
+		c.Address,
+		formatP8105Number(c.TCPPort),
+		formatP8105Number(c.UDPPort),
+		formatP8105Number(c.ConnectTimeLimit),
+	}, ";")
+}
+
+func (c *P0x8105ConnectParam) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t连接控制:[%d] 0-切换到指定监管平台服务器 1-切换回原缺省监控平台服务器", c.ConnectControl),
+		fmt.Sprintf("\t\t监管平台鉴权码:[%s]", c.AuthCode),
+		fmt.Sprintf("\t\t拨号点名称:[%s]", c.DialPointName),
+		fmt.Sprintf("\t\t拨号用户名:[%s]", c.DialUserName),
+		fmt.Sprintf("\t\t拨号密码:[%s]", c.DialPassword),
+		fmt.Sprintf("\t\t地址:[%s]", c.Address),
+		fmt.Sprintf("\t\tTCP端口:[%d]", c.TCPPort),
+		fmt.Sprintf("\t\tUDP端口:[%d]", c.UDPPort),
+		fmt.Sprintf("\t\t连接到指定服务器时限:[%d]分钟", c.ConnectTimeLimit),
+	}, "\n")
+}
+
+// splitP8105Params 按半角分号拆分参数 不足的补空.
+func splitP8105Params(params string, n int) []string {
+	values := make([]string, n)
+	if params == "" {
+		return values
+	}
+	copy(values, strings.Split(params, ";"))
+	return values
+}
+
+func parseP8105Number(v string) (uint16, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	num, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return 0, protocol.ErrUnqualifiedData
+	}
+	return uint16(num), nil
+}
+
+func formatP8105Number(v uint16) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(v))
+}
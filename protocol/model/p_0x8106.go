@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8106 struct {
+	BaseHandle
+	// ParamTotal 参数总数
+	ParamTotal byte `json:"paramTotal"`
+	// ParamIDList 参数ID列表 终端使用0x0104应答 应答的参数项使用TerminalParamDetails解析
+	ParamIDList []uint32 `json:"paramIDList"`
+}
+
+func (p *P0x8106) Protocol() consts.JT808CommandType {
+	return consts.P8106QuerySpecifyParam
+}
+
+func (p *P0x8106) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0104QueryParameter
+}
+
+func (p *P0x8106) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 1 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.ParamTotal = body[0]
+	if len(body) != 1+4*int(p.ParamTotal) {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.ParamIDList = make([]uint32, 0, p.ParamTotal)
+	for i := 1; i < len(body); i += 4 {
+		p.ParamIDList = append(p.ParamIDList, binary.BigEndian.Uint32(body[i:i+4]))
+	}
+	return nil
+}
+
+func (p *P0x8106) Encode() []byte {
+	p.ParamTotal = byte(len(p.ParamIDList))
+	data := make([]byte, 1, 1+4*len(p.ParamIDList))
+	data[0] = p.ParamTotal
+	for _, id := range p.ParamIDList {
+		data = binary.BigEndian.AppendUint32(data, id)
+	}
+	return data
+}
+
+func (p *P0x8106) HasReply() bool {
+	return false
+}
+
+func (p *P0x8106) String() string {
+	str := "\t参数ID列表:"
+	for _, v := range p.ParamIDList {
+		str += fmt.Sprintf("\n\t[%08x] 参数ID:[%d]", v, v)
+	}
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%02x] 参数总数:[%d]", p.ParamTotal, p.ParamTotal),
+		str,
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8107 struct {
+	BaseHandle
+}
+
+func (p *P0x8107) Protocol() consts.JT808CommandType {
+	return consts.P8107QueryTerminalProperties
+}
+
+func (p *P0x8107) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0107QueryAttribute
+}
+
+func (p *P0x8107) Parse(_ *jt808.JTMessage) error {
+	return nil
+}
+
+func (p *P0x8107) Encode() []byte {
+	return nil
+}
+
+func (p *P0x8107) HasReply() bool {
+	return false
+}
+
+func (p *P0x8107) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:null%x", p.Protocol(), p.Encode()),
+		"}",
+	}, "\n")
+}
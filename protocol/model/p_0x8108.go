@@ -0,0 +1,95 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/utils"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+// manufacturerIDLen 升级包中制造商ID的长度.
+const manufacturerIDLen = 5
+
+type P0x8108 struct {
+	BaseHandle
+	// UpgradeType 升级类型 0-终端 12-道路运输证IC卡读卡器 52-北斗卫星定位模块
+	UpgradeType byte `json:"upgradeType"`
+	// ManufacturerID 制造商ID 终端制造商编码 固定5个字节
+	ManufacturerID string `json:"manufacturerID"`
+	// VersionLen 版本号长度 编码的时候根据Version生成
+	VersionLen byte `json:"versionLen"`
+	// Version 版本号
+	Version string `json:"version"`
+	// PackageLen 升级数据包长度 单位byte 编码的时候根据Package生成
+	PackageLen uint32 `json:"packageLen"`
+	// Package 升级数据包 超过1000字节的平台分包下发
+	Package []byte `json:"package"`
+}
+
+func (p *P0x8108) Protocol() consts.JT808CommandType {
+	return consts.P8108DistributeTerminalUpgradePackage
+}
+
+func (p *P0x8108) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8108) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 1+manufacturerIDLen+1 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.UpgradeType = body[0]
+	p.ManufacturerID = string(bytes.TrimRight(body[1:1+manufacturerIDLen], "\x00"))
+	p.VersionLen = body[1+manufacturerIDLen]
+	start := 1 + manufacturerIDLen + 1
+	end := start + int(p.VersionLen)
+	if len(body) < end+4 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.Version = string(body[start:end])
+	p.PackageLen = binary.BigEndian.Uint32(body[end : end+4])
+	if len(body) != end+4+int(p.PackageLen) {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.Package = body[end+4:]
+	return nil
+}
+
+func (p *P0x8108) Encode() []byte {
+	version := []byte(p.Version)
+	p.VersionLen = byte(len(version))
+	p.PackageLen = uint32(len(p.Package))
+	data := make([]byte, 0, 1+manufacturerIDLen+1+len(version)+4+len(p.Package))
+	data = append(data, p.UpgradeType)
+	data = append(data, utils.String2FillingBytes(p.ManufacturerID, manufacturerIDLen)...)
+	data = append(data, p.VersionLen)
+	data = append(data, version...)
+	data = binary.BigEndian.AppendUint32(data, p.PackageLen)
+	data = append(data, p.Package...)
+	return data
+}
+
+func (p *P0x8108) HasReply() bool {
+	return false
+}
+
+func (p *P0x8108) String() string {
+	data := p.Encode()
+	// 升级包一般比较大 只显示升级包前面的数据
+	head := data[:len(data)-len(p.Package)]
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x...] 总长度:[%d]", p.Protocol(), head, len(data)),
+		fmt.Sprintf("\t[%02x] 升级类型:[%d] 0-终端 12-道路运输证IC卡读卡器 52-北斗卫星定位模块", p.UpgradeType, p.UpgradeType),
+		fmt.Sprintf("\t[%010x] 制造商ID:[%s]", utils.String2FillingBytes(p.ManufacturerID, manufacturerIDLen), p.ManufacturerID),
+		fmt.Sprintf("\t[%02x] 版本号长度:[%d]", p.VersionLen, p.VersionLen),
+		fmt.Sprintf("\t[%x] 版本号:[%s]", p.Version, p.Version),
+		fmt.Sprintf("\t[%08x] 升级数据包长度:[%d]", p.PackageLen, p.PackageLen),
+		"}",
+	}, "\n")
+}
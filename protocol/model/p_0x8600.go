@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8600 struct {
+	BaseHandle
+	// SetAttribute 设置属性 0-更新区域 1-追加区域 2-修改区域
+	SetAttribute byte `json:"setAttribute"`
+	// AreaTotal 区域总数
+	AreaTotal byte `json:"areaTotal"`
+	// Areas 区域项
+	Areas []AreaCircle `json:"areas"`
+	// Version 版本 2019版本增加了夜间最高速度和区域名称
+	Version consts.ProtocolVersionType `json:"version"`
+}
+
+func (p *P0x8600) Protocol() consts.JT808CommandType {
+	return consts.P8600SetCircularArea
+}
+
+func (p *P0x8600) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8600) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 2 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.Version = areaVersion(jtMsg)
+	p.SetAttribute = body[0]
+	p.AreaTotal = body[1]
+	r := &areaReader{data: body[2:]}
+	p.Areas = make([]AreaCircle, 0, p.AreaTotal)
+	for i := 0; i < int(p.AreaTotal) && r.err == nil; i++ {
+		area := AreaCircle{}
+		area.parse(r, p.Version)
+		p.Areas = append(p.Areas, area)
+	}
+	return r.finish()
+}
+
+func (p *P0x8600) Encode() []byte {
+	p.AreaTotal = byte(len(p.Areas))
+	data := make([]byte, 2, 100)
+	data[0] = p.SetAttribute
+	data[1] = p.AreaTotal
+	for i := range p.Areas {
+		data = p.Areas[i].encode(data, p.Version)
+	}
+	return data
+}
+
+func (p *P0x8600) HasReply() bool {
+	return false
+}
+
+func (p *P0x8600) String() string {
+	str := "\t区域项列表:"
+	for _, v := range p.Areas {
+		str += "\n" + v.String()
+	}
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%02x] 设置属性:[%d] 0-更新区域 1-追加区域 2-修改区域", p.SetAttribute, p.SetAttribute),
+		fmt.Sprintf("\t[%02x] 区域总数:[%d]", p.AreaTotal, p.AreaTotal),
+		str,
+		"}",
+	}, "\n")
+}
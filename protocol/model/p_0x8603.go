@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8603 struct {
+	BaseHandle
+	// AreaTotal 区域数 不超过125个 0-删除所有矩形区域
+	AreaTotal byte `json:"areaTotal"`
+	// AreaIDs 区域ID列表
+	AreaIDs []uint32 `json:"areaIDs"`
+}
+
+func (p *P0x8603) Protocol() consts.JT808CommandType {
+	return consts.P8603DeleteRectArea
+}
+
+func (p *P0x8603) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8603) Parse(jtMsg *jt808.JTMessage) error {
+	var err error
+	p.AreaTotal, p.AreaIDs, err = parseAreaIDs(jtMsg.Body)
+	return err
+}
+
+func (p *P0x8603) Encode() []byte {
+	p.AreaTotal = byte(len(p.AreaIDs))
+	return encodeAreaIDs(p.AreaIDs)
+}
+
+func (p *P0x8603) HasReply() bool {
+	return false
+}
+
+func (p *P0x8603) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%02x] 区域数:[%d] 0-删除所有矩形区域", p.AreaTotal, p.AreaTotal),
+		areaIDsString(p.AreaIDs),
+		"}",
+	}, "\n")
+}
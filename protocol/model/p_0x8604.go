@@ -0,0 +1,48 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8604 struct {
+	BaseHandle
+	// AreaPolygon 多边形区域 一次只设置一个区域
+	AreaPolygon
+	// Version 版本 2019版本增加了夜间最高速度和区域名称
+	Version consts.ProtocolVersionType `json:"version"`
+}
+
+func (p *P0x8604) Protocol() consts.JT808CommandType {
+	return consts.P8604PolygonArea
+}
+
+func (p *P0x8604) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8604) Parse(jtMsg *jt808.JTMessage) error {
+	p.Version = areaVersion(jtMsg)
+	r := &areaReader{data: jtMsg.Body}
+	p.AreaPolygon.parse(r, p.Version)
+	return r.finish()
+}
+
+func (p *P0x8604) Encode() []byte {
+	return p.AreaPolygon.encode(make([]byte, 0, 100), p.Version)
+}
+
+func (p *P0x8604) HasReply() bool {
+	return false
+}
+
+func (p *P0x8604) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		p.AreaPolygon.String(),
+		"}",
+	}, "\n")
+}
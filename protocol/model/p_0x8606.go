@@ -0,0 +1,48 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8606 struct {
+	BaseHandle
+	// AreaRoute 路线 一次只设置一条路线
+	AreaRoute
+	// Version 版本 2019版本增加了路段夜间最高速度和路线名称
+	Version consts.ProtocolVersionType `json:"version"`
+}
+
+func (p *P0x8606) Protocol() consts.JT808CommandType {
+	return consts.P8606SetRoute
+}
+
+func (p *P0x8606) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8606) Parse(jtMsg *jt808.JTMessage) error {
+	p.Version = areaVersion(jtMsg)
+	r := &areaReader{data: jtMsg.Body}
+	p.AreaRoute.parse(r, p.Version)
+	return r.finish()
+}
+
+func (p *P0x8606) Encode() []byte {
+	return p.AreaRoute.encode(make([]byte, 0, 100), p.Version)
+}
+
+func (p *P0x8606) HasReply() bool {
+	return false
+}
+
+func (p *P0x8606) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		p.AreaRoute.String(),
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8607 struct {
+	BaseHandle
+	// RouteTotal 路线数 不超过125个 0-删除所有路线
+	RouteTotal byte `json:"routeTotal"`
+	// RouteIDs 路线ID列表
+	RouteIDs []uint32 `json:"routeIDs"`
+}
+
+func (p *P0x8607) Protocol() consts.JT808CommandType {
+	return consts.P8607DeleteRoute
+}
+
+func (p *P0x8607) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8607) Parse(jtMsg *jt808.JTMessage) error {
+	var err error
+	p.RouteTotal, p.RouteIDs, err = parseAreaIDs(jtMsg.Body)
+	return err
+}
+
+func (p *P0x8607) Encode() []byte {
+	p.RouteTotal = byte(len(p.RouteIDs))
+	return encodeAreaIDs(p.RouteIDs)
+}
+
+func (p *P0x8607) HasReply() bool {
+	return false
+}
+
+func (p *P0x8607) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%02x] 路线数:[%d] 0-删除所有路线", p.RouteTotal, p.RouteTotal),
+		areaIDsString(p.RouteIDs),
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type P0x8608 struct {
+	BaseHandle
+	// QueryType 查询类型 1-圆形区域 2-矩形区域 3-多边形区域 4-路线
+	QueryType byte `json:"queryType"`
+	// Total 要查询的区域或路线的ID数量 0-查询所有该类型的区域或路线
+	Total uint32 `json:"total"`
+	// IDs 区域或路线的ID列表
+	IDs []uint32 `json:"ids"`
+}
+
+func (p *P0x8608) Protocol() consts.JT808CommandType {
+	return consts.P8608QueryAreaOrRouteData
+}
+
+func (p *P0x8608) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0608QueryRegionRespond
+}
+
+func (p *P0x8608) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 5 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.QueryType = body[0]
+	p.Total = binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)) != 5+4*uint64(p.Total) {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	p.IDs = make([]uint32, 0, p.Total)
+	for i := 5; i < len(body); i += 4 {
+		p.IDs = append(p.IDs, binary.BigEndian.Uint32(body[i:i+4]))
+	}
+	return nil
+}
+
+func (p *P0x8608) Encode() []byte {
+	p.Total = uint32(len(p.IDs))
+	data := make([]byte, 5, 5+4*len(p.IDs))
+	data[0] = p.QueryType
+	binary.BigEndian.PutUint32(data[1:5], p.Total)
+	for _, id := range p.IDs {
+		data = binary.BigEndian.AppendUint32(data, id)
+	}
+	return data
+}
+
+func (p *P0x8608) HasReply() bool {
+	return false
+}
+
+func (p *P0x8608) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%02x] 查询类型:[%d] 1-圆形区域 2-矩形区域 3-多边形区域 4-路线", p.QueryType, p.QueryType),
+		fmt.Sprintf("\t[%08x] 查询的ID数量:[%d] 0-查询所有", p.Total, p.Total),
+		areaIDsString(p.IDs),
+		"}",
+	}, "\n")
+}
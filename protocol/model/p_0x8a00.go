@@ -0,0 +1,64 @@
+package model
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+// P0x8A00 平台RSA公钥 终端上传的消息体使用这个公钥加密.
+type P0x8A00 struct {
+	BaseHandle
+	// E RSA公钥{e,n}中的e
+	E uint32 `json:"e"`
+	// N RSA公钥{e,n}中的n 128个字节
+	N []byte `json:"n"`
+}
+
+// SetPublicKey 使用标准库的公钥设置e和n n固定128个字节 超过1024位的密钥返回错误.
+func (p *P0x8A00) SetPublicKey(publicKey *rsa.PublicKey) error {
+	if publicKey.Size() > rsaModulusLength {
+		return fmt.Errorf("%w: bits=[%d]", protocol.ErrRSAKeySize, publicKey.N.BitLen())
+	}
+	p.E = uint32(publicKey.E)
+	p.N = publicKey.N.FillBytes(make([]byte, rsaModulusLength))
+	return nil
+}
+
+func (p *P0x8A00) Protocol() consts.JT808CommandType {
+	return consts.P8A00PlatformRSAPublicKey
+}
+
+func (p *P0x8A00) ReplyProtocol() consts.JT808CommandType {
+	return consts.T0001GeneralRespond
+}
+
+func (p *P0x8A00) Parse(jtMsg *jt808.JTMessage) error {
+	e, n, err := parseRSAPublicKey(jtMsg.Body)
+	if err != nil {
+		return err
+	}
+	p.E, p.N = e, n
+	return nil
+}
+
+func (p *P0x8A00) Encode() []byte {
+	return encodeRSAPublicKey(p.E, p.N)
+}
+
+func (p *P0x8A00) HasReply() bool {
+	return false
+}
+
+func (p *P0x8A00) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", p.Protocol(), p.Encode()),
+		fmt.Sprintf("\t[%08x] e:[%d]", p.E, p.E),
+		fmt.Sprintf("\t[%x] n", p.N),
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,678 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/utils"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type (
+	// AreaCircle 圆形区域项 见表57.
+	AreaCircle struct {
+		// ID 区域ID
+		ID uint32 `json:"id"`
+		// Attribute 区域属性 见AreaAttributeDetails
+		Attribute uint16 `json:"attribute"`
+		// Latitude 中心点纬度 以度为单位的纬度值乘以10的6次方
+		Latitude uint32 `json:"latitude"`
+		// Longitude 中心点经度 以度为单位的经度值乘以10的6次方
+		Longitude uint32 `json:"longitude"`
+		// Radius 半径 单位为米(m)
+		Radius uint32 `json:"radius"`
+		// AreaLimit 时间 限速 名称和区域属性详情
+		AreaLimit
+	}
+
+	// AreaRect 矩形区域项 见表60.
+	AreaRect struct {
+		// ID 区域ID
+		ID uint32 `json:"id"`
+		// Attribute 区域属性 见AreaAttributeDetails
+		Attribute uint16 `json:"attribute"`
+		// TopLeftLatitude 左上点纬度
+		TopLeftLatitude uint32 `json:"topLeftLatitude"`
+		// TopLeftLongitude 左上点经度
+		TopLeftLongitude uint32 `json:"topLeftLongitude"`
+		// BottomRightLatitude 右下点纬度
+		BottomRightLatitude uint32 `json:"bottomRightLatitude"`
+		// BottomRightLongitude 右下点经度
+		BottomRightLongitude uint32 `json:"bottomRightLongitude"`
+		// AreaLimit 时间 限速 名称和区域属性详情
+		AreaLimit
+	}
+
+	// AreaPolygon 多边形区域 见表62.
+	AreaPolygon struct {
+		// ID 区域ID
+		ID uint32 `json:"id"`
+		// Attribute 区域属性 见AreaAttributeDetails
+		Attribute uint16 `json:"attribute"`
+		// StartTime 起始时间 YY-MM-DD-hh-mm-ss 区域属性bit0为0时没有该字段
+		StartTime string `json:"startTime"`
+		// EndTime 结束时间 YY-MM-DD-hh-mm-ss 区域属性bit0为0时没有该字段
+		EndTime string `json:"endTime"`
+		// MaxSpeed 最高速度 单位为千米每小时(km/h) 区域属性bit1为0时没有该字段
+		MaxSpeed uint16 `json:"maxSpeed"`
+		// OverSpeedDuration 超速持续时间 单位为秒(s) 区域属性bit1为0时没有该字段
+		OverSpeedDuration byte `json:"overSpeedDuration"`
+		// VertexTotal 区域总顶点数
+		VertexTotal uint16 `json:"vertexTotal"`
+		// Vertexes 顶点项
+		Vertexes []AreaVertex `json:"vertexes"`
+		// NightMaxSpeed 夜间最高速度 2019版本增加的 区域属性bit1为0时没有该字段
+		NightMaxSpeed uint16 `json:"nightMaxSpeed"`
+		// NameLen 区域名称长度 2019版本增加的
+		NameLen uint16 `json:"nameLen"`
+		// Name 区域名称 2019版本增加的
+		Name string `json:"name"`
+		// AreaAttributeDetails 区域属性详情
+		AreaAttributeDetails `json:"areaAttributeDetails"`
+	}
+
+	// AreaVertex 多边形区域顶点.
+	AreaVertex struct {
+		// Latitude 顶点纬度
+		Latitude uint32 `json:"latitude"`
+		// Longitude 顶点经度
+		Longitude uint32 `json:"longitude"`
+	}
+
+	// AreaRoute 路线 见表64.
+	AreaRoute struct {
+		// ID 路线ID
+		ID uint32 `json:"id"`
+		// Attribute 路线属性 只有bit0和bit2-bit5 见AreaAttributeDetails
+		Attribute uint16 `json:"attribute"`
+		// StartTime 起始时间 路线属性bit0为0时没有该字段
+		StartTime string `json:"startTime"`
+		// EndTime 结束时间 路线属性bit0为0时没有该字段
+		EndTime string `json:"endTime"`
+		// PointTotal 路线总拐点数
+		PointTotal uint16 `json:"pointTotal"`
+		// Points 拐点项
+		Points []AreaRoutePoint `json:"points"`
+		// NameLen 路线名称长度 2019版本增加的
+		NameLen uint16 `json:"nameLen"`
+		// Name 路线名称 2019版本增加的
+		Name string `json:"name"`
+		// AreaAttributeDetails 路线属性详情
+		AreaAttributeDetails `json:"areaAttributeDetails"`
+	}
+
+	// AreaRoutePoint 路线拐点 见表66.
+	AreaRoutePoint struct {
+		// ID 拐点ID
+		ID uint32 `json:"id"`
+		// SegmentID 路段ID
+		SegmentID uint32 `json:"segmentID"`
+		// Latitude 拐点纬度
+		Latitude uint32 `json:"latitude"`
+		// Longitude 拐点经度
+		Longitude uint32 `json:"longitude"`
+		// SegmentWidth 路段宽度 单位为米(m) 路段为该拐点到下一拐点
+		SegmentWidth byte `json:"segmentWidth"`
+		// SegmentAttribute 路段属性 见AreaRouteSegmentDetails
+		SegmentAttribute byte `json:"segmentAttribute"`
+		// OverLongThreshold 路段行驶过长阈值 单位为秒(s) 路段属性bit0为0时没有该字段
+		OverLongThreshold uint16 `json:"overLongThreshold"`
+		// InsufficientThreshold 路段行驶不足阈值 单位为秒(s) 路段属性bit0为0时没有该字段
+		InsufficientThreshold uint16 `json:"insufficientThreshold"`
+		// MaxSpeed 路段最高速度 单位为千米每小时(km/h) 路段属性bit1为0时没有该字段
+		MaxSpeed uint16 `json:"maxSpeed"`
+		// OverSpeedDuration 路段超速持续时间 单位为秒(s) 路段属性bit1为0时没有该字段
+		OverSpeedDuration byte `json:"overSpeedDuration"`
+		// NightMaxSpeed 路段夜间最高速度 2019版本增加的 路段属性bit1为0时没有该字段
+		NightMaxSpeed uint16 `json:"nightMaxSpeed"`
+		// AreaRouteSegmentDetails 路段属性详情
+		AreaRouteSegmentDetails `json:"areaRouteSegmentDetails"`
+	}
+
+	// AreaLimit 圆形和矩形区域公共的时间 限速 名称部分.
+	AreaLimit struct {
+		// StartTime 起始时间 YY-MM-DD-hh-mm-ss 区域属性bit0为0时没有该字段
+		StartTime string `json:"startTime"`
+		// EndTime 结束时间 YY-MM-DD-hh-mm-ss 区域属性bit0为0时没有该字段
+		EndTime string `json:"endTime"`
+		// MaxSpeed 最高速度 单位为千米每小时(km/h) 区域属性bit1为0时没有该字段
+		MaxSpeed uint16 `json:"maxSpeed"`
+		// OverSpeedDuration 超速持续时间 单位为秒(s) 区域属性bit1为0时没有该字段
+		OverSpeedDuration byte `json:"overSpeedDuration"`
+		// NightMaxSpeed 夜间最高速度 2019版本增加的 区域属性bit1为0时没有该字段
+		NightMaxSpeed uint16 `json:"nightMaxSpeed"`
+		// NameLen 区域名称长度 2019版本增加的
+		NameLen uint16 `json:"nameLen"`
+		// Name 区域名称 2019版本增加的
+		Name string `json:"name"`
+		// AreaAttributeDetails 区域属性详情
+		AreaAttributeDetails `json:"areaAttributeDetails"`
+	}
+
+	// AreaAttributeDetails 区域属性 见表58.
+	AreaAttributeDetails struct {
+		// ByTime bit0 1-根据时间
+		ByTime bool `json:"byTime"`
+		// SpeedLimit bit1 1-限速 路线的限速在路段属性中
+		SpeedLimit bool `json:"speedLimit"`
+		// EnterAlarmDriver bit2 1-进区域报警给驾驶员
+		EnterAlarmDriver bool `json:"enterAlarmDriver"`
+		// EnterAlarmPlatform bit3 1-进区域报警给平台
+		EnterAlarmPlatform bool `json:"enterAlarmPlatform"`
+		// LeaveAlarmDriver bit4 1-出区域报警给驾驶员
+		LeaveAlarmDriver bool `json:"leaveAlarmDriver"`
+		// LeaveAlarmPlatform bit5 1-出区域报警给平台
+		LeaveAlarmPlatform bool `json:"leaveAlarmPlatform"`
+		// SouthLatitude bit6 0-北纬 1-南纬
+		SouthLatitude bool `json:"southLatitude"`
+		// WestLongitude bit7 0-东经 1-西经
+		WestLongitude bool `json:"westLongitude"`
+		// ForbidOpenDoor bit8 0-允许开门 1-禁止开门
+		ForbidOpenDoor bool `json:"forbidOpenDoor"`
+		// CloseCommunication bit14 0-进区域开启通信模块 1-进区域关闭通信模块
+		CloseCommunication bool `json:"closeCommunication"`
+		// CollectGNSS bit15 0-进区域不采集GNSS详细定位数据 1-进区域采集GNSS详细定位数据
+		CollectGNSS bool `json:"collectGNSS"`
+	}
+
+	// AreaRouteSegmentDetails 路段属性 见表67.
+	AreaRouteSegmentDetails struct {
+		// TravelTime bit0 1-行驶时间
+		TravelTime bool `json:"travelTime"`
+		// SpeedLimit bit1 1-限速
+		SpeedLimit bool `json:"speedLimit"`
+		// SouthLatitude bit2 0-北纬 1-南纬
+		SouthLatitude bool `json:"southLatitude"`
+		// WestLongitude bit3 0-东经 1-西经
+		WestLongitude bool `json:"westLongitude"`
+	}
+
+	// areaReader 区域和路线有很多根据属性决定是否存在的字段 按顺序读取 长度不足时记录错误.
+	areaReader struct {
+		data []byte
+		err  error
+	}
+)
+
+func (a *AreaCircle) parse(r *areaReader, version consts.ProtocolVersionType) {
+	a.ID = r.uint32()
+	a.Attribute = r.uint16()
+	a.Latitude = r.uint32()
+	a.Longitude = r.uint32()
+	a.Radius = r.uint32()
+	a.AreaLimit.parse(r, a.Attribute, version)
+}
+
+func (a *AreaCircle) encode(data []byte, version consts.ProtocolVersionType) []byte {
+	if a.Attribute == 0 {
+		a.Attribute = a.AreaAttributeDetails.toAttribute()
+	}
+	data = binary.BigEndian.AppendUint32(data, a.ID)
+	data = binary.BigEndian.AppendUint16(data, a.Attribute)
+	data = binary.BigEndian.AppendUint32(data, a.Latitude)
+	data = binary.BigEndian.AppendUint32(data, a.Longitude)
+	data = binary.BigEndian.AppendUint32(data, a.Radius)
+	return a.AreaLimit.encode(data, a.Attribute, version)
+}
+
+func (a *AreaCircle) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t[%08x] 区域ID:[%d]", a.ID, a.ID),
+		fmt.Sprintf("\t[%04x] 区域属性:[%d]", a.Attribute, a.Attribute),
+		a.AreaAttributeDetails.String(),
+		fmt.Sprintf("\t[%08x] 中心点纬度:[%d]", a.Latitude, a.Latitude),
+		fmt.Sprintf("\t[%08x] 中心点经度:[%d]", a.Longitude, a.Longitude),
+		fmt.Sprintf("\t[%08x] 半径:[%d]", a.Radius, a.Radius),
+		a.AreaLimit.String(),
+	}, "\n")
+}
+
+func (a *AreaRect) parse(r *areaReader, version consts.ProtocolVersionType) {
+	a.ID = r.uint32()
+	a.Attribute = r.uint16()
+	a.TopLeftLatitude = r.uint32()
+	a.TopLeftLongitude = r.uint32()
+	a.BottomRightLatitude = r.uint32()
+	a.BottomRightLongitude = r.uint32()
+	a.AreaLimit.parse(r, a.Attribute, version)
+}
+
+func (a *AreaRect) encode(data []byte, version consts.ProtocolVersionType) []byte {
+	if a.Attribute == 0 {
+		a.Attribute = a.AreaAttributeDetails.toAttribute()
+	}
+	data = binary.BigEndian.AppendUint32(data, a.ID)
+	data = binary.BigEndian.AppendUint16(data, a.Attribute)
+	data = binary.BigEndian.AppendUint32(data, a.TopLeftLatitude)
+	data = binary.BigEndian.AppendUint32(data, a.TopLeftLongitude)
+	data = binary.BigEndian.AppendUint32(data, a.BottomRightLatitude)
+	data = binary.BigEndian.AppendUint32(data, a.BottomRightLongitude)
+	return a.AreaLimit.encode(data, a.Attribute, version)
+}
+
+func (a *AreaRect) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t[%08x] 区域ID:[%d]", a.ID, a.ID),
+		fmt.Sprintf("\t[%04x] 区域属性:[%d]", a.Attribute, a.Attribute),
+		a.AreaAttributeDetails.String(),
+		fmt.Sprintf("\t[%08x] 左上点纬度:[%d]", a.TopLeftLatitude, a.TopLeftLatitude),
+		fmt.Sprintf("\t[%08x] 左上点经度:[%d]", a.TopLeftLongitude, a.TopLeftLongitude),
+		fmt.Sprintf("\t[%08x] 右下点纬度:[%d]", a.BottomRightLatitude, a.BottomRightLatitude),
+		fmt.Sprintf("\t[%08x] 右下点经度:[%d]", a.BottomRightLongitude, a.BottomRightLongitude),
+		a.AreaLimit.String(),
+	}, "\n")
+}
+
+func (a *AreaPolygon) parse(r *areaReader, version consts.ProtocolVersionType) {
+	a.ID = r.uint32()
+	a.Attribute = r.uint16()
+	a.AreaAttributeDetails.parse(a.Attribute)
+	if a.ByTime {
+		a.StartTime = r.time()
+		a.EndTime = r.time()
+	}
+	if a.SpeedLimit {
+		a.MaxSpeed = r.uint16()
+		a.OverSpeedDuration = r.byte()
+	}
+	a.VertexTotal = r.uint16()
+	a.Vertexes = make([]AreaVertex, 0, min(int(a.VertexTotal), len(r.data)/8))
+	for i := 0; i < int(a.VertexTotal) && r.err == nil; i++ {
+		a.Vertexes = append(a.Vertexes, AreaVertex{
+			Latitude:  r.uint32(),
+			Longitude: r.uint32(),
+		})
+	}
+	if version == consts.JT808Protocol2019 {
+		if a.SpeedLimit {
+			a.NightMaxSpeed = r.uint16()
+		}
+		a.NameLen, a.Name = r.name()
+	}
+}
+
+func (a *AreaPolygon) encode(data []byte, version consts.ProtocolVersionType) []byte {
+	if a.Attribute == 0 {
+		a.Attribute = a.AreaAttributeDetails.toAttribute()
+	}
+	data = binary.BigEndian.AppendUint32(data, a.ID)
+	data = binary.BigEndian.AppendUint16(data, a.Attribute)
+	if a.Attribute&(1<<0) > 0 {
+		data = append(data, areaTime2BCD(a.StartTime)...)
+		data = append(data, areaTime2BCD(a.EndTime)...)
+	}
+	if a.Attribute&(1<<1) > 0 {
+		data = binary.BigEndian.AppendUint16(data, a.MaxSpeed)
+		data = append(data, a.OverSpeedDuration)
+	}
+	a.VertexTotal = uint16(len(a.Vertexes))
+	data = binary.BigEndian.AppendUint16(data, a.VertexTotal)
+	for _, v := range a.Vertexes {
+		data = binary.BigEndian.AppendUint32(data, v.Latitude)
+		data = binary.BigEndian.AppendUint32(data, v.Longitude)
+	}
+	if version == consts.JT808Protocol2019 {
+		if a.Attribute&(1<<1) > 0 {
+			data = binary.BigEndian.AppendUint16(data, a.NightMaxSpeed)
+		}
+		data = appendAreaName(data, &a.NameLen, a.Name)
+	}
+	return data
+}
+
+func (a *AreaPolygon) String() string {
+	str := "\t顶点项列表:"
+	for _, v := range a.Vertexes {
+		str += fmt.Sprintf("\n\t\t[%08x] 顶点纬度:[%d] [%08x] 顶点经度:[%d]", v.Latitude, v.Latitude, v.Longitude, v.Longitude)
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("\t[%08x] 区域ID:[%d]", a.ID, a.ID),
+		fmt.Sprintf("\t[%04x] 区域属性:[%d]", a.Attribute, a.Attribute),
+		a.AreaAttributeDetails.String(),
+		fmt.Sprintf("\t[%012x] 起始时间:[%s]", areaTime2BCD(a.StartTime), a.StartTime),
+		fmt.Sprintf("\t[%012x] 结束时间:[%s]", areaTime2BCD(a.EndTime), a.EndTime),
+		fmt.Sprintf("\t[%04x] 最高速度:[%d]", a.MaxSpeed, a.MaxSpeed),
+		fmt.Sprintf("\t[%02x] 超速持续时间:[%d]", a.OverSpeedDuration, a.OverSpeedDuration),
+		fmt.Sprintf("\t[%04x] 区域总顶点数:[%d]", a.VertexTotal, a.VertexTotal),
+		str,
+		fmt.Sprintf("\t[%04x] 夜间最高速度:[%d]", a.NightMaxSpeed, a.NightMaxSpeed),
+		fmt.Sprintf("\t[%04x] 区域名称长度:[%d]", a.NameLen, a.NameLen),
+		fmt.Sprintf("\t[%x] 区域名称:[%s]", utils.UTF82GBK([]byte(a.Name)), a.Name),
+	}, "\n")
+}
+
+func (a *AreaRoute) parse(r *areaReader, version consts.ProtocolVersionType) {
+	a.ID = r.uint32()
+	a.Attribute = r.uint16()
+	a.AreaAttributeDetails.parse(a.Attribute)
+	if a.ByTime {
+		a.StartTime = r.time()
+		a.EndTime = r.time()
+	}
+	a.PointTotal = r.uint16()
+	a.Points = make([]AreaRoutePoint, 0, min(int(a.PointTotal), len(r.data)/18))
+	for i := 0; i < int(a.PointTotal) && r.err == nil; i++ {
+		point := AreaRoutePoint{}
+		point.parse(r, version)
+		a.Points = append(a.Points, point)
+	}
+	if version == consts.JT808Protocol2019 {
+		a.NameLen, a.Name = r.name()
+	}
+}
+
+func (a *AreaRoute) encode(data []byte, version consts.ProtocolVersionType) []byte {
+	if a.Attribute == 0 {
+		a.Attribute = a.AreaAttributeDetails.toAttribute()
+	}
+	data = binary.BigEndian.AppendUint32(data, a.ID)
+	data = binary.BigEndian.AppendUint16(data, a.Attribute)
+	if a.Attribute&(1<<0) > 0 {
+		data = append(data, areaTime2BCD(a.StartTime)...)
+		data = append(data, areaTime2BCD(a.EndTime)...)
+	}
+	a.PointTotal = uint16(len(a.Points))
+	data = binary.BigEndian.AppendUint16(data, a.PointTotal)
+	for i := range a.Points {
+		data = a.Points[i].encode(data, version)
+	}
+	if version == consts.JT808Protocol2019 {
+		data = appendAreaName(data, &a.NameLen, a.Name)
+	}
+	return data
+}
+
+func (a *AreaRoute) String() string {
+	str := "\t拐点项列表:"
+	for _, v := range a.Points {
+		str += "\n" + v.String()
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("\t[%08x] 路线ID:[%d]", a.ID, a.ID),
+		fmt.Sprintf("\t[%04x] 路线属性:[%d]", a.Attribute, a.Attribute),
+		a.AreaAttributeDetails.String(),
+		fmt.Sprintf("\t[%012x] 起始时间:[%s]", areaTime2BCD(a.StartTime), a.StartTime),
+		fmt.Sprintf("\t[%012x] 结束时间:[%s]", areaTime2BCD(a.EndTime), a.EndTime),
+		fmt.Sprintf("\t[%04x] 路线总拐点数:[%d]", a.PointTotal, a.PointTotal),
+		str,
+		fmt.Sprintf("\t[%04x] 路线名称长度:[%d]", a.NameLen, a.NameLen),
+		fmt.Sprintf("\t[%x] 路线名称:[%s]", utils.UTF82GBK([]byte(a.Name)), a.Name),
+	}, "\n")
+}
+
+func (a *AreaRoutePoint) parse(r *areaReader, version consts.ProtocolVersionType) {
+	a.ID = r.uint32()
+	a.SegmentID = r.uint32()
+	a.Latitude = r.uint32()
+	a.Longitude = r.uint32()
+	a.SegmentWidth = r.byte()
+	a.SegmentAttribute = r.byte()
+	a.AreaRouteSegmentDetails.parse(a.SegmentAttribute)
+	if a.TravelTime {
+		a.OverLongThreshold = r.uint16()
+		a.InsufficientThreshold = r.uint16()
+	}
+	if a.SpeedLimit {
+		a.MaxSpeed = r.uint16()
+		a.OverSpeedDuration = r.byte()
+		if version == consts.JT808Protocol2019 {
+			a.NightMaxSpeed = r.uint16()
+		}
+	}
+}
+
+func (a *AreaRoutePoint) encode(data []byte, version consts.ProtocolVersionType) []byte {
+	if a.SegmentAttribute == 0 {
+		a.SegmentAttribute = a.AreaRouteSegmentDetails.toAttribute()
+	}
+	data = binary.BigEndian.AppendUint32(data, a.ID)
+	data = binary.BigEndian.AppendUint32(data, a.SegmentID)
+	data = binary.BigEndian.AppendUint32(data, a.Latitude)
+	data = binary.BigEndian.AppendUint32(data, a.Longitude)
+	data = append(data, a.SegmentWidth, a.SegmentAttribute)
+	if a.SegmentAttribute&(1<<0) > 0 {
+		data = binary.BigEndian.AppendUint16(data, a.OverLongThreshold)
+		data = binary.BigEndian.AppendUint16(data, a.InsufficientThreshold)
+	}
+	if a.SegmentAttribute&(1<<1) > 0 {
+		data = binary.BigEndian.AppendUint16(data, a.MaxSpeed)
+		data = append(data, a.OverSpeedDuration)
+		if version == consts.JT808Protocol2019 {
+			data = binary.BigEndian.AppendUint16(data, a.NightMaxSpeed)
+		}
+	}
+	return data
+}
+
+func (a *AreaRoutePoint) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t[%08x] 拐点ID:[%d]", a.ID, a.ID),
+		fmt.Sprintf("\t\t[%08x] 路段ID:[%d]", a.SegmentID, a.SegmentID),
+		fmt.Sprintf("\t\t[%08x] 拐点纬度:[%d]", a.Latitude, a.Latitude),
+		fmt.Sprintf("\t\t[%08x] 拐点经度:[%d]", a.Longitude, a.Longitude),
+		fmt.Sprintf("\t\t[%02x] 路段宽度:[%d]", a.SegmentWidth, a.SegmentWidth),
+		fmt.Sprintf("\t\t[%02x] 路段属性:[%d]", a.SegmentAttribute, a.SegmentAttribute),
+		a.AreaRouteSegmentDetails.String(),
+		fmt.Sprintf("\t\t[%04x] 路段行驶过长阈值:[%d]", a.OverLongThreshold, a.OverLongThreshold),
+		fmt.Sprintf("\t\t[%04x] 路段行驶不足阈值:[%d]", a.InsufficientThreshold, a.InsufficientThreshold),
+		fmt.Sprintf("\t\t[%04x] 路段最高速度:[%d]", a.MaxSpeed, a.MaxSpeed),
+		fmt.Sprintf("\t\t[%02x] 路段超速持续时间:[%d]", a.OverSpeedDuration, a.OverSpeedDuration),
+		fmt.Sprintf("\t\t[%04x] 路段夜间最高速度:[%d]", a.NightMaxSpeed, a.NightMaxSpeed),
+	}, "\n")
+}
+
+func (a *AreaLimit) parse(r *areaReader, attribute uint16, version consts.ProtocolVersionType) {
+	a.AreaAttributeDetails.parse(attribute)
+	if a.ByTime {
+		a.StartTime = r.time()
+		a.EndTime = r.time()
+	}
+	if a.SpeedLimit {
+		a.MaxSpeed = r.uint16()
+		a.OverSpeedDuration = r.byte()
+		if version == consts.JT808Protocol2019 {
+			a.NightMaxSpeed = r.uint16()
+		}
+	}
+	if version == consts.JT808Protocol2019 {
+		a.NameLen, a.Name = r.name()
+	}
+}
+
+func (a *AreaLimit) encode(data []byte, attribute uint16, version consts.ProtocolVersionType) []byte {
+	if attribute&(1<<0) > 0 {
+		data = append(data, areaTime2BCD(a.StartTime)...)
+		data = append(data, areaTime2BCD(a.EndTime)...)
+	}
+	if attribute&(1<<1) > 0 {
+		data = binary.BigEndian.AppendUint16(data, a.MaxSpeed)
+		data = append(data, a.OverSpeedDuration)
+		if version == consts.JT808Protocol2019 {
+			data = binary.BigEndian.AppendUint16(data, a.NightMaxSpeed)
+		}
+	}
+	if version == consts.JT808Protocol2019 {
+		data = appendAreaName(data, &a.NameLen, a.Name)
+	}
+	return data
+}
+
+func (a *AreaLimit) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t[%012x] 起始时间:[%s]", areaTime2BCD(a.StartTime), a.StartTime),
+		fmt.Sprintf("\t[%012x] 结束时间:[%s]", areaTime2BCD(a.EndTime), a.EndTime),
+		fmt.Sprintf("\t[%04x] 最高速度:[%d]", a.MaxSpeed, a.MaxSpeed),
+		fmt.Sprintf("\t[%02x] 超速持续时间:[%d]", a.OverSpeedDuration, a.OverSpeedDuration),
+		fmt.Sprintf("\t[%04x] 夜间最高速度:[%d]", a.NightMaxSpeed, a.NightMaxSpeed),
+		fmt.Sprintf("\t[%04x] 区域名称长度:[%d]", a.NameLen, a.NameLen),
+		fmt.Sprintf("\t[%x] 区域名称:[%s]", utils.UTF82GBK([]byte(a.Name)), a.Name),
+	}, "\n")
+}
+
+func (d *AreaAttributeDetails) parse(attribute uint16) {
+	d.ByTime = attribute&(1<<0) > 0
+	d.SpeedLimit = attribute&(1<<1) > 0
+	d.EnterAlarmDriver = attribute&(1<<2) > 0
+	d.EnterAlarmPlatform = attribute&(1<<3) > 0
+	d.LeaveAlarmDriver = attribute&(1<<4) > 0
+	d.LeaveAlarmPlatform = attribute&(1<<5) > 0
+	d.SouthLatitude = attribute&(1<<6) > 0
+	d.WestLongitude = attribute&(1<<7) > 0
+	d.ForbidOpenDoor = attribute&(1<<8) > 0
+	d.CloseCommunication = attribute&(1<<14) > 0
+	d.CollectGNSS = attribute&(1<<15) > 0
+}
+
+func (d *AreaAttributeDetails) toAttribute() uint16 {
+	attribute := uint16(0)
+	for i, v := range []bool{
+		d.ByTime, d.SpeedLimit, d.EnterAlarmDriver, d.EnterAlarmPlatform,
+		d.LeaveAlarmDriver, d.LeaveAlarmPlatform, d.SouthLatitude, d.WestLongitude, d.ForbidOpenDoor,
+	} {
+		if v {
+			attribute |= 1 << i
+		}
+	}
+	if d.CloseCommunication {
+		attribute |= 1 << 14
+	}
+	if d.CollectGNSS {
+		attribute |= 1 << 15
+	}
+	return attribute
+}
+
+func (d *AreaAttributeDetails) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t[bit0]根据时间:[%t]", d.ByTime),
+		fmt.Sprintf("\t\t[bit1]限速:[%t]", d.SpeedLimit),
+		fmt.Sprintf("\t\t[bit2]进区域报警给驾驶员:[%t]", d.EnterAlarmDriver),
+		fmt.Sprintf("\t\t[bit3]进区域报警给平台:[%t]", d.EnterAlarmPlatform),
+		fmt.Sprintf("\t\t[bit4]出区域报警给驾驶员:[%t]", d.LeaveAlarmDriver),
+		fmt.Sprintf("\t\t[bit5]出区域报警给平台:[%t]", d.LeaveAlarmPlatform),
+		fmt.Sprintf("\t\t[bit6]南纬:[%t] false-北纬 true-南纬", d.SouthLatitude),
+		fmt.Sprintf("\t\t[bit7]西经:[%t] false-东经 true-西经", d.WestLongitude),
+		fmt.Sprintf("\t\t[bit8]禁止开门:[%t]", d.ForbidOpenDoor),
+		fmt.Sprintf("\t\t[bit14]进区域关闭通信模块:[%t]", d.CloseCommunication),
+		fmt.Sprintf("\t\t[bit15]进区域采集GNSS详细定位数据:[%t]", d.CollectGNSS),
+	}, "\n")
+}
+
+func (d *AreaRouteSegmentDetails) parse(attribute byte) {
+	d.TravelTime = attribute&(1<<0) > 0
+	d.SpeedLimit = attribute&(1<<1) > 0
+	d.SouthLatitude = attribute&(1<<2) > 0
+	d.WestLongitude = attribute&(1<<3) > 0
+}
+
+func (d *AreaRouteSegmentDetails) toAttribute() byte {
+	attribute := byte(0)
+	for i, v := range []bool{d.TravelTime, d.SpeedLimit, d.SouthLatitude, d.WestLongitude} {
+		if v {
+			attribute |= 1 << i
+		}
+	}
+	return attribute
+}
+
+func (d *AreaRouteSegmentDetails) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t\t[bit0]行驶时间:[%t]", d.TravelTime),
+		fmt.Sprintf("\t\t\t[bit1]限速:[%t]", d.SpeedLimit),
+		fmt.Sprintf("\t\t\t[bit2]南纬:[%t] false-北纬 true-南纬", d.SouthLatitude),
+		fmt.Sprintf("\t\t\t[bit3]西经:[%t] false-东经 true-西经", d.WestLongitude),
+	}, "\n")
+}
+
+func (r *areaReader) next(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = protocol.ErrBodyLengthInconsistency
+		return make([]byte, n)
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *areaReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *areaReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *areaReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *areaReader) time() string {
+	return utils.BCD2Time(r.next(6))
+}
+
+func (r *areaReader) name() (uint16, string) {
+	nameLen := r.uint16()
+	return nameLen, string(utils.GBK2UTF8(r.next(int(nameLen))))
+}
+
+// finish 数据体全部读取完成 多余或者不足都是长度不一致.
+func (r *areaReader) finish() error {
+	if r.err == nil && len(r.data) != 0 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	return r.err
+}
+
+// areaTime2BCD 没有设置时间的 使用全0填充.
+func areaTime2BCD(t string) []byte {
+	if bcd := utils.Time2BCD(t); len(bcd) == 6 {
+		return bcd
+	}
+	return make([]byte, 6)
+}
+
+func appendAreaName(data []byte, nameLen *uint16, name string) []byte {
+	gbk := utils.UTF82GBK([]byte(name))
+	*nameLen = uint16(len(gbk))
+	data = binary.BigEndian.AppendUint16(data, *nameLen)
+	return append(data, gbk...)
+}
+
+// parseAreaIDs 删除圆形 矩形 多边形区域和路线的消息体格式一样.
+func parseAreaIDs(body []byte) (byte, []uint32, error) {
+	if len(body) < 1 || len(body) != 1+4*int(body[0]) {
+		return 0, nil, protocol.ErrBodyLengthInconsistency
+	}
+	ids := make([]uint32, 0, body[0])
+	for i := 1; i < len(body); i += 4 {
+		ids = append(ids, binary.BigEndian.Uint32(body[i:i+4]))
+	}
+	return body[0], ids, nil
+}
+
+func encodeAreaIDs(ids []uint32) []byte {
+	data := make([]byte, 1, 1+4*len(ids))
+	data[0] = byte(len(ids))
+	for _, id := range ids {
+		data = binary.BigEndian.AppendUint32(data, id)
+	}
+	return data
+}
+
+func areaIDsString(ids []uint32) string {
+	str := "\tID列表:"
+	for _, v := range ids {
+		str += fmt.Sprintf("\n\t[%08x] ID:[%d]", v, v)
+	}
+	return str
+}
+
+// areaVersion 2019版本增加了夜间最高速度和名称 其他按照2013版本.
+func areaVersion(jtMsg *jt808.JTMessage) consts.ProtocolVersionType {
+	if jtMsg.Header.ProtocolVersion == consts.JT808Protocol2019 {
+		return consts.JT808Protocol2019
+	}
+	return consts.JT808Protocol2013
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -9,6 +12,7 @@ import (
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
 	"math"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -338,6 +342,238 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "T0x0108 终端-升级结果通知",
+			args: args{
+				msg:      "7e0108000201234567890100010001837e",
+				Handler:  &T0x0108{},
+				bodyLens: []int{1},
+			},
+			fields: &T0x0108{
+				UpgradeType: 0,
+				Result:      1,
+			},
+		},
+		{
+			name: "T0x0107 终端-查询终端属性应答",
+			args: args{
+				msg:      "7e010700390123456789010000004541424344454a543830382d4d4f44454c00000000000000000031323334353637898600123456789012340456312e300556322e303103216f7e",
+				Handler:  &T0x0107{},
+				bodyLens: []int{20, 48, 56},
+			},
+			fields: &T0x0107{
+				TerminalType:          69,
+				ManufacturerID:        "ABCDE",
+				TerminalModel:         "JT808-MODEL",
+				TerminalID:            "1234567",
+				ICCID:                 "89860012345678901234",
+				HardwareVersionLen:    4,
+				HardwareVersion:       "V1.0",
+				FirmwareVersionLen:    5,
+				FirmwareVersion:       "V2.01",
+				GNSSProperty:          3,
+				CommunicationProperty: 33,
+				Version:               consts.JT808Protocol2013,
+				T0x0107TerminalTypeDetails: T0x0107TerminalTypeDetails{
+					PassengerVehicle: true,
+					FreightVehicle:   true,
+					HardDiskVideo:    true,
+				},
+				T0x0107GNSSDetails: T0x0107GNSSDetails{
+					GPS:    true,
+					BeiDou: true,
+				},
+				T0x0107CommunicationDetails: T0x0107CommunicationDetails{
+					GPRS:  true,
+					TDLTE: true,
+				},
+			},
+		},
+		{
+			name: "T0x0107 终端-查询终端属性应答 2019版本",
+			args: args{
+				msg:      "7e010740600100000000012345678901000001814142434445464748494a4b4a543830382d4d4f44454c2d323031390000000000000000000000000000494432303139000000000000000000000000000000000000000000000000898600123456789012340456312e300556322e30310321a37e",
+				Handler:  &T0x0107{},
+				bodyLens: []int{80, 95},
+			},
+			fields: &T0x0107{
+				TerminalType:          385,
+				ManufacturerID:        "ABCDEFGHIJK",
+				TerminalModel:         "JT808-MODEL-2019",
+				TerminalID:            "ID2019",
+				ICCID:                 "89860012345678901234",
+				HardwareVersionLen:    4,
+				HardwareVersion:       "V1.0",
+				FirmwareVersionLen:    5,
+				FirmwareVersion:       "V2.01",
+				GNSSProperty:          3,
+				CommunicationProperty: 33,
+				Version:               consts.JT808Protocol2019,
+				T0x0107TerminalTypeDetails: T0x0107TerminalTypeDetails{
+					PassengerVehicle: true,
+					SplitMachine:     true,
+					TrailerVehicle:   true,
+				},
+				T0x0107GNSSDetails: T0x0107GNSSDetails{
+					GPS:    true,
+					BeiDou: true,
+				},
+				T0x0107CommunicationDetails: T0x0107CommunicationDetails{
+					GPRS:  true,
+					TDLTE: true,
+				},
+			},
+		},
+		{
+			name: "T0x0608 终端-查询区域或路线数据应答 圆形区域",
+			args: args{
+				msg:      "7e0608403201000000000123456789010001010000000100000001000301c9c38007270e00000001f424010108000024123118000000500a003c0008b2e2cad4c7f8d3f2ab7e",
+				Handler:  &T0x0608{},
+				bodyLens: []int{4, 49},
+			},
+			fields: &T0x0608{
+				QueryType: 1,
+				Total:     1,
+				Circles: []AreaCircle{
+					{
+						ID:        1,
+						Attribute: 3,
+						Latitude:  30000000,
+						Longitude: 120000000,
+						Radius:    500,
+						AreaLimit: AreaLimit{
+							StartTime:         "2024-01-01 08:00:00",
+							EndTime:           "2024-12-31 18:00:00",
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							NightMaxSpeed:     60,
+							NameLen:           8,
+							Name:              "测试区域",
+							AreaAttributeDetails: AreaAttributeDetails{
+								ByTime:     true,
+								SpeedLimit: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
+		{
+			name: "T0x0608 终端-查询区域或路线数据应答 矩形区域",
+			args: args{
+				msg:      "7e0608001e0123456789010001020000000100000003010201d905c00736504001c9c3800745928000500a277e",
+				Handler:  &T0x0608{},
+				bodyLens: []int{29},
+			},
+			fields: &T0x0608{
+				QueryType: 2,
+				Total:     1,
+				Rects: []AreaRect{
+					{
+						ID:                   3,
+						Attribute:            258,
+						TopLeftLatitude:      31000000,
+						TopLeftLongitude:     121000000,
+						BottomRightLatitude:  30000000,
+						BottomRightLongitude: 122000000,
+						AreaLimit: AreaLimit{
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							AreaAttributeDetails: AreaAttributeDetails{
+								SpeedLimit:     true,
+								ForbidOpenDoor: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "T0x0608 终端-查询区域或路线数据应答 多边形区域",
+			args: args{
+				msg:      "7e060800280123456789010001030000000100000004000200500a000301c9c38007270e0001cb4a20072894a001ccd0c007270e001f7e",
+				Handler:  &T0x0608{},
+				bodyLens: []int{39},
+			},
+			fields: &T0x0608{
+				QueryType: 3,
+				Total:     1,
+				Polygons: []AreaPolygon{
+					{
+						ID:                4,
+						Attribute:         2,
+						MaxSpeed:          80,
+						OverSpeedDuration: 10,
+						VertexTotal:       3,
+						Vertexes: []AreaVertex{
+							{Latitude: 30000000, Longitude: 120000000},
+							{Latitude: 30100000, Longitude: 120100000},
+							{Latitude: 30200000, Longitude: 120000000},
+						},
+						AreaAttributeDetails: AreaAttributeDetails{
+							SpeedLimit: true,
+						},
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "T0x0608 终端-查询区域或路线数据应答 路线",
+			args: args{
+				msg:      "7e060840400100000000012345678901000104000000010000000500240002000000010000006401c9c38107270e0132030258003c005a0f0046000000020000006501c9c38207270e0232000004c2b7cfdfb57e",
+				Handler:  &T0x0608{},
+				bodyLens: []int{63},
+			},
+			fields: &T0x0608{
+				QueryType: 4,
+				Total:     1,
+				Routes: []AreaRoute{
+					{
+						ID:         5,
+						Attribute:  36,
+						PointTotal: 2,
+						Points: []AreaRoutePoint{
+							{
+								ID:                    1,
+								SegmentID:             100,
+								Latitude:              30000001,
+								Longitude:             120000001,
+								SegmentWidth:          50,
+								SegmentAttribute:      3,
+								OverLongThreshold:     600,
+								InsufficientThreshold: 60,
+								MaxSpeed:              90,
+								OverSpeedDuration:     15,
+								NightMaxSpeed:         70,
+								AreaRouteSegmentDetails: AreaRouteSegmentDetails{
+									TravelTime: true,
+									SpeedLimit: true,
+								},
+							},
+							{
+								ID:                      2,
+								SegmentID:               101,
+								Latitude:                30000002,
+								Longitude:               120000002,
+								SegmentWidth:            50,
+								SegmentAttribute:        0,
+								AreaRouteSegmentDetails: AreaRouteSegmentDetails{},
+							},
+						},
+						NameLen: 4,
+						Name:    "路线",
+						AreaAttributeDetails: AreaAttributeDetails{
+							EnterAlarmDriver:   true,
+							LeaveAlarmPlatform: true,
+						},
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
 		{
 			name: "P0x9003 平台-查询终端音视频属性",
 			args: args{
@@ -527,6 +763,499 @@ func TestParse(t *testing.T) {
 				UploadControl:       2,
 			},
 		},
+		{
+			name: "T0x0005 终端-补传分包请求",
+			args: args{
+				msg:      "7e000500070123456789017fff10990200010003837e",
+				Handler:  &T0x0005{},
+				bodyLens: []int{2, 4},
+			},
+			fields: &T0x0005{
+				OriginalSerialNumber: 4249,
+				AgainPackageCount:    2,
+				AgainPackageList:     []uint16{1, 3},
+			},
+		},
+		{
+			name: "T0x0A00 终端-RSA公钥",
+			args: args{
+				msg:      "7e0a0000840123456789017fff00010001abababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababab867e",
+				Handler:  &T0x0A00{},
+				bodyLens: []int{4},
+			},
+			fields: &T0x0A00{
+				E: 65537,
+				N: bytes.Repeat([]byte{0xab}, 128),
+			},
+		},
+		{
+			name: "P0x8A00 平台-RSA公钥",
+			args: args{
+				msg:      "7e8a000084012345678901000100010001abababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababab877e",
+				Handler:  &P0x8A00{},
+				bodyLens: []int{4},
+			},
+			fields: &P0x8A00{
+				E: 65537,
+				N: bytes.Repeat([]byte{0xab}, 128),
+			},
+		},
+		{
+			name: "P0x8105 平台-终端控制 无线升级",
+			args: args{
+				msg:      "7e81050049012345678901000101687474703a2f2f612e636e2f75702e62696e3b434d4e45543b757365723b7077643b3139322e3136382e312e313b383038303b3b41424344453b56312e303bb9ccbcfe56323b3330247e",
+				Handler:  &P0x8105{},
+				bodyLens: []int{0, 1, 40},
+			},
+			fields: &P0x8105{
+				CommandWord:   P8105WirelessUpgrade,
+				CommandParams: "http://a.cn/up.bin;CMNET;user;pwd;192.168.1.1;8080;;ABCDE;V1.0;固件V2;30",
+				UpgradeParam: &P0x8105UpgradeParam{
+					URL:              "http://a.cn/up.bin",
+					DialPointName:    "CMNET",
+					DialUserName:     "user",
+					DialPassword:     "pwd",
+					Address:          "192.168.1.1",
+					TCPPort:          8080,
+					ManufacturerID:   "ABCDE",
+					HardwareVersion:  "V1.0",
+					FirmwareVersion:  "固件V2",
+					ConnectTimeLimit: 30,
+				},
+			},
+		},
+		{
+			name: "P0x8105 平台-终端控制 连接指定服务器",
+			args: args{
+				msg:      "7e8105002b012345678901000102303bbcf8c8a8c2eb3132333b434d4e45543b3b3b3132372e302e302e313b373738383b373738393b3630547e",
+				Handler:  &P0x8105{},
+				bodyLens: []int{0, 1, 2},
+			},
+			fields: &P0x8105{
+				CommandWord:   P8105ConnectServer,
+				CommandParams: "0;鉴权码123;CMNET;;;127.0.0.1;7788;7789;60",
+				ConnectParam: &P0x8105ConnectParam{
+					ConnectControl:   0,
+					AuthCode:         "鉴权码123",
+					DialPointName:    "CMNET",
+					Address:          "127.0.0.1",
+					TCPPort:          7788,
+					UDPPort:          7789,
+					ConnectTimeLimit: 60,
+				},
+			},
+		},
+		{
+			name: "P0x8105 平台-终端控制 终端复位",
+			args: args{
+				msg:      "7e81050001012345678901000104087e",
+				Handler:  &P0x8105{},
+				bodyLens: []int{0},
+			},
+			fields: &P0x8105{
+				CommandWord: P8105Reset,
+			},
+		},
+		{
+			name: "P0x8108 平台-下发终端升级包",
+			args: args{
+				msg:      "7e8108001701234567890100010041424344450456312e32000000080102030405060708297e",
+				Handler:  &P0x8108{},
+				bodyLens: []int{6, 10, 14, 20},
+			},
+			fields: &P0x8108{
+				UpgradeType:    0,
+				ManufacturerID: "ABCDE",
+				VersionLen:     4,
+				Version:        "V1.2",
+				PackageLen:     8,
+				Package:        []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			},
+		},
+		{
+			name: "P0x8107 平台-查询终端属性",
+			args: args{
+				msg:      "7e81074000010000000001234567890100004f7e",
+				Handler:  &P0x8107{},
+				bodyLens: nil,
+			},
+			fields: &P0x8107{},
+		},
+		{
+			name: "P0x8106 平台-查询指定终端参数",
+			args: args{
+				msg:      "7e8106000d01234567890100010300000001000000130000005b497e",
+				Handler:  &P0x8106{},
+				bodyLens: []int{0, 9},
+			},
+			fields: &P0x8106{
+				ParamTotal:  3,
+				ParamIDList: []uint32{0x001, 0x013, 0x05b},
+			},
+		},
+		{
+			name: "P0x8600 平台-设置圆形区域",
+			args: args{
+				msg:      "7e860000230123456789010001010100000001000301c9c38007270e00000001f424010108000024123118000000500a177e",
+				Handler:  &P0x8600{},
+				bodyLens: []int{1, 20, 34},
+			},
+			fields: &P0x8600{
+				SetAttribute: 1,
+				AreaTotal:    1,
+				Areas: []AreaCircle{
+					{
+						ID:        1,
+						Attribute: 3,
+						Latitude:  30000000,
+						Longitude: 120000000,
+						Radius:    500,
+						AreaLimit: AreaLimit{
+							StartTime:         "2024-01-01 08:00:00",
+							EndTime:           "2024-12-31 18:00:00",
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							AreaAttributeDetails: AreaAttributeDetails{
+								ByTime:     true,
+								SpeedLimit: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "P0x8600 平台-设置圆形区域 2019版本",
+			args: args{
+				msg:      "7e8600404401000000000123456789010001000200000001000301c9c38007270e00000001f424010108000024123118000000500a003c0008b2e2cad4c7f8d3f200000002000c01c9c38007270e00000001f40001424a7e",
+				Handler:  &P0x8600{},
+				bodyLens: []int{30, 67},
+			},
+			fields: &P0x8600{
+				SetAttribute: 0,
+				AreaTotal:    2,
+				Areas: []AreaCircle{
+					{
+						ID:        1,
+						Attribute: 3,
+						Latitude:  30000000,
+						Longitude: 120000000,
+						Radius:    500,
+						AreaLimit: AreaLimit{
+							StartTime:         "2024-01-01 08:00:00",
+							EndTime:           "2024-12-31 18:00:00",
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							NightMaxSpeed:     60,
+							NameLen:           8,
+							Name:              "测试区域",
+							AreaAttributeDetails: AreaAttributeDetails{
+								ByTime:     true,
+								SpeedLimit: true,
+							},
+						},
+					},
+					{
+						ID:        2,
+						Attribute: 12,
+						Latitude:  30000000,
+						Longitude: 120000000,
+						Radius:    500,
+						AreaLimit: AreaLimit{
+							NameLen: 1,
+							Name:    "B",
+							AreaAttributeDetails: AreaAttributeDetails{
+								EnterAlarmDriver:   true,
+								EnterAlarmPlatform: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
+		{
+			name: "P0x8601 平台-删除圆形区域",
+			args: args{
+				msg:      "7e860100090123456789010001020000000100000002067e",
+				Handler:  &P0x8601{},
+				bodyLens: []int{0, 5},
+			},
+			fields: &P0x8601{
+				AreaTotal: 2,
+				AreaIDs:   []uint32{1, 2},
+			},
+		},
+		{
+			name: "P0x8602 平台-设置矩形区域",
+			args: args{
+				msg:      "7e8602001b0123456789010001020100000003010201d905c00736504001c9c3800745928000500aa87e",
+				Handler:  &P0x8602{},
+				bodyLens: []int{10, 26},
+			},
+			fields: &P0x8602{
+				SetAttribute: 2,
+				AreaTotal:    1,
+				Areas: []AreaRect{
+					{
+						ID:                   3,
+						Attribute:            258,
+						TopLeftLatitude:      31000000,
+						TopLeftLongitude:     121000000,
+						BottomRightLatitude:  30000000,
+						BottomRightLongitude: 122000000,
+						AreaLimit: AreaLimit{
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							AreaAttributeDetails: AreaAttributeDetails{
+								SpeedLimit:     true,
+								ForbidOpenDoor: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "P0x8602 平台-设置矩形区域 2019版本",
+			args: args{
+				msg:      "7e8602402f01000000000123456789010001010100000003010301d905c00736504001c9c3800745928024010108000024123118000000500a003c0004bed8d0ceac7e",
+				Handler:  &P0x8602{},
+				bodyLens: []int{46},
+			},
+			fields: &P0x8602{
+				SetAttribute: 1,
+				AreaTotal:    1,
+				Areas: []AreaRect{
+					{
+						ID:                   3,
+						Attribute:            259,
+						TopLeftLatitude:      31000000,
+						TopLeftLongitude:     121000000,
+						BottomRightLatitude:  30000000,
+						BottomRightLongitude: 122000000,
+						AreaLimit: AreaLimit{
+							StartTime:         "2024-01-01 08:00:00",
+							EndTime:           "2024-12-31 18:00:00",
+							MaxSpeed:          80,
+							OverSpeedDuration: 10,
+							NightMaxSpeed:     60,
+							NameLen:           4,
+							Name:              "矩形",
+							AreaAttributeDetails: AreaAttributeDetails{
+								ByTime:         true,
+								SpeedLimit:     true,
+								ForbidOpenDoor: true,
+							},
+						},
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
+		{
+			name: "P0x8603 平台-删除矩形区域",
+			args: args{
+				msg:      "7e86030005012345678901000101000000030b7e",
+				Handler:  &P0x8603{},
+				bodyLens: []int{4},
+			},
+			fields: &P0x8603{
+				AreaTotal: 1,
+				AreaIDs:   []uint32{3},
+			},
+		},
+		{
+			name: "P0x8604 平台-设置多边形区域",
+			args: args{
+				msg:      "7e86040023012345678901000100000004000200500a000301c9c38007270e0001cb4a20072894a001ccd0c007270e009a7e",
+				Handler:  &P0x8604{},
+				bodyLens: []int{20, 34},
+			},
+			fields: &P0x8604{
+				AreaPolygon: AreaPolygon{
+					ID:                4,
+					Attribute:         2,
+					MaxSpeed:          80,
+					OverSpeedDuration: 10,
+					VertexTotal:       3,
+					Vertexes: []AreaVertex{
+						{Latitude: 30000000, Longitude: 120000000},
+						{Latitude: 30100000, Longitude: 120100000},
+						{Latitude: 30200000, Longitude: 120000000},
+					},
+					AreaAttributeDetails: AreaAttributeDetails{
+						SpeedLimit: true,
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "P0x8604 平台-设置多边形区域 2019版本",
+			args: args{
+				msg:      "7e8604403401000000000123456789010001000000040001240101080000241231180000000301c9c38007270e0001cb4a20072894a001ccd0c007270e000006b6e0b1dfd0ce867e",
+				Handler:  &P0x8604{},
+				bodyLens: []int{51},
+			},
+			fields: &P0x8604{
+				AreaPolygon: AreaPolygon{
+					ID:          4,
+					Attribute:   1,
+					StartTime:   "2024-01-01 08:00:00",
+					EndTime:     "2024-12-31 18:00:00",
+					VertexTotal: 3,
+					Vertexes: []AreaVertex{
+						{Latitude: 30000000, Longitude: 120000000},
+						{Latitude: 30100000, Longitude: 120100000},
+						{Latitude: 30200000, Longitude: 120000000},
+					},
+					NameLen: 6,
+					Name:    "多边形",
+					AreaAttributeDetails: AreaAttributeDetails{
+						ByTime: true,
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
+		{
+			name: "P0x8605 平台-删除多边形区域",
+			args: args{
+				msg:      "7e860500010123456789010001000b7e",
+				Handler:  &P0x8605{},
+				bodyLens: []int{0},
+			},
+			fields: &P0x8605{
+				AreaTotal: 0,
+				AreaIDs:   []uint32{},
+			},
+		},
+		{
+			name: "P0x8606 平台-设置路线",
+			args: args{
+				msg:      "7e8606003f01234567890100010000000500012401010800002412311800000002000000010000006401c9c38107270e0132030258003c005a0f000000020000006501c9c38207270e023200317e",
+				Handler:  &P0x8606{},
+				bodyLens: []int{40, 62},
+			},
+			fields: &P0x8606{
+				AreaRoute: AreaRoute{
+					ID:         5,
+					Attribute:  1,
+					StartTime:  "2024-01-01 08:00:00",
+					EndTime:    "2024-12-31 18:00:00",
+					PointTotal: 2,
+					Points: []AreaRoutePoint{
+						{
+							ID:                    1,
+							SegmentID:             100,
+							Latitude:              30000001,
+							Longitude:             120000001,
+							SegmentWidth:          50,
+							SegmentAttribute:      3,
+							OverLongThreshold:     600,
+							InsufficientThreshold: 60,
+							MaxSpeed:              90,
+							OverSpeedDuration:     15,
+							AreaRouteSegmentDetails: AreaRouteSegmentDetails{
+								TravelTime: true,
+								SpeedLimit: true,
+							},
+						},
+						{
+							ID:                      2,
+							SegmentID:               101,
+							Latitude:                30000002,
+							Longitude:               120000002,
+							SegmentWidth:            50,
+							SegmentAttribute:        0,
+							AreaRouteSegmentDetails: AreaRouteSegmentDetails{},
+						},
+					},
+					AreaAttributeDetails: AreaAttributeDetails{
+						ByTime: true,
+					},
+				},
+				Version: consts.JT808Protocol2013,
+			},
+		},
+		{
+			name: "P0x8606 平台-设置路线 2019版本",
+			args: args{
+				msg:      "7e8606403b010000000001234567890100010000000500240002000000010000006401c9c38107270e0132030258003c005a0f0046000000020000006501c9c38207270e0232000004c2b7cfdf457e",
+				Handler:  &P0x8606{},
+				bodyLens: []int{58},
+			},
+			fields: &P0x8606{
+				AreaRoute: AreaRoute{
+					ID:         5,
+					Attribute:  36,
+					PointTotal: 2,
+					Points: []AreaRoutePoint{
+						{
+							ID:                    1,
+							SegmentID:             100,
+							Latitude:              30000001,
+							Longitude:             120000001,
+							SegmentWidth:          50,
+							SegmentAttribute:      3,
+							OverLongThreshold:     600,
+							InsufficientThreshold: 60,
+							MaxSpeed:              90,
+							OverSpeedDuration:     15,
+							NightMaxSpeed:         70,
+							AreaRouteSegmentDetails: AreaRouteSegmentDetails{
+								TravelTime: true,
+								SpeedLimit: true,
+							},
+						},
+						{
+							ID:                      2,
+							SegmentID:               101,
+							Latitude:                30000002,
+							Longitude:               120000002,
+							SegmentWidth:            50,
+							SegmentAttribute:        0,
+							AreaRouteSegmentDetails: AreaRouteSegmentDetails{},
+						},
+					},
+					NameLen: 4,
+					Name:    "路线",
+					AreaAttributeDetails: AreaAttributeDetails{
+						EnterAlarmDriver:   true,
+						LeaveAlarmPlatform: true,
+					},
+				},
+				Version: consts.JT808Protocol2019,
+			},
+		},
+		{
+			name: "P0x8607 平台-删除路线",
+			args: args{
+				msg:      "7e860700090123456789010001020000000500000006007e",
+				Handler:  &P0x8607{},
+				bodyLens: []int{8},
+			},
+			fields: &P0x8607{
+				RouteTotal: 2,
+				RouteIDs:   []uint32{5, 6},
+			},
+		},
+		{
+			name: "P0x8608 平台-查询区域或路线数据",
+			args: args{
+				msg:      "7e86084009010000000001234567890100010100000001000000014e7e",
+				Handler:  &P0x8608{},
+				bodyLens: []int{4, 8},
+			},
+			fields: &P0x8608{
+				QueryType: 1,
+				Total:     1,
+				IDs:       []uint32{1},
+			},
+		},
 		{
 			name: "P0x8003 平台-补发分包请求",
 			args: args{
@@ -1358,3 +2087,176 @@ func TestP9208AlarmSignEncode(t *testing.T) {
 		return
 	}
 }
+
+func TestRSAPublicKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p8a00 := &P0x8A00{}
+	if err := p8a00.SetPublicKey(&privateKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	t0a00 := &T0x0A00{}
+	if err := t0a00.Parse(&jt808.JTMessage{Body: p8a00.Encode()}); err != nil {
+		t.Fatal(err)
+	}
+	if !t0a00.PublicKey().Equal(&privateKey.PublicKey) {
+		t.Errorf("PublicKey() = %v\n want %v", t0a00.PublicKey(), privateKey.PublicKey)
+	}
+
+	// n固定128个字节 超过1024位的不支持
+	largeKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p8a00.SetPublicKey(&largeKey.PublicKey); !errors.Is(err, protocol.ErrRSAKeySize) {
+		t.Errorf("SetPublicKey() err = %v", err)
+	}
+}
+
+func TestT0x0102Version2011(t *testing.T) {
+	data, _ := hex.DecodeString("7e0102000c0123456789010001303132333435363738393031867e")
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	// 请求头解析的是2013版本 会话中根据注册消息判断是2011版本的
+	jtMsg.Header.ProtocolVersion = consts.JT808Protocol2011
+	handler := &T0x0102{}
+	if err := handler.Parse(jtMsg); err != nil {
+		t.Fatal(err)
+	}
+	if handler.Version != consts.JT808Protocol2011 || handler.AuthCode != "012345678901" {
+		t.Errorf("T0x0102 version=[%s] auth code=[%s]", handler.Version, handler.AuthCode)
+	}
+	if got := jtMsg.Header.Encode(nil); got[3]&0x40 != 0 {
+		t.Errorf("Encode() 2011 version bit14 = 1 [%x]", got)
+	}
+}
+
+func TestT0x0801Version2011(t *testing.T) {
+	data, _ := hex.DecodeString("7e0801000d01234567890100010000007b010201020d7b0d7b7b8d7e")
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	// 2013版本至少36个字节 2011版本没有位置基本信息
+	if err := (&T0x0801{}).Parse(jtMsg); !errors.Is(err, protocol.ErrBodyLengthInconsistency) {
+		t.Errorf("2013 Parse() err = %v", err)
+	}
+	jtMsg.Header.ProtocolVersion = consts.JT808Protocol2011
+	handler := &T0x0801{}
+	if err := handler.Parse(jtMsg); err != nil {
+		t.Fatal(err)
+	}
+	want := &T0x0801{
+		MultimediaID:           123,
+		MultimediaType:         1,
+		MultimediaFormatEncode: 2,
+		EventItemEncode:        1,
+		ChannelID:              2,
+		MultimediaPackage:      []byte{13, 123, 13, 123, 123},
+		Version:                consts.JT808Protocol2011,
+	}
+	if !reflect.DeepEqual(handler, want) {
+		t.Errorf("Parse() = %+v\n want %+v", handler, want)
+	}
+	if got := want.Encode(); !bytes.Equal(got, jtMsg.Body) {
+		t.Errorf("Encode() = %x\n want %x", got, jtMsg.Body)
+	}
+}
+
+func TestP0x8105Params(t *testing.T) {
+	for i := P8105CommandWord(0); i <= P8105CloseWirelessCommunication; i++ {
+		if i.String() == "" {
+			t.Errorf("P8105CommandWord(%d) String() is empty", i)
+		}
+	}
+	{
+		// 切换回原缺省监控平台服务器 没有后续参数
+		p := &P0x8105{CommandWord: P8105ConnectServer, ConnectParam: &P0x8105ConnectParam{ConnectControl: 1, AuthCode: "123"}}
+		if got := fmt.Sprintf("%x", p.Encode()); got != "0231" {
+			t.Errorf("P0x8105 Encode() = %s want 0231", got)
+		}
+	}
+	errParams := []struct {
+		commandWord P8105CommandWord
+		params      string
+	}{
+		{commandWord: P8105WirelessUpgrade, params: ";;;;;abc"},
+		{commandWord: P8105WirelessUpgrade, params: ";;;;;;abc"},
+		{commandWord: P8105WirelessUpgrade, params: ";;;;;;;;;;70000"},
+		{commandWord: P8105ConnectServer, params: "256"},
+		{commandWord: P8105ConnectServer, params: "0;;;;;;abc"},
+		{commandWord: P8105ConnectServer, params: "0;;;;;;;abc"},
+	}
+	for _, v := range errParams {
+		p := &P0x8105{CommandWord: v.commandWord, CommandParams: v.params}
+		if err := (&P0x8105{}).Parse(&jt808.JTMessage{Body: p.Encode()}); !errors.Is(err, protocol.ErrUnqualifiedData) {
+			t.Errorf("P0x8105 Parse() params=[%s] err[%v]", v.params, err)
+		}
+	}
+}
+
+func TestT0x0107ICCID(t *testing.T) {
+	tests := []struct {
+		iccid string
+		want  string
+	}{
+		{iccid: "12345678901234567890", want: "12345678901234567890"},
+		{iccid: "1234567890123", want: "00000001234567890123"},
+		{iccid: "8986001234567890123X", want: "00000000000000000000"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf("%x", iccid2BCD(tt.iccid)); got != tt.want {
+			t.Errorf("iccid2BCD(%s) = %s want %s", tt.iccid, got, tt.want)
+		}
+	}
+}
+
+func TestAreaAttribute(t *testing.T) {
+	details := AreaAttributeDetails{
+		ByTime: true, SpeedLimit: true, EnterAlarmDriver: true, EnterAlarmPlatform: true,
+		LeaveAlarmDriver: true, LeaveAlarmPlatform: true, SouthLatitude: true, WestLongitude: true,
+		ForbidOpenDoor: true, CloseCommunication: true, CollectGNSS: true,
+	}
+	segment := AreaRouteSegmentDetails{TravelTime: true, SpeedLimit: true, SouthLatitude: true, WestLongitude: true}
+	// 属性为0时 根据属性详情生成
+	p8600 := &P0x8600{Areas: []AreaCircle{{AreaLimit: AreaLimit{AreaAttributeDetails: details}}}}
+	p8602 := &P0x8602{Areas: []AreaRect{{AreaLimit: AreaLimit{AreaAttributeDetails: details}}}}
+	p8604 := &P0x8604{AreaPolygon: AreaPolygon{AreaAttributeDetails: details}}
+	p8606 := &P0x8606{AreaRoute: AreaRoute{
+		AreaAttributeDetails: details,
+		Points:               []AreaRoutePoint{{AreaRouteSegmentDetails: segment}},
+	}}
+	handlers := []interface {
+		Parse(jtMsg *jt808.JTMessage) error
+		Encode() []byte
+	}{p8600, p8602, p8604, p8606}
+	for _, handler := range handlers {
+		// 多余的数据
+		jtMsg := &jt808.JTMessage{Header: &jt808.Header{}, Body: append(handler.Encode(), 0x00)}
+		if err := handler.Parse(jtMsg); !errors.Is(err, protocol.ErrBodyLengthInconsistency) {
+			t.Errorf("%T Parse() extra data err[%v]", handler, err)
+		}
+	}
+	for _, attribute := range []uint16{p8600.Areas[0].Attribute, p8602.Areas[0].Attribute, p8604.Attribute, p8606.Attribute} {
+		if attribute != 0xc1ff {
+			t.Errorf("attribute = %04x want c1ff", attribute)
+		}
+	}
+	if p8606.Points[0].SegmentAttribute != 0x0f {
+		t.Errorf("segment attribute = %02x want 0f", p8606.Points[0].SegmentAttribute)
+	}
+	if err := (&P0x8602{}).Parse(&jt808.JTMessage{Header: &jt808.Header{}, Body: []byte{0}}); !errors.Is(err, protocol.ErrBodyLengthInconsistency) {
+		t.Errorf("P0x8602 Parse() err[%v]", err)
+	}
+	t0x0608 := &T0x0608{}
+	if err := t0x0608.Parse(&jt808.JTMessage{Header: &jt808.Header{}, Body: []byte{5, 0, 0, 0, 1}}); !errors.Is(err, protocol.ErrUnqualifiedData) {
+		t.Errorf("T0x0608 Parse() err[%v]", err)
+	}
+	if got := fmt.Sprintf("%x", (&T0x0608{QueryType: 5}).Encode()); got != "0500000000" {
+		t.Errorf("T0x0608 Encode() = %s", got)
+	}
+}
@@ -76,6 +76,24 @@ func TestReplyProtocol(t *testing.T) {
 			wantProtocol:      consts.T0104QueryParameter,
 			wantReplyProtocol: 0,
 		},
+		{
+			name:              "T0x0108 终端-升级结果通知",
+			args:              &T0x0108{},
+			wantProtocol:      consts.T0108UpgradeNotice,
+			wantReplyProtocol: consts.P8001GeneralRespond,
+		},
+		{
+			name:              "T0x0107 终端-查询终端属性应答",
+			args:              &T0x0107{},
+			wantProtocol:      consts.T0107QueryAttribute,
+			wantReplyProtocol: 0,
+		},
+		{
+			name:              "T0x0608 终端-查询区域或路线数据应答",
+			args:              &T0x0608{},
+			wantProtocol:      consts.T0608QueryRegionRespond,
+			wantReplyProtocol: consts.P8001GeneralRespond,
+		},
 		{
 			name:              "P0x9003 平台-查询终端音视频属性",
 			args:              &P0x9003{},
@@ -142,6 +160,102 @@ func TestReplyProtocol(t *testing.T) {
 			wantProtocol:      consts.T1206FileUploadCompleteNotice,
 			wantReplyProtocol: consts.P8001GeneralRespond,
 		},
+		{
+			name:              "T0x0005 终端-补传分包请求",
+			args:              &T0x0005{},
+			wantProtocol:      consts.T0005ReissueSubcontractingRequest,
+			wantReplyProtocol: 0,
+		},
+		{
+			name:              "T0x0A00 终端-RSA公钥",
+			args:              &T0x0A00{},
+			wantProtocol:      consts.T0A00TerminalRSAPublicKey,
+			wantReplyProtocol: consts.P8001GeneralRespond,
+		},
+		{
+			name:              "P0x8A00 平台-RSA公钥",
+			args:              &P0x8A00{},
+			wantProtocol:      consts.P8A00PlatformRSAPublicKey,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8105 平台-终端控制",
+			args:              &P0x8105{},
+			wantProtocol:      consts.P8105TerminalControl,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8108 平台-下发终端升级包",
+			args:              &P0x8108{},
+			wantProtocol:      consts.P8108DistributeTerminalUpgradePackage,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8107 平台-查询终端属性",
+			args:              &P0x8107{},
+			wantProtocol:      consts.P8107QueryTerminalProperties,
+			wantReplyProtocol: consts.T0107QueryAttribute,
+		},
+		{
+			name:              "P0x8106 平台-查询指定终端参数",
+			args:              &P0x8106{},
+			wantProtocol:      consts.P8106QuerySpecifyParam,
+			wantReplyProtocol: consts.T0104QueryParameter,
+		},
+		{
+			name:              "P0x8600 平台-设置圆形区域",
+			args:              &P0x8600{},
+			wantProtocol:      consts.P8600SetCircularArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8601 平台-删除圆形区域",
+			args:              &P0x8601{},
+			wantProtocol:      consts.P8601DeleteArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8602 平台-设置矩形区域",
+			args:              &P0x8602{},
+			wantProtocol:      consts.P8602SetRectArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8603 平台-删除矩形区域",
+			args:              &P0x8603{},
+			wantProtocol:      consts.P8603DeleteRectArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8604 平台-设置多边形区域",
+			args:              &P0x8604{},
+			wantProtocol:      consts.P8604PolygonArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8605 平台-删除多边形区域",
+			args:              &P0x8605{},
+			wantProtocol:      consts.P8605DeletePolygonArea,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8606 平台-设置路线",
+			args:              &P0x8606{},
+			wantProtocol:      consts.P8606SetRoute,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8607 平台-删除路线",
+			args:              &P0x8607{},
+			wantProtocol:      consts.P8607DeleteRoute,
+			wantReplyProtocol: consts.T0001GeneralRespond,
+		},
+		{
+			name:              "P0x8608 平台-查询区域或路线数据",
+			args:              &P0x8608{},
+			wantProtocol:      consts.P8608QueryAreaOrRouteData,
+			wantReplyProtocol: consts.T0608QueryRegionRespond,
+		},
 		{
 			name:              "P0x8003 平台-补发分包请求",
 			args:              &P0x8003{},
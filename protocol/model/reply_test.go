@@ -114,6 +114,36 @@ func TestReply(t *testing.T) {
 				msg2019: "7E010443A20100000000014419999999000500045B00000001040000000A00000002040000003C00000003040000000200000004040000003C00000005040000000200000006040000003C000000070400000002000000100B31333031323334353637300000001105313233343500000012053132333435000000130E3132372E302E302E313A37303030000000140531323334350000001505313233343500000016053132333435000000170531323334350000001A093132372E302E302E310000001B04000004570000001C04000004580000001D093132372E302E302E310000002004000000000000002104000000000000002204000000000000002301300000002401300000002501300000002601300000002704000000000000002804000000000000002904000000000000002C04000003E80000002D04000003E80000002E04000003E80000002F04000003E800000030040000000A0000003102003C000000320416320A1E000000400B3133303132333435363731000000410B3133303132333435363732000000420B3133303132333435363733000000430B3133303132333435363734000000440B3133303132333435363735000000450400000001000000460400000000000000470400000000000000480B3133303132333435363738000000490B313330313233343536373900000050040000000000000051040000000000000052040000000000000053040000000000000054040000000000000055040000003C000000560400000014000000570400003840000000580400000708000000590400001C200000005A040000012C0000005B0200500000005C0200050000005D02000A0000005E02001E00000064040000000100000065040000000100000070040000000100000071040000006F000000720400000070000000730400000071000000740400000072000000751500030190320000002800030190320000002800050100000076130400000101000002020000030300000404000000000077160101000301F43200000028000301F43200000028000500000079032808010000007A04000000230000007B0232320000007C1405000000000000000000000000000000000000000000008004000000240000008102000B000000820200660000008308BEA9415830303031000000840101000000900102000000910101000000920101000000930400000001000000940100000000950400000001000001000400000064000001010213880000010204000000640000010302138800000110080000000000000101F07E",
 			},
 		},
+		{
+			name: "T0x0108 终端-升级结果通知",
+			args: args{
+				Handler: &T0x0108{},
+				msg2013: "7e0108000201234567890100010001837e",
+			},
+			want: want{
+				result2013: "7e8001000501234567890100000001010800047e",
+			},
+		},
+		{
+			name: "T0x0107 终端-查询终端属性应答",
+			args: args{
+				Handler: &T0x0107{},
+				msg2013: "7e010700390123456789010000004541424344454a543830382d4d4f44454c00000000000000000031323334353637898600123456789012340456312e300556322e303103216f7e",
+				msg2019: "7e010740600100000000012345678901000001814142434445464748494a4b4a543830382d4d4f44454c2d323031390000000000000000000000000000494432303139000000000000000000000000000000000000000000000000898600123456789012340456312e300556322e30310321a37e",
+			},
+		},
+		{
+			name: "T0x0608 终端-查询区域或路线数据应答",
+			args: args{
+				Handler: &T0x0608{},
+				msg2013: "7e0608001e0123456789010001020000000100000003010201d905c00736504001c9c3800745928000500a277e",
+				msg2019: "7e0608403201000000000123456789010001010000000100000001000301c9c38007270e00000001f424010108000024123118000000500a003c0008b2e2cad4c7f8d3f2ab7e",
+			},
+			want: want{
+				result2013: "7e8001000501234567890100000001060800037e",
+				result2019: "7e80014005010000000001234567890100000001060800427e",
+			},
+		},
 		{
 			name: "P0x9003 平台-查询终端音视频属性",
 			args: args{
@@ -201,6 +231,121 @@ func TestReply(t *testing.T) {
 				msg2013: "7e000100050123456789017fff007b01c803bd7e",
 			},
 		},
+		{
+			name: "T0x0005 终端-补传分包请求",
+			args: args{
+				Handler: &T0x0005{},
+				msg2013: "7e000500070123456789017fff10990200010003837e",
+			},
+		},
+		{
+			name: "T0x0A00 终端-RSA公钥",
+			args: args{
+				Handler: &T0x0A00{},
+				msg2013: "7e0a0000840123456789017fff00010001abababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababab867e",
+			},
+			want: want{
+				result2013: "7e8001000501234567890100007fff0a0000867e",
+			},
+		},
+		{
+			name: "P0x8A00 平台-RSA公钥",
+			args: args{
+				Handler: &P0x8A00{},
+				msg2013: "7e8a000084012345678901000100010001abababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababababab877e",
+			},
+		},
+		{
+			name: "P0x8105 平台-终端控制",
+			args: args{
+				Handler: &P0x8105{},
+				msg2013: "7e81050001012345678901000104087e",
+			},
+		},
+		{
+			name: "P0x8108 平台-下发终端升级包",
+			args: args{
+				Handler: &P0x8108{},
+				msg2013: "7e8108001701234567890100010041424344450456312e32000000080102030405060708297e",
+			},
+		},
+		{
+			name: "P0x8107 平台-查询终端属性",
+			args: args{
+				Handler: &P0x8107{},
+				msg2019: "7e81074000010000000001234567890100004f7e",
+			},
+		},
+		{
+			name: "P0x8106 平台-查询指定终端参数",
+			args: args{
+				Handler: &P0x8106{},
+				msg2013: "7e8106000d01234567890100010300000001000000130000005b497e",
+			},
+		},
+		{
+			name: "P0x8600 平台-设置圆形区域",
+			args: args{
+				Handler: &P0x8600{},
+				msg2013: "7e860000230123456789010001010100000001000301c9c38007270e00000001f424010108000024123118000000500a177e",
+			},
+		},
+		{
+			name: "P0x8601 平台-删除圆形区域",
+			args: args{
+				Handler: &P0x8601{},
+				msg2013: "7e860100090123456789010001020000000100000002067e",
+			},
+		},
+		{
+			name: "P0x8602 平台-设置矩形区域",
+			args: args{
+				Handler: &P0x8602{},
+				msg2013: "7e8602001b0123456789010001020100000003010201d905c00736504001c9c3800745928000500aa87e",
+			},
+		},
+		{
+			name: "P0x8603 平台-删除矩形区域",
+			args: args{
+				Handler: &P0x8603{},
+				msg2013: "7e86030005012345678901000101000000030b7e",
+			},
+		},
+		{
+			name: "P0x8604 平台-设置多边形区域",
+			args: args{
+				Handler: &P0x8604{},
+				msg2019: "7e8604403401000000000123456789010001000000040001240101080000241231180000000301c9c38007270e0001cb4a20072894a001ccd0c007270e000006b6e0b1dfd0ce867e",
+			},
+		},
+		{
+			name: "P0x8605 平台-删除多边形区域",
+			args: args{
+				Handler: &P0x8605{},
+				msg2013: "7e860500010123456789010001000b7e",
+			},
+		},
+		{
+			name: "P0x8606 平台-设置路线",
+			args: args{
+				Handler: &P0x8606{},
+				msg2019: "7e8606403b010000000001234567890100010000000500240002000000010000006401c9c38107270e0132030258003c005a0f0046000000020000006501c9c38207270e0232000004c2b7cfdf457e",
+			},
+		},
+		{
+			name: "P0x8607 平台-删除路线",
+			args: args{
+				Handler: &P0x8607{},
+				msg2013: "7e860700090123456789010001020000000500000006007e",
+			},
+		},
+		{
+			name: "P0x8608 平台-查询区域或路线数据",
+			args: args{
+				Handler: &P0x8608{},
+				msg2019: "7e86084009010000000001234567890100010100000001000000014e7e",
+			},
+		},
 		{
 			name: "P0x8003 平台-补发分包请求",
 			args: args{
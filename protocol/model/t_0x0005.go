@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+// T0x0005 终端补传分包请求 2019版本新增 平台收到后重新发送对应的分包.
+type T0x0005 struct {
+	BaseHandle
+	// OriginalSerialNumber 原始消息流水号 对应要求补传的原始消息第一包的消息流水号
+	OriginalSerialNumber uint16 `json:"originalSerialNumber"`
+	// AgainPackageCount 重传包总数
+	AgainPackageCount byte `json:"againPackageCount"`
+	// AgainPackageList 重传包ID列表 BYTE[2*n] 重传包序号顺序排列，如“包 ID1 包 ID2......包 IDn
+	AgainPackageList []uint16 `json:"againPackageList"`
+}
+
+func (t *T0x0005) Protocol() consts.JT808CommandType {
+	return consts.T0005ReissueSubcontractingRequest
+}
+
+func (t *T0x0005) ReplyProtocol() consts.JT808CommandType {
+	return 0
+}
+
+func (t *T0x0005) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 3 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.OriginalSerialNumber = binary.BigEndian.Uint16(body[:2])
+	t.AgainPackageCount = body[2]
+	if len(body) != 3+2*int(t.AgainPackageCount) {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.AgainPackageList = make([]uint16, 0, t.AgainPackageCount)
+	for i := 0; i < int(t.AgainPackageCount); i++ {
+		id := binary.BigEndian.Uint16(body[3+(2*i) : 3+(2*i)+2])
+		t.AgainPackageList = append(t.AgainPackageList, id)
+	}
+	return nil
+}
+
+func (t *T0x0005) Encode() []byte {
+	data := make([]byte, 3)
+	binary.BigEndian.PutUint16(data[:2], t.OriginalSerialNumber)
+	data[2] = t.AgainPackageCount
+	for _, v := range t.AgainPackageList {
+		data = binary.BigEndian.AppendUint16(data, v)
+	}
+	return data
+}
+
+// HasReply 平台重新发送分包 不需要通用应答.
+func (t *T0x0005) HasReply() bool {
+	return false
+}
+
+func (t *T0x0005) String() string {
+	str := "\t重传包ID列表:"
+	for _, v := range t.AgainPackageList {
+		str += fmt.Sprintf("\n\t[%04x] 重传包ID:[%v]", v, v)
+	}
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", t.Protocol(), t.Encode()),
+		fmt.Sprintf("\t[%04x] 原始消息流水号:[%d]", t.OriginalSerialNumber, t.OriginalSerialNumber),
+		fmt.Sprintf("\t[%02x] 重传包总数:[%d]", t.AgainPackageCount, t.AgainPackageCount),
+		str,
+		"}",
+	}, "\n")
+}
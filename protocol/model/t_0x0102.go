@@ -30,8 +30,11 @@ func (t *T0x0102) Protocol() consts.JT808CommandType {
 
 func (t *T0x0102) Parse(jtMsg *jt808.JTMessage) error {
 	version := consts.JT808Protocol2013
-	if jtMsg.Header.ProtocolVersion == consts.JT808Protocol2019 {
-		version = consts.JT808Protocol2019
+	switch jtMsg.Header.ProtocolVersion {
+	case consts.JT808Protocol2019, consts.JT808Protocol2011:
+		// 2011版本的请求头和2013版本一样 需要外部根据注册消息设置
+		version = jtMsg.Header.ProtocolVersion
+	default:
 	}
 	t.Version = version
 
@@ -0,0 +1,275 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/utils"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+// iccidLen 终端SIM卡ICCID BCD[10].
+const iccidLen = 10
+
+type (
+	T0x0107 struct {
+		BaseHandle
+		// TerminalType 终端类型 见T0x0107TerminalTypeDetails
+		TerminalType uint16 `json:"terminalType"`
+		// ManufacturerID 制造商ID 2013版本5个字节 2019版本11个字节
+		ManufacturerID string `json:"manufacturerID"`
+		// TerminalModel 终端型号 2013版本20个字节 2019版本30个字节 位数不足时后补0x00
+		TerminalModel string `json:"terminalModel"`
+		// TerminalID 终端ID 2013版本7个字节 2019版本30个字节 由大写字母和数字组成
+		TerminalID string `json:"terminalID"`
+		// ICCID 终端SIM卡ICCID号 BCD[10]
+		ICCID string `json:"iccid"`
+		// HardwareVersionLen 终端硬件版本号长度
+		HardwareVersionLen byte `json:"hardwareVersionLen"`
+		// HardwareVersion 终端硬件版本号
+		HardwareVersion string `json:"hardwareVersion"`
+		// FirmwareVersionLen 终端固件版本号长度
+		FirmwareVersionLen byte `json:"firmwareVersionLen"`
+		// FirmwareVersion 终端固件版本号
+		FirmwareVersion string `json:"firmwareVersion"`
+		// GNSSProperty GNSS模块属性 见T0x0107GNSSDetails
+		GNSSProperty byte `json:"gnssProperty"`
+		// CommunicationProperty 通信模块属性 见T0x0107CommunicationDetails
+		CommunicationProperty byte `json:"communicationProperty"`
+		// Version 版本 2-2013 3-2019 2011版本没有这个指令 按照2013版本解析
+		Version consts.ProtocolVersionType `json:"version"`
+		// T0x0107TerminalTypeDetails 终端类型详情
+		T0x0107TerminalTypeDetails `json:"terminalTypeDetails"`
+		// T0x0107GNSSDetails GNSS模块属性详情
+		T0x0107GNSSDetails `json:"gnssDetails"`
+		// T0x0107CommunicationDetails 通信模块属性详情
+		T0x0107CommunicationDetails `json:"communicationDetails"`
+	}
+
+	T0x0107TerminalTypeDetails struct {
+		// PassengerVehicle bit0 适用客运车辆
+		PassengerVehicle bool `json:"passengerVehicle"`
+		// DangerousGoodsVehicle bit1 适用危险品车辆
+		DangerousGoodsVehicle bool `json:"dangerousGoodsVehicle"`
+		// FreightVehicle bit2 适用普通货运车辆
+		FreightVehicle bool `json:"freightVehicle"`
+		// TaxiVehicle bit3 适用出租车辆
+		TaxiVehicle bool `json:"taxiVehicle"`
+		// HardDiskVideo bit6 支持硬盘录像
+		HardDiskVideo bool `json:"hardDiskVideo"`
+		// SplitMachine bit7 0-一体机 1-分体机
+		SplitMachine bool `json:"splitMachine"`
+		// TrailerVehicle bit8 适用挂车 2019版本增加的
+		TrailerVehicle bool `json:"trailerVehicle"`
+	}
+
+	T0x0107GNSSDetails struct {
+		// GPS bit0 支持GPS定位
+		GPS bool `json:"gps"`
+		// BeiDou bit1 支持北斗定位
+		BeiDou bool `json:"beiDou"`
+		// GLONASS bit2 支持GLONASS定位
+		GLONASS bool `json:"glonass"`
+		// Galileo bit3 支持Galileo定位
+		Galileo bool `json:"galileo"`
+	}
+
+	T0x0107CommunicationDetails struct {
+		// GPRS bit0 支持GPRS通信
+		GPRS bool `json:"gprs"`
+		// CDMA bit1 支持CDMA通信
+		CDMA bool `json:"cdma"`
+		// TDSCDMA bit2 支持TD-SCDMA通信
+		TDSCDMA bool `json:"tdscdma"`
+		// WCDMA bit3 支持WCDMA通信
+		WCDMA bool `json:"wcdma"`
+		// CDMA2000 bit4 支持CDMA2000通信
+		CDMA2000 bool `json:"cdma2000"`
+		// TDLTE bit5 支持TD-LTE通信
+		TDLTE bool `json:"tdlte"`
+		// Other bit7 支持其他通信方式
+		Other bool `json:"other"`
+	}
+)
+
+func (t *T0x0107) Protocol() consts.JT808CommandType {
+	return consts.T0107QueryAttribute
+}
+
+func (t *T0x0107) ReplyProtocol() consts.JT808CommandType {
+	return 0
+}
+
+func (t *T0x0107) Parse(jtMsg *jt808.JTMessage) error {
+	t.Version = consts.JT808Protocol2013
+	if jtMsg.Header.ProtocolVersion == consts.JT808Protocol2019 {
+		t.Version = consts.JT808Protocol2019
+	}
+	mLen, tLen, tIDLen := t.protocolDiff()
+	body := jtMsg.Body
+	// 固定部分 终端类型+制造商ID+终端型号+终端ID+ICCID+硬件版本号长度
+	start := 2 + mLen + tLen + tIDLen + iccidLen
+	if len(body) < start+1 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	cutset := "\x00"
+	t.TerminalType = binary.BigEndian.Uint16(body[:2])
+	t.ManufacturerID = string(bytes.TrimRight(body[2:2+mLen], cutset))
+	t.TerminalModel = string(bytes.TrimRight(body[2+mLen:2+mLen+tLen], cutset))
+	t.TerminalID = string(bytes.TrimRight(body[2+mLen+tLen:2+mLen+tLen+tIDLen], cutset))
+	t.ICCID = hex.EncodeToString(body[start-iccidLen : start])
+
+	t.HardwareVersionLen = body[start]
+	start++
+	if len(body) < start+int(t.HardwareVersionLen)+1 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.HardwareVersion = string(body[start : start+int(t.HardwareVersionLen)])
+	start += int(t.HardwareVersionLen)
+
+	t.FirmwareVersionLen = body[start]
+	start++
+	if len(body) != start+int(t.FirmwareVersionLen)+2 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.FirmwareVersion = string(body[start : start+int(t.FirmwareVersionLen)])
+	start += int(t.FirmwareVersionLen)
+
+	t.GNSSProperty = body[start]
+	t.CommunicationProperty = body[start+1]
+	t.T0x0107TerminalTypeDetails.parse(t.TerminalType)
+	t.T0x0107GNSSDetails.parse(t.GNSSProperty)
+	t.T0x0107CommunicationDetails.parse(t.CommunicationProperty)
+	return nil
+}
+
+func (t *T0x0107) Encode() []byte {
+	mLen, tLen, tIDLen := t.protocolDiff()
+	t.HardwareVersionLen = byte(len(t.HardwareVersion))
+	t.FirmwareVersionLen = byte(len(t.FirmwareVersion))
+	data := make([]byte, 0, 2+mLen+tLen+tIDLen+iccidLen+len(t.HardwareVersion)+len(t.FirmwareVersion)+4)
+	data = binary.BigEndian.AppendUint16(data, t.TerminalType)
+	data = append(data, utils.String2FillingBytes(t.ManufacturerID, mLen)...)
+	data = append(data, utils.String2FillingBytes(t.TerminalModel, tLen)...)
+	data = append(data, utils.String2FillingBytes(t.TerminalID, tIDLen)...)
+	data = append(data, iccid2BCD(t.ICCID)...)
+	data = append(data, t.HardwareVersionLen)
+	data = append(data, t.HardwareVersion...)
+	data = append(data, t.FirmwareVersionLen)
+	data = append(data, t.FirmwareVersion...)
+	data = append(data, t.GNSSProperty, t.CommunicationProperty)
+	return data
+}
+
+func (t *T0x0107) HasReply() bool {
+	return false
+}
+
+func (t *T0x0107) String() string {
+	mLen, tLen, tIDLen := t.protocolDiff()
+	f := func(arg string, size int, remark string) string {
+		return fmt.Sprintf("\t[%x] %s(%d):[%s]", utils.String2FillingBytes(arg, size), remark, size, arg)
+	}
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", t.Protocol(), t.Encode()),
+		fmt.Sprintf("\t[%04x] 终端类型:[%d]", t.TerminalType, t.TerminalType),
+		t.T0x0107TerminalTypeDetails.String(),
+		f(t.ManufacturerID, mLen, "制造商ID"),
+		f(t.TerminalModel, tLen, "终端型号"),
+		f(t.TerminalID, tIDLen, "终端ID"),
+		fmt.Sprintf("\t[%x] 终端SIM卡ICCID:[%s]", iccid2BCD(t.ICCID), t.ICCID),
+		fmt.Sprintf("\t[%02x] 终端硬件版本号长度:[%d]", t.HardwareVersionLen, t.HardwareVersionLen),
+		fmt.Sprintf("\t[%x] 终端硬件版本号:[%s]", t.HardwareVersion, t.HardwareVersion),
+		fmt.Sprintf("\t[%02x] 终端固件版本号长度:[%d]", t.FirmwareVersionLen, t.FirmwareVersionLen),
+		fmt.Sprintf("\t[%x] 终端固件版本号:[%s]", t.FirmwareVersion, t.FirmwareVersion),
+		fmt.Sprintf("\t[%02x] GNSS模块属性:[%d]", t.GNSSProperty, t.GNSSProperty),
+		t.T0x0107GNSSDetails.String(),
+		fmt.Sprintf("\t[%02x] 通信模块属性:[%d]", t.CommunicationProperty, t.CommunicationProperty),
+		t.T0x0107CommunicationDetails.String(),
+		"}",
+	}, "\n")
+}
+
+func (t *T0x0107) protocolDiff() (int, int, int) {
+	if t.Version == consts.JT808Protocol2019 {
+		return 11, 30, 30
+	}
+	return 5, 20, 7
+}
+
+// iccid2BCD ICCID是20位数字 不足的前面补0.
+func iccid2BCD(iccid string) []byte {
+	if len(iccid) < 2*iccidLen {
+		iccid = strings.Repeat("0", 2*iccidLen-len(iccid)) + iccid
+	}
+	data, err := hex.DecodeString(iccid[:2*iccidLen])
+	if err != nil {
+		return make([]byte, iccidLen)
+	}
+	return data
+}
+
+func (d *T0x0107TerminalTypeDetails) parse(terminalType uint16) {
+	d.PassengerVehicle = terminalType&(1<<0) > 0
+	d.DangerousGoodsVehicle = terminalType&(1<<1) > 0
+	d.FreightVehicle = terminalType&(1<<2) > 0
+	d.TaxiVehicle = terminalType&(1<<3) > 0
+	d.HardDiskVideo = terminalType&(1<<6) > 0
+	d.SplitMachine = terminalType&(1<<7) > 0
+	d.TrailerVehicle = terminalType&(1<<8) > 0
+}
+
+func (d *T0x0107TerminalTypeDetails) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t[bit0]适用客运车辆:[%t]", d.PassengerVehicle),
+		fmt.Sprintf("\t\t[bit1]适用危险品车辆:[%t]", d.DangerousGoodsVehicle),
+		fmt.Sprintf("\t\t[bit2]适用普通货运车辆:[%t]", d.FreightVehicle),
+		fmt.Sprintf("\t\t[bit3]适用出租车辆:[%t]", d.TaxiVehicle),
+		fmt.Sprintf("\t\t[bit6]支持硬盘录像:[%t]", d.HardDiskVideo),
+		fmt.Sprintf("\t\t[bit7]分体机:[%t] false-一体机 true-分体机", d.SplitMachine),
+		fmt.Sprintf("\t\t[bit8]适用挂车:[%t]", d.TrailerVehicle),
+	}, "\n")
+}
+
+func (d *T0x0107GNSSDetails) parse(property byte) {
+	d.GPS = property&(1<<0) > 0
+	d.BeiDou = property&(1<<1) > 0
+	d.GLONASS = property&(1<<2) > 0
+	d.Galileo = property&(1<<3) > 0
+}
+
+func (d *T0x0107GNSSDetails) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t[bit0]GPS定位:[%t]", d.GPS),
+		fmt.Sprintf("\t\t[bit1]北斗定位:[%t]", d.BeiDou),
+		fmt.Sprintf("\t\t[bit2]GLONASS定位:[%t]", d.GLONASS),
+		fmt.Sprintf("\t\t[bit3]Galileo定位:[%t]", d.Galileo),
+	}, "\n")
+}
+
+func (d *T0x0107CommunicationDetails) parse(property byte) {
+	d.GPRS = property&(1<<0) > 0
+	d.CDMA = property&(1<<1) > 0
+	d.TDSCDMA = property&(1<<2) > 0
+	d.WCDMA = property&(1<<3) > 0
+	d.CDMA2000 = property&(1<<4) > 0
+	d.TDLTE = property&(1<<5) > 0
+	d.Other = property&(1<<7) > 0
+}
+
+func (d *T0x0107CommunicationDetails) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("\t\t[bit0]GPRS通信:[%t]", d.GPRS),
+		fmt.Sprintf("\t\t[bit1]CDMA通信:[%t]", d.CDMA),
+		fmt.Sprintf("\t\t[bit2]TD-SCDMA通信:[%t]", d.TDSCDMA),
+		fmt.Sprintf("\t\t[bit3]WCDMA通信:[%t]", d.WCDMA),
+		fmt.Sprintf("\t\t[bit4]CDMA2000通信:[%t]", d.CDMA2000),
+		fmt.Sprintf("\t\t[bit5]TD-LTE通信:[%t]", d.TDLTE),
+		fmt.Sprintf("\t\t[bit7]其他通信方式:[%t]", d.Other),
+	}, "\n")
+}
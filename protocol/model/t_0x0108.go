@@ -0,0 +1,45 @@
+package model
+
+import (
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type T0x0108 struct {
+	BaseHandle
+	// UpgradeType 升级类型 0-终端 12-道路运输证IC卡读卡器 52-北斗卫星定位模块
+	UpgradeType byte `json:"upgradeType"`
+	// Result 升级结果 0-成功 1-失败 2-取消
+	Result byte `json:"result"`
+}
+
+func (t *T0x0108) Protocol() consts.JT808CommandType {
+	return consts.T0108UpgradeNotice
+}
+
+func (t *T0x0108) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) != 2 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.UpgradeType = body[0]
+	t.Result = body[1]
+	return nil
+}
+
+func (t *T0x0108) Encode() []byte {
+	return []byte{t.UpgradeType, t.Result}
+}
+
+func (t *T0x0108) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", t.Protocol(), t.Encode()),
+		fmt.Sprintf("\t[%02x] 升级类型:[%d] 0-终端 12-道路运输证IC卡读卡器 52-北斗卫星定位模块", t.UpgradeType, t.UpgradeType),
+		fmt.Sprintf("\t[%02x] 升级结果:[%d] 0-成功 1-失败 2-取消", t.Result, t.Result),
+		"}",
+	}, "\n")
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"strings"
+)
+
+type T0x0608 struct {
+	BaseHandle
+	// QueryType 查询类型 1-圆形区域 2-矩形区域 3-多边形区域 4-路线
+	QueryType byte `json:"queryType"`
+	// Total 查询到的区域或路线的数量
+	Total uint32 `json:"total"`
+	// Circles 圆形区域 QueryType=1时有效 格式同0x8600的区域项
+	Circles []AreaCircle `json:"circles"`
+	// Rects 矩形区域 QueryType=2时有效 格式同0x8602的区域项
+	Rects []AreaRect `json:"rects"`
+	// Polygons 多边形区域 QueryType=3时有效 格式同0x8604
+	Polygons []AreaPolygon `json:"polygons"`
+	// Routes 路线 QueryType=4时有效 格式同0x8606
+	Routes []AreaRoute `json:"routes"`
+	// Version 版本 2019版本增加的指令 2019版本的区域和路线有夜间最高速度和名称
+	Version consts.ProtocolVersionType `json:"version"`
+}
+
+func (t *T0x0608) Protocol() consts.JT808CommandType {
+	return consts.T0608QueryRegionRespond
+}
+
+func (t *T0x0608) Parse(jtMsg *jt808.JTMessage) error {
+	body := jtMsg.Body
+	if len(body) < 5 {
+		return protocol.ErrBodyLengthInconsistency
+	}
+	t.Version = areaVersion(jtMsg)
+	t.QueryType = body[0]
+	t.Total = binary.BigEndian.Uint32(body[1:5])
+	r := &areaReader{data: body[5:]}
+	for i := uint32(0); i < t.Total && r.err == nil; i++ {
+		switch t.QueryType {
+		case 1:
+			area := AreaCircle{}
+			area.parse(r, t.Version)
+			t.Circles = append(t.Circles, area)
+		case 2:
+			area := AreaRect{}
+			area.parse(r, t.Version)
+			t.Rects = append(t.Rects, area)
+		case 3:
+			area := AreaPolygon{}
+			area.parse(r, t.Version)
+			t.Polygons = append(t.Polygons, area)
+		case 4:
+			route := AreaRoute{}
+			route.parse(r, t.Version)
+			t.Routes = append(t.Routes, route)
+		default:
+			return protocol.ErrUnqualifiedData
+		}
+	}
+	return r.finish()
+}
+
+func (t *T0x0608) Encode() []byte {
+	data := make([]byte, 5, 100)
+	data[0] = t.QueryType
+	switch t.QueryType {
+	case 1:
+		t.Total = uint32(len(t.Circles))
+		for i := range t.Circles {
+			data = t.Circles[i].encode(data, t.Version)
+		}
+	case 2:
+		t.Total = uint32(len(t.Rects))
+		for i := range t.Rects {
+			data = t.Rects[i].encode(data, t.Version)
+		}
+	case 3:
+		t.Total = uint32(len(t.Polygons))
+		for i := range t.Polygons {
+			data = t.Polygons[i].encode(data, t.Version)
+		}
+	case 4:
+		t.Total = uint32(len(t.Routes))
+		for i := range t.Routes {
+			data = t.Routes[i].encode(data, t.Version)
+		}
+	default:
+	}
+	binary.BigEndian.PutUint32(data[1:5], t.Total)
+	return data
+}
+
+func (t *T0x0608) String() string {
+	str := "\t区域或路线列表:"
+	for _, v := range t.Circles {
+		str += "\n" + v.String()
+	}
+	for _, v := range t.Rects {
+		str += "\n" + v.String()
+	}
+	for _, v := range t.Polygons {
+		str += "\n" + v.String()
+	}
+	for _, v := range t.Routes {
+		str += "\n" + v.String()
+	}
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", t.Protocol(), t.Encode()),
+		fmt.Sprintf("\t[%02x] 查询类型:[%d] 1-圆形区域 2-矩形区域 3-多边形区域 4-路线", t.QueryType, t.QueryType),
+		fmt.Sprintf("\t[%08x] 数量:[%d]", t.Total, t.Total),
+		str,
+		"}",
+	}, "\n")
+}
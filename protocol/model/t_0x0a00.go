@@ -0,0 +1,84 @@
+package model
+
+import (
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"math/big"
+	"strings"
+)
+
+// rsaModulusLength RSA公钥{e,n}中n的长度.
+const rsaModulusLength = 128
+
+// T0x0A00 终端RSA公钥 平台下发的消息体使用这个公钥加密.
+type T0x0A00 struct {
+	BaseHandle
+	// E RSA公钥{e,n}中的e
+	E uint32 `json:"e"`
+	// N RSA公钥{e,n}中的n 128个字节
+	N []byte `json:"n"`
+}
+
+func (t *T0x0A00) Protocol() consts.JT808CommandType {
+	return consts.T0A00TerminalRSAPublicKey
+}
+
+func (t *T0x0A00) ReplyProtocol() consts.JT808CommandType {
+	return consts.P8001GeneralRespond
+}
+
+func (t *T0x0A00) Parse(jtMsg *jt808.JTMessage) error {
+	e, n, err := parseRSAPublicKey(jtMsg.Body)
+	if err != nil {
+		return err
+	}
+	t.E, t.N = e, n
+	return nil
+}
+
+func (t *T0x0A00) Encode() []byte {
+	return encodeRSAPublicKey(t.E, t.N)
+}
+
+// PublicKey 转换成标准库的公钥.
+func (t *T0x0A00) PublicKey() *rsa.PublicKey {
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(t.N),
+		E: int(t.E),
+	}
+}
+
+func (t *T0x0A00) String() string {
+	return strings.Join([]string{
+		"数据体对象:{",
+		fmt.Sprintf("\t%s:[%x]", t.Protocol(), t.Encode()),
+		fmt.Sprintf("\t[%08x] e:[%d]", t.E, t.E),
+		fmt.Sprintf("\t[%x] n", t.N),
+		"}",
+	}, "\n")
+}
+
+func parseRSAPublicKey(body []byte) (uint32, []byte, error) {
+	if len(body) != 4+rsaModulusLength {
+		return 0, nil, protocol.ErrBodyLengthInconsistency
+	}
+	e := binary.BigEndian.Uint32(body[:4])
+	n := make([]byte, rsaModulusLength)
+	copy(n, body[4:])
+	return e, n, nil
+}
+
+func encodeRSAPublicKey(e uint32, n []byte) []byte {
+	data := make([]byte, 4, 4+rsaModulusLength)
+	binary.BigEndian.PutUint32(data[:4], e)
+	if len(n) > rsaModulusLength {
+		n = n[len(n)-rsaModulusLength:]
+	}
+	// n不足128个字节的 前面补0
+	data = append(data, make([]byte, rsaModulusLength-len(n))...)
+	return append(data, n...)
+}
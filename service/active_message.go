@@ -1,18 +1,23 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type ActiveMessage struct {
 	// header 设备消息固体头 使用的是第一次报文的固定头
 	header *jt808.Header
-	// replyChan 用于获取终端应答情况
-	replyChan chan *Message
+	// reply 用于获取终端应答情况 每次下发都是新的
+	reply *activeReply
+	// subPackage 逐个下发的分包 远程升级使用 为空的按照Body长度自动分包
+	subPackage *activeSubPackage
 	// Key 唯一标识符 默认手机号
 	Key string `json:"key"`
 	// Command 平台下发的指令
@@ -21,7 +26,9 @@ type ActiveMessage struct {
 	Body []byte `json:"body"`
 	// OverTimeDuration  超时时间 默认5秒
 	OverTimeDuration time.Duration `json:"overTimeDuration"`
-	ExtensionFields  struct {
+	// OfflineTTL 设置了离线缓存的情况 终端离线时指令的有效时间 默认使用Options.OfflineTTL
+	OfflineTTL      time.Duration `json:"offlineTTL,omitempty"`
+	ExtensionFields struct {
 		// PlatformSeq 平台下发的流水号
 		PlatformSeq uint16 `json:"platformSeq,omitempty"`
 		// Data 平台最终下发的数据
@@ -40,3 +47,62 @@ func (a *ActiveMessage) String() string {
 		fmt.Sprintf("body[%x]", a.Body),
 	}, "\n")
 }
+
+type (
+	// activeSubPackage 逐个下发的分包 no是包序号 sum是总包数.
+	activeSubPackage struct {
+		group *subPackageGroup
+		no    uint16
+		sum   uint16
+	}
+
+	// subPackageGroup 同一组分包共用的流水号 conn是预留流水号的连接 只在连接的writer中修改.
+	subPackageGroup struct {
+		conn     *connection
+		firstSeq uint16
+	}
+)
+
+// activeReply 平台下发指令的结果 终端应答 超时 连接关闭 ctx结束等情况只有第一次的有效.
+type activeReply struct {
+	once     sync.Once
+	callback func(msg *Message)
+	// canceled ctx结束了 连接还没有下发的不再下发
+	canceled atomic.Bool
+	// conn 下发的连接 ctx结束的时候通知连接删除等待中的指令
+	conn atomic.Pointer[connection]
+
+	mu    sync.Mutex
+	done  bool
+	stops []func() bool
+}
+
+func newActiveReply(callback func(msg *Message)) *activeReply {
+	return &activeReply{callback: callback}
+}
+
+func (r *activeReply) reply(msg *Message) {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.done = true
+		stops := r.stops
+		r.stops = nil
+		r.mu.Unlock()
+		for _, stop := range stops {
+			stop()
+		}
+		r.callback(msg)
+	})
+}
+
+// afterFunc ctx结束后执行f 已经回复了的情况不再执行.
+func (r *activeReply) afterFunc(ctx context.Context, f func()) {
+	stop := context.AfterFunc(ctx, f)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		stop()
+		return
+	}
+	r.stops = append(r.stops, stop)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"container/heap"
+	"time"
+)
+
+type (
+	// activeTimer 平台下发指令的超时管理 每个连接一个定时器 不需要每个指令一个等待的协程.
+	activeTimer struct {
+		timer *time.Timer
+		items activeTimeouts
+	}
+
+	activeTimeout struct {
+		deadline time.Time
+		duration time.Duration
+		seq      uint16
+		// activeMsg 用于判断是否是同一个指令 流水号会重复使用
+		activeMsg *ActiveMessage
+		// replyMsg 超时后回复的
+		replyMsg *Message
+	}
+
+	// activeTimeouts 按照超时时间排序的最小堆.
+	activeTimeouts []*activeTimeout
+)
+
+func newActiveTimer() *activeTimer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &activeTimer{timer: timer}
+}
+
+func (a *activeTimer) C() <-chan time.Time {
+	return a.timer.C
+}
+
+func (a *activeTimer) add(item *activeTimeout) {
+	heap.Push(&a.items, item)
+	if a.items[0] == item {
+		a.timer.Reset(time.Until(item.deadline))
+	}
+}
+
+// expired 取出已经超时的 然后根据最早的超时时间重置定时器.
+func (a *activeTimer) expired(now time.Time) []*activeTimeout {
+	var items []*activeTimeout
+	for len(a.items) > 0 && !a.items[0].deadline.After(now) {
+		items = append(items, heap.Pop(&a.items).(*activeTimeout))
+	}
+	if len(a.items) > 0 {
+		a.timer.Reset(a.items[0].deadline.Sub(now))
+	}
+	return items
+}
+
+func (a *activeTimer) stop() {
+	a.timer.Stop()
+	clear(a.items)
+	a.items = nil
+}
+
+func (a activeTimeouts) Len() int {
+	return len(a)
+}
+
+func (a activeTimeouts) Less(i, j int) bool {
+	return a[i].deadline.Before(a[j].deadline)
+}
+
+func (a activeTimeouts) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a *activeTimeouts) Push(x any) {
+	*a = append(*a, x.(*activeTimeout))
+}
+
+func (a *activeTimeouts) Pop() any {
+	old := *a
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*a = old[:n-1]
+	return item
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"maps"
+	"sync"
+)
+
+// RegisterResult 终端注册结果 对应0x8100的结果.
+type RegisterResult byte
+
+const (
+	// RegisterSuccess 注册成功.
+	RegisterSuccess RegisterResult = 0
+	// RegisterVehicleRegistered 车辆已被注册.
+	RegisterVehicleRegistered RegisterResult = 1
+	// RegisterVehicleNotFound 数据库中无该车辆.
+	RegisterVehicleNotFound RegisterResult = 2
+	// RegisterTerminalRegistered 终端已被注册.
+	RegisterTerminalRegistered RegisterResult = 3
+	// RegisterTerminalNotFound 数据库中无该终端.
+	RegisterTerminalNotFound RegisterResult = 4
+)
+
+func (r RegisterResult) String() string {
+	switch r {
+	case RegisterSuccess:
+		return "成功"
+	case RegisterVehicleRegistered:
+		return "车辆已被注册"
+	case RegisterVehicleNotFound:
+		return "数据库中无该车辆"
+	case RegisterTerminalRegistered:
+		return "终端已被注册"
+	case RegisterTerminalNotFound:
+		return "数据库中无该终端"
+	default:
+	}
+	return "未知的注册结果"
+}
+
+// Authenticator 终端注册和鉴权.
+// 设置后注册或者鉴权失败的终端 回复后断开连接 未鉴权的终端上传的报文回复失败 不触发读写事件.
+type Authenticator interface {
+	// Register 终端注册 返回注册结果和鉴权码 返回错误的情况不回复终端.
+	Register(key string, register *model.T0x0100) (RegisterResult, string, error)
+	// Auth 终端鉴权 判断鉴权码是否正确 返回错误的情况不回复终端.
+	Auth(key string, auth *model.T0x0102) (bool, error)
+}
+
+type (
+	// MemoryAuthenticator 内存存储的注册鉴权 服务重启后需要重新注册.
+	MemoryAuthenticator struct {
+		mu     sync.RWMutex
+		record authRecord
+		// onChange 注册信息变化后的回调 用于持久化
+		onChange func(record authRecord) error
+	}
+
+	authRecord struct {
+		// AllowTerminals 允许接入的终端 为空的时候不限制
+		AllowTerminals map[string]struct{}
+		// AllowVehicles 允许接入的车辆(车牌号或者VIN) 为空的时候不限制
+		AllowVehicles map[string]struct{}
+		// Registrations 已经注册的终端
+		Registrations map[string]Registration
+	}
+
+	// Registration 终端的注册信息.
+	Registration struct {
+		// AuthCode 鉴权码
+		AuthCode string `json:"authCode"`
+		// LicensePlateNumber 车辆标识 车牌号或者VIN
+		LicensePlateNumber string `json:"licensePlateNumber"`
+	}
+)
+
+func NewMemoryAuthenticator() *MemoryAuthenticator {
+	return &MemoryAuthenticator{
+		record: newAuthRecord(),
+	}
+}
+
+func newAuthRecord() authRecord {
+	return authRecord{
+		AllowTerminals: make(map[string]struct{}),
+		AllowVehicles:  make(map[string]struct{}),
+		Registrations:  make(map[string]Registration),
+	}
+}
+
+// AllowTerminals 增加允许接入的终端.
+func (m *MemoryAuthenticator) AllowTerminals(keys ...string) error {
+	return m.update(func() {
+		for _, key := range keys {
+			m.record.AllowTerminals[key] = struct{}{}
+		}
+	})
+}
+
+// AllowVehicles 增加允许接入的车辆.
+func (m *MemoryAuthenticator) AllowVehicles(plates ...string) error {
+	return m.update(func() {
+		for _, plate := range plates {
+			m.record.AllowVehicles[plate] = struct{}{}
+		}
+	})
+}
+
+// Revoke 注销终端 终端需要重新注册.
+func (m *MemoryAuthenticator) Revoke(key string) error {
+	return m.update(func() {
+		delete(m.record.Registrations, key)
+	})
+}
+
+// Registration 获取终端的注册信息.
+func (m *MemoryAuthenticator) Registration(key string) (Registration, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.record.Registrations[key]
+	return v, ok
+}
+
+func (m *MemoryAuthenticator) Register(key string, register *model.T0x0100) (RegisterResult, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	plate := register.LicensePlateNumber
+	if len(m.record.AllowTerminals) > 0 {
+		if _, ok := m.record.AllowTerminals[key]; !ok {
+			return RegisterTerminalNotFound, "", nil
+		}
+	}
+	if len(m.record.AllowVehicles) > 0 {
+		if _, ok := m.record.AllowVehicles[plate]; !ok {
+			return RegisterVehicleNotFound, "", nil
+		}
+	}
+	// 没有车牌的终端(车牌颜色0 使用VIN)可能是空的 不判断冲突
+	for k, v := range m.record.Registrations {
+		if plate != "" && k != key && v.LicensePlateNumber == plate {
+			return RegisterVehicleRegistered, "", nil
+		}
+	}
+	if v, ok := m.record.Registrations[key]; ok {
+		if v.LicensePlateNumber != plate {
+			return RegisterTerminalRegistered, "", nil
+		}
+		return RegisterSuccess, v.AuthCode, nil
+	}
+
+	code, err := newAuthCode()
+	if err != nil {
+		return 0, "", err
+	}
+	m.record.Registrations[key] = Registration{
+		AuthCode:           code,
+		LicensePlateNumber: plate,
+	}
+	if m.onChange != nil {
+		if err := m.onChange(m.record); err != nil {
+			delete(m.record.Registrations, key)
+			return 0, "", err
+		}
+	}
+	return RegisterSuccess, code, nil
+}
+
+func (m *MemoryAuthenticator) Auth(key string, auth *model.T0x0102) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.record.AllowTerminals) > 0 {
+		if _, ok := m.record.AllowTerminals[key]; !ok {
+			return false, nil
+		}
+	}
+	v, ok := m.record.Registrations[key]
+	return ok && v.AuthCode == auth.AuthCode, nil
+}
+
+// update 持久化失败的恢复修改之前的 内存和存储保持一致.
+func (m *MemoryAuthenticator) update(f func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.onChange == nil {
+		f()
+		return nil
+	}
+	previous := m.record.clone()
+	f()
+	if err := m.onChange(m.record); err != nil {
+		m.record = previous
+		return err
+	}
+	return nil
+}
+
+func (a authRecord) clone() authRecord {
+	return authRecord{
+		AllowTerminals: maps.Clone(a.AllowTerminals),
+		AllowVehicles:  maps.Clone(a.AllowVehicles),
+		Registrations:  maps.Clone(a.Registrations),
+	}
+}
+
+func newAuthCode() (string, error) {
+	data := make([]byte, 8)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("create auth code: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+type (
+	// FileAuthenticator 文件存储的注册鉴权 注册信息变化后写入json文件 服务重启后继续使用.
+	FileAuthenticator struct {
+		*MemoryAuthenticator
+		path string
+	}
+
+	// authFile 文件的格式 允许接入的终端和车辆可以手动修改.
+	authFile struct {
+		AllowTerminals []string                `json:"allowTerminals"`
+		AllowVehicles  []string                `json:"allowVehicles"`
+		Registrations  map[string]Registration `json:"registrations"`
+	}
+)
+
+// NewFileAuthenticator 读取path的注册信息 文件不存在的情况在第一次注册的时候创建.
+func NewFileAuthenticator(path string) (*FileAuthenticator, error) {
+	f := &FileAuthenticator{
+		MemoryAuthenticator: NewMemoryAuthenticator(),
+		path:                path,
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read auth file [%s]: %w", path, err)
+	case len(data) > 0:
+		var file authFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("unmarshal auth file [%s]: %w", path, err)
+		}
+		record := f.MemoryAuthenticator.record
+		for _, key := range file.AllowTerminals {
+			record.AllowTerminals[key] = struct{}{}
+		}
+		for _, plate := range file.AllowVehicles {
+			record.AllowVehicles[plate] = struct{}{}
+		}
+		for key, v := range file.Registrations {
+			record.Registrations[key] = v
+		}
+	}
+	f.MemoryAuthenticator.onChange = f.save
+	return f, nil
+}
+
+// save 先写临时文件再替换 避免写入一半的情况.
+func (f *FileAuthenticator) save(record authRecord) error {
+	file := authFile{
+		AllowTerminals: make([]string, 0, len(record.AllowTerminals)),
+		AllowVehicles:  make([]string, 0, len(record.AllowVehicles)),
+		Registrations:  record.Registrations,
+	}
+	for key := range record.AllowTerminals {
+		file.AllowTerminals = append(file.AllowTerminals, key)
+	}
+	for plate := range record.AllowVehicles {
+		file.AllowVehicles = append(file.AllowVehicles, plate)
+	}
+	slices.Sort(file.AllowTerminals)
+	slices.Sort(file.AllowVehicles)
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save auth file [%s]: %w", f.path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save auth file [%s]: %w", f.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save auth file [%s]: %w", f.path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save auth file [%s]: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 注册 2013版本 手机号12345678901 车牌号测A1234.
+const _registerMsg = "7e0100002c0123456789010000001f007363640000007777772e3830382e636f6d0000000000000000003736353433323101b2e24131323334cc7e"
+
+func TestMemoryAuthenticator(t *testing.T) {
+	auth := NewMemoryAuthenticator()
+	_ = auth.AllowTerminals("1001", "1002", "1003")
+	_ = auth.AllowVehicles("测A1234", "测A5678")
+
+	tests := []struct {
+		name   string
+		key    string
+		plate  string
+		result RegisterResult
+	}{
+		{name: "数据库中无该终端", key: "2001", plate: "测A1234", result: RegisterTerminalNotFound},
+		{name: "数据库中无该车辆", key: "1001", plate: "测B0000", result: RegisterVehicleNotFound},
+		{name: "注册成功", key: "1001", plate: "测A1234", result: RegisterSuccess},
+		{name: "重复注册成功", key: "1001", plate: "测A1234", result: RegisterSuccess},
+		{name: "车辆已被注册", key: "1002", plate: "测A1234", result: RegisterVehicleRegistered},
+		{name: "终端已被注册", key: "1001", plate: "测A5678", result: RegisterTerminalRegistered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, code, err := auth.Register(tt.key, &model.T0x0100{LicensePlateNumber: tt.plate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.result {
+				t.Errorf("Register() result = %s, want %s", result, tt.result)
+			}
+			if (result == RegisterSuccess) != (code != "") {
+				t.Errorf("Register() result = %s code = [%s]", result, code)
+			}
+		})
+	}
+
+	registration, _ := auth.Registration("1001")
+	if pass, _ := auth.Auth("1001", &model.T0x0102{AuthCode: registration.AuthCode}); !pass {
+		t.Error("Auth() want pass")
+	}
+	if pass, _ := auth.Auth("1001", &model.T0x0102{AuthCode: "1001"}); pass {
+		t.Error("Auth() wrong code want fail")
+	}
+	_ = auth.Revoke("1001")
+	if pass, _ := auth.Auth("1001", &model.T0x0102{AuthCode: registration.AuthCode}); pass {
+		t.Error("Auth() revoke want fail")
+	}
+}
+
+func TestMemoryAuthenticatorNoPlate(t *testing.T) {
+	auth := NewMemoryAuthenticator()
+	// 没有车牌的终端 车牌号为空 不算车辆已被注册
+	for _, key := range []string{"1001", "1002"} {
+		if result, _, err := auth.Register(key, &model.T0x0100{PlateColor: 0}); err != nil || result != RegisterSuccess {
+			t.Errorf("Register(%s) = %s %v", key, result, err)
+		}
+	}
+}
+
+func TestMemoryAuthenticatorRollback(t *testing.T) {
+	auth := NewMemoryAuthenticator()
+	_ = auth.AllowTerminals("1001")
+	_, _, _ = auth.Register("1001", &model.T0x0100{LicensePlateNumber: "测A1234"})
+	saveErr := errors.New("save fail")
+	auth.onChange = func(_ authRecord) error {
+		return saveErr
+	}
+	// 持久化失败的 内存中也不修改
+	if err := auth.AllowTerminals("1002"); !errors.Is(err, saveErr) {
+		t.Errorf("AllowTerminals() err = %v", err)
+	}
+	if err := auth.Revoke("1001"); !errors.Is(err, saveErr) {
+		t.Errorf("Revoke() err = %v", err)
+	}
+	if result, _, _ := auth.Register("1002", &model.T0x0100{LicensePlateNumber: "测A5678"}); result != RegisterTerminalNotFound {
+		t.Errorf("Register(1002) = %s", result)
+	}
+	if _, ok := auth.Registration("1001"); !ok {
+		t.Error("Registration(1001) want exist")
+	}
+}
+
+func TestFileAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	auth, err := NewFileAuthenticator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = auth.AllowTerminals("1001")
+	_, code, err := auth.Register("1001", &model.T0x0100{LicensePlateNumber: "测A1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新读取文件 注册信息还在
+	reload, err := NewFileAuthenticator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pass, _ := reload.Auth("1001", &model.T0x0102{AuthCode: code}); !pass {
+		t.Error("Auth() after reload want pass")
+	}
+	if result, _, _ := reload.Register("1002", &model.T0x0100{LicensePlateNumber: "测A5678"}); result != RegisterTerminalNotFound {
+		t.Errorf("Register() after reload result = %s", result)
+	}
+}
+
+func TestGoJT808Authenticator(t *testing.T) {
+	addr := freeAddr(t)
+	auth := NewMemoryAuthenticator()
+	_ = auth.AllowTerminals("12345678901")
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithAuthenticator(auth),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	// 未鉴权的心跳 回复失败
+	if p8001 := readP8001(t, conn); p8001.Result != 1 {
+		t.Errorf("heartbeat result = %d, want 1", p8001.Result)
+	}
+	// 不需要回复的和回复其他协议的 也使用通用应答回复失败
+	for _, v := range []string{
+		"7e0001000601234567890100010001000200008d7e",
+		"7e0801000a0123456789010001000000010000000000018a7e",
+	} {
+		data, _ := hex.DecodeString(v)
+		if _, err := conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if p8001 := readP8001(t, conn); p8001.Result != 1 {
+			t.Errorf("[%s] result = %d, want 1", v, p8001.Result)
+		}
+	}
+
+	register := dialAndSend(t, addr, _registerMsg)
+	defer func() {
+		_ = register.Close()
+	}()
+	jtMsg := readJTMessage(t, register)
+	p8100 := &model.P0x8100{}
+	if err := p8100.Parse(jtMsg); err != nil || p8100.Result != byte(RegisterSuccess) {
+		t.Fatalf("register result = %d err = %v", p8100.Result, err)
+	}
+
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0102RegisterAuth)
+	header.PlatformSerialNumber = 1
+	if _, err := register.Write(header.Encode([]byte("wrong code"))); err != nil {
+		t.Fatal(err)
+	}
+	if p8001 := readP8001(t, register); p8001.Result != 1 {
+		t.Errorf("auth result = %d, want 1", p8001.Result)
+	}
+	// 鉴权失败的断开连接
+	_ = register.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := register.Read(make([]byte, 10)); !errors.Is(err, io.EOF) {
+		t.Errorf("read after auth fail err = %v, want EOF", err)
+	}
+
+	// 等待上一个连接离开
+	time.Sleep(100 * time.Millisecond)
+	again := dialAndSend(t, addr, _registerMsg)
+	defer func() {
+		_ = again.Close()
+	}()
+	_ = readJTMessage(t, again)
+	header.PlatformSerialNumber = 2
+	if _, err := again.Write(header.Encode([]byte(p8100.AuthCode))); err != nil {
+		t.Fatal(err)
+	}
+	if p8001 := readP8001(t, again); p8001.Result != 0 {
+		t.Errorf("auth result = %d, want 0", p8001.Result)
+	}
+}
+
+func TestGoJT808AuthRSAPublicKey(t *testing.T) {
+	platformKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminalKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := freeAddr(t)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithAuthenticator(NewMemoryAuthenticator()),
+		WithRSA(platformKey),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	header := readJTMessage(t, conn).Header
+
+	// 未鉴权的终端上传公钥 回复失败 不设置公钥
+	p8a00 := &model.P0x8A00{}
+	if err := p8a00.SetPublicKey(&terminalKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	header.ReplyID = uint16(consts.T0A00TerminalRSAPublicKey)
+	if _, err := conn.Write(header.Encode(p8a00.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	if p8001 := readP8001(t, conn); p8001.Result != 1 || p8001.RespondID != uint16(consts.T0A00TerminalRSAPublicKey) {
+		t.Errorf("P0x8001 = %+v", p8001)
+	}
+	if info, ok := goJt808.Session("14419999999"); !ok || info.RSAPublicKey != nil {
+		t.Errorf("Session() RSAPublicKey = %v %t", info.RSAPublicKey, ok)
+	}
+}
+
+func readJTMessage(t *testing.T, conn net.Conn) *jt808.JTMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	data := make([]byte, 1024)
+	n, err := conn.Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(data[:n]); err != nil {
+		t.Fatalf("decode [%s] err = %v", hex.EncodeToString(data[:n]), err)
+	}
+	return jtMsg
+}
+
+func readP8001(t *testing.T, conn net.Conn) *model.P0x8001 {
+	t.Helper()
+	jtMsg := readJTMessage(t, conn)
+	if jtMsg.Header.ID != uint16(consts.P8001GeneralRespond) {
+		t.Fatalf("reply id = %04x, want 8001", jtMsg.Header.ID)
+	}
+	p8001 := &model.P0x8001{}
+	if err := p8001.Parse(jtMsg); err != nil {
+		t.Fatal(err)
+	}
+	return p8001
+}
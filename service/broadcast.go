@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaultBroadcastConcurrency 批量下发默认的并发数.
+const defaultBroadcastConcurrency = 100
+
+// BroadcastStatus 批量下发单个终端的结果.
+type BroadcastStatus uint8
+
+const (
+	// BroadcastSuccess 终端应答了.
+	BroadcastSuccess BroadcastStatus = iota + 1
+	// BroadcastTimeout 终端应答超时.
+	BroadcastTimeout
+	// BroadcastOffline 终端不在线 或者等待应答的时候断开了.
+	BroadcastOffline
+	// BroadcastWriteFail 写入数据失败.
+	BroadcastWriteFail
+	// BroadcastFail 其他失败 如ctx结束 服务关闭.
+	BroadcastFail
+	// BroadcastQueued 终端不在线 设置了离线缓存的情况 等终端加入后下发.
+	BroadcastQueued
+)
+
+func (b BroadcastStatus) String() string {
+	switch b {
+	case BroadcastSuccess:
+		return "成功"
+	case BroadcastTimeout:
+		return "超时"
+	case BroadcastOffline:
+		return "不在线"
+	case BroadcastWriteFail:
+		return "写入失败"
+	case BroadcastFail:
+		return "失败"
+	case BroadcastQueued:
+		return "离线缓存"
+	default:
+	}
+	return "未知的下发结果"
+}
+
+type (
+	// BroadcastResult 批量下发单个终端的结果.
+	BroadcastResult struct {
+		// Key 唯一标识符 默认手机号
+		Key string `json:"key"`
+		// Status 下发结果
+		Status BroadcastStatus `json:"status"`
+		// Message 终端应答或者失败的情况
+		Message *Message `json:"message"`
+	}
+
+	// BroadcastReport 批量下发的结果 顺序和下发的key一致.
+	BroadcastReport struct {
+		Results []BroadcastResult `json:"results"`
+	}
+)
+
+// Count 指定结果的数量.
+func (b *BroadcastReport) Count(status BroadcastStatus) int {
+	sum := 0
+	for _, v := range b.Results {
+		if v.Status == status {
+			sum++
+		}
+	}
+	return sum
+}
+
+// Broadcast 批量下发相同的指令给多个终端 activeMsg的Key不使用.
+// concurrency 同时等待应答的最大数量 小于等于0使用默认的100.
+func (g *GoJT808) Broadcast(ctx context.Context, keys []string, activeMsg *ActiveMessage, concurrency int) *BroadcastReport {
+	report := &BroadcastReport{Results: make([]BroadcastResult, len(keys))}
+	if concurrency <= 0 {
+		concurrency = defaultBroadcastConcurrency
+	}
+	indexChan := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexChan {
+				report.Results[i] = g.broadcastOne(ctx, keys[i], activeMsg)
+			}
+		}()
+	}
+	for i := range keys {
+		indexChan <- i
+	}
+	close(indexChan)
+	wg.Wait()
+	return report
+}
+
+// BroadcastFunc 批量下发给在线的终端 filter返回true的才下发 同一个key只下发一次.
+func (g *GoJT808) BroadcastFunc(ctx context.Context, filter func(info SessionInfo) bool,
+	activeMsg *ActiveMessage, concurrency int) *BroadcastReport {
+	var (
+		keys  []string
+		exist = map[string]struct{}{}
+	)
+	for _, info := range g.Sessions() {
+		if _, ok := exist[info.Key]; ok || !filter(info) {
+			continue
+		}
+		exist[info.Key] = struct{}{}
+		keys = append(keys, info.Key)
+	}
+	return g.Broadcast(ctx, keys, activeMsg, concurrency)
+}
+
+func (g *GoJT808) broadcastOne(ctx context.Context, key string, activeMsg *ActiveMessage) BroadcastResult {
+	result := BroadcastResult{Key: key}
+	if err := ctx.Err(); err != nil {
+		result.Status = BroadcastFail
+		result.Message = newErrMessage(err)
+		return result
+	}
+	msg := NewActiveMessage(key, activeMsg.Command, activeMsg.Body, activeMsg.OverTimeDuration)
+	msg.OfflineTTL = activeMsg.OfflineTTL
+	result.Message = g.SendActiveMessageContext(ctx, msg)
+	err := result.Message.ExtensionFields.Err
+	switch {
+	case err == nil:
+		result.Status = BroadcastSuccess
+	case errors.Is(err, ErrActiveQueued):
+		result.Status = BroadcastQueued
+	case errors.Is(err, ErrWriteDataOverTime):
+		result.Status = BroadcastTimeout
+	case errors.Is(err, ErrNotExistKey), errors.Is(err, ErrConnectionClosed):
+		result.Status = BroadcastOffline
+	case errors.Is(err, ErrWriteDataFail):
+		result.Status = BroadcastWriteFail
+	default:
+		result.Status = BroadcastFail
+	}
+	return result
+}
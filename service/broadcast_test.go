@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"testing"
+	"time"
+)
+
+func TestGoJT808Broadcast(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	p8300 := &model.P0x8300{Flag: 1, Text: "broadcast"}
+	activeMsg := NewActiveMessage("", consts.P8300TextInfoDistribution, p8300.Encode(), time.Second)
+	reportChan := make(chan *BroadcastReport, 1)
+	go func() {
+		reportChan <- goJt808.Broadcast(context.Background(), []string{key, "1"}, activeMsg, 2)
+	}()
+	// 终端通用应答平台的文本下发
+	jtMsg := readJTMessage(t, conn)
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0001GeneralRespond)
+	body := &model.P0x8001{
+		RespondSerialNumber: jtMsg.Header.SerialNumber,
+		RespondID:           jtMsg.Header.ID,
+	}
+	if _, err := conn.Write(header.Encode(body.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	report := <-reportChan
+	if len(report.Results) != 2 {
+		t.Fatalf("results = %v", report.Results)
+	}
+	if v := report.Results[0]; v.Key != key || v.Status != BroadcastSuccess {
+		t.Errorf("result[0] = %s %s %v", v.Key, v.Status, v.Message.ExtensionFields.Err)
+	}
+	if v := report.Results[1]; v.Key != "1" || v.Status != BroadcastOffline {
+		t.Errorf("result[1] = %s %s", v.Key, v.Status)
+	}
+
+	// 终端不应答
+	activeMsg.OverTimeDuration = 100 * time.Millisecond
+	report = goJt808.BroadcastFunc(context.Background(), func(info SessionInfo) bool {
+		return info.Key == key
+	}, activeMsg, 0)
+	if len(report.Results) != 1 || report.Count(BroadcastTimeout) != 1 {
+		t.Errorf("results = %v", report.Results)
+	}
+}
+
+func TestGoJT808BroadcastOffline(t *testing.T) {
+	store := NewMemoryOfflineStore()
+	goJt808 := New(WithOfflineStore(store, time.Hour))
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	activeMsg := NewActiveMessage("", consts.P8300TextInfoDistribution, nil, time.Second)
+	activeMsg.OfflineTTL = time.Minute
+	report := goJt808.Broadcast(context.Background(), []string{"1001"}, activeMsg, 1)
+	if report.Count(BroadcastQueued) != 1 {
+		t.Fatalf("results = %v", report.Results)
+	}
+	// 使用指令的有效时间 不是默认的
+	cmds, _ := store.Load("1001")
+	if len(cmds) != 1 || cmds[0].ExpireTime.Sub(cmds[0].CreateTime) != time.Minute {
+		t.Errorf("Load() = %v", cmds)
+	}
+}
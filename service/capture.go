@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// CaptureIn 终端上传的 每次读取到的原始数据 保留了粘包半包的情况.
+	CaptureIn CaptureDirection = "in"
+	// CaptureOut 平台下发的 每次写入的完整报文.
+	CaptureOut CaptureDirection = "out"
+)
+
+const (
+	defaultCaptureMaxSize    = 100 << 20 // 单个抓包文件默认最大100MB
+	defaultCaptureMaxBackups = 5         // 默认保留5个历史文件
+)
+
+type (
+	// CaptureDirection 数据的方向.
+	CaptureDirection string
+
+	// CaptureRecord 一条抓包记录.
+	CaptureRecord struct {
+		// Time 读取或者写入的时间
+		Time time.Time `json:"time"`
+		// Key 终端的唯一标识 还没有加入的为空
+		Key string `json:"key,omitempty"`
+		// Remote 连接的远程地址 回放的时候同一个地址使用同一个连接
+		Remote string `json:"remote"`
+		// Direction 数据的方向
+		Direction CaptureDirection `json:"direction"`
+		// Data 原始数据 文件中是十六进制字符串
+		Data HexData `json:"data"`
+	}
+
+	// HexData json编码成十六进制字符串 方便直接复制使用.
+	HexData []byte
+
+	// Capturer 记录终端上传和平台下发的原始数据 在读写的协程中同步调用.
+	Capturer interface {
+		Capture(record CaptureRecord)
+	}
+
+	// FileCapture 按照json行写入文件 超过大小后轮转 path.1是最近的历史文件.
+	// 打开或者写入失败的记录在Err 下一次记录的时候重新打开文件.
+	FileCapture struct {
+		mu         sync.Mutex
+		path       string
+		maxSize    int64
+		maxBackups int
+		file       *os.File
+		size       int64
+		closed     bool
+		// err 最近一次失败的错误
+		err error
+	}
+)
+
+func (h HexData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+func (h *HexData) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	v, err := hex.DecodeString(str)
+	if err != nil {
+		return err
+	}
+	*h = v
+	return nil
+}
+
+// NewFileCapture maxSize是单个文件最大字节数 默认100MB maxBackups是保留的历史文件数量 默认5个.
+func NewFileCapture(path string, maxSize int64, maxBackups int) (*FileCapture, error) {
+	if maxSize <= 0 {
+		maxSize = defaultCaptureMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultCaptureMaxBackups
+	}
+	f := &FileCapture{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileCapture) Capture(record CaptureRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+	if f.file == nil {
+		// 之前打开或者轮转失败的 重新打开
+		if err := f.open(); err != nil {
+			f.err = err
+			return
+		}
+	}
+	if f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			f.err = err
+			return
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		f.err = fmt.Errorf("write capture file [%s]: %w", f.path, err)
+		// 写入失败的关闭 下一次重新打开
+		_ = f.file.Close()
+		f.file = nil
+	}
+}
+
+// Err 最近一次打开 轮转或者写入失败的错误 没有失败的为空.
+func (f *FileCapture) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Close 关闭后不再记录.
+func (f *FileCapture) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *FileCapture) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open capture file [%s]: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("stat capture file [%s]: %w", f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate path.n-1重命名为path.n path重命名为path.1 超过maxBackups的删除.
+func (f *FileCapture) rotate() error {
+	_ = f.file.Close()
+	f.file = nil
+	_ = os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return fmt.Errorf("rotate capture file [%s]: %w", f.path, err)
+	}
+	return f.open()
+}
+
+// ReadCaptureFile 读取抓包文件 轮转的历史文件需要按照从旧到新的顺序分别读取.
+func ReadCaptureFile(path string) ([]CaptureRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read capture file [%s]: %w", path, err)
+	}
+	records := make([]CaptureRecord, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("capture file [%s] line [%d]: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
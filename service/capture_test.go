@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.log")
+	capture, err := NewFileCapture(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	now := time.Now()
+	for i := range 5 {
+		capture.Capture(CaptureRecord{
+			Time:      now.Add(time.Duration(i) * time.Second),
+			Key:       "14419999999",
+			Remote:    "127.0.0.1:10000",
+			Direction: CaptureIn,
+			Data:      data,
+		})
+	}
+	_ = capture.Close()
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Errorf("rotate file not exist: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("max backups = 2 but file exist: %v", err)
+	}
+	records, err := ReadCaptureFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || hex.EncodeToString(records[len(records)-1].Data) != _heartBeatMsg ||
+		!records[len(records)-1].Time.Equal(now.Add(4*time.Second)) {
+		t.Errorf("ReadCaptureFile() = %v", records)
+	}
+}
+
+func TestFileCaptureReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.log")
+	capture, err := NewFileCapture(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = capture.Close()
+	}()
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	record := CaptureRecord{Time: time.Now(), Remote: "127.0.0.1:10000", Direction: CaptureIn, Data: data}
+	// 文件已经关闭 写入失败
+	_ = capture.file.Close()
+	capture.Capture(record)
+	if capture.Err() == nil {
+		t.Fatal("Err() want write fail")
+	}
+	// 下一次重新打开文件
+	capture.Capture(record)
+	records, err := ReadCaptureFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || hex.EncodeToString(records[0].Data) != _heartBeatMsg {
+		t.Errorf("ReadCaptureFile() = %v", records)
+	}
+}
+
+func TestReplayParse(t *testing.T) {
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	now := time.Now()
+	// 半包的情况 一个报文分两次读取到
+	records := []CaptureRecord{
+		{Time: now, Remote: "1", Direction: CaptureIn, Data: data[:5]},
+		{Time: now, Remote: "1", Direction: CaptureOut, Data: data},
+		{Time: now.Add(10 * time.Millisecond), Remote: "1", Direction: CaptureIn, Data: data[5:]},
+		{Time: now.Add(20 * time.Millisecond), Remote: "2", Direction: CaptureIn, Data: data},
+	}
+	start := time.Now()
+	msgs, err := ReplayParse(context.Background(), records, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("replay not keep original timing")
+	}
+	if len(msgs) != 2 || msgs[0].Command != consts.T0002HeartBeat || msgs[1].Command != consts.T0002HeartBeat {
+		t.Errorf("ReplayParse() = %v", msgs)
+	}
+}
+
+func TestGoJT808CaptureReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.log")
+	capture, err := NewFileCapture(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := freeAddr(t)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCapture(capture),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	_ = readFrames(t, conn, 1)
+	_ = conn.Close()
+	// 写入成功后才记录 终端读到的时候可能还没有记录
+	var records []CaptureRecord
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline) && len(records) < 2; {
+		time.Sleep(10 * time.Millisecond)
+		if records, err = ReadCaptureFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = capture.Close()
+	if len(records) != 2 || records[0].Direction != CaptureIn || records[1].Direction != CaptureOut ||
+		records[1].Key != "14419999999" || hex.EncodeToString(records[0].Data) != _heartBeatMsg {
+		t.Fatalf("records = %v", records)
+	}
+
+	// 回放给另一个服务
+	replayAddr := freeAddr(t)
+	msgChan := make(chan *Message, 1)
+	replayJt808 := New(
+		WithHostPorts(replayAddr),
+		WithInboundInterceptor(func(msg *Message, next InboundHandler) error {
+			msgChan <- msg
+			return next(msg)
+		}),
+	)
+	go func() {
+		_ = replayJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = replayJt808.Shutdown(context.Background())
+	}()
+	_ = dialAndSend(t, replayAddr, "").Close() // 等待服务启动
+	if err := Replay(context.Background(), replayAddr, records, 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgChan:
+		if msg.Command != consts.T0002HeartBeat {
+			t.Errorf("replay command = %s", msg.Command)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait replay message timeout")
+	}
+}
+
+func TestReplayNetworkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	msgChan := make(chan *Message, 10)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithNetwork("udp"),
+		WithInboundInterceptor(func(msg *Message, next InboundHandler) error {
+			msgChan <- msg
+			return next(msg)
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	records := []CaptureRecord{{Time: time.Now(), Remote: "127.0.0.1:10000", Direction: CaptureIn, Data: data}}
+	// udp没有连接 服务启动前发送的会丢失 重复回放直到收到
+	for i := 0; i < 50; i++ {
+		if err := ReplayNetwork(context.Background(), "udp", addr, records, 1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case msg := <-msgChan:
+			if msg.Command != consts.T0002HeartBeat {
+				t.Errorf("replay command = %s", msg.Command)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Fatal("wait replay message timeout")
+}
@@ -1,52 +1,150 @@
 package service
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
 	"github.com/cuteLittleDevil/go-jt808/protocol/model"
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
 	"io"
 	"log/slog"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type connection struct {
-	conn                  *net.TCPConn
-	handles               map[consts.JT808CommandType]Handler
-	stopOnce              sync.Once
+	conn     net.Conn
+	handles  map[consts.JT808CommandType]Handler
+	stopOnce sync.Once
+	// closeOnce closeChan 主动断开连接的时候关闭 reader限速等待中的时候使用
+	closeOnce             sync.Once
+	closeChan             chan struct{}
 	stopChan              chan struct{}
 	msgChan               chan *Message
 	activeMsgChan         chan *ActiveMessage
 	activeMsgCompleteChan chan *Message
-	reissuePackChan       chan *Message
-	// platformSerialNumber 平台流水号 到了math.MaxUint16后+1重新变成0
-	platformSerialNumber uint16
-	joinFunc             func(message *Message, activeChan chan<- *ActiveMessage) (string, error)
-	leaveFunc            func(key string)
-	key                  string
-	filter               bool
-	terminalEvent        TerminalEventer
-}
-
-func newConnection(conn *net.TCPConn, handles map[consts.JT808CommandType]Handler, terminalEvent TerminalEventer, filter bool,
-	join func(message *Message, activeChan chan<- *ActiveMessage) (string, error), leave func(key string)) *connection {
-	return &connection{
+	// activeMsgCancelChan ctx结束的平台下发指令 不再等待终端应答
+	activeMsgCancelChan chan *ActiveMessage
+	reissuePackChan     chan *Message
+	// platformSerialNumber 平台下一次下发使用的流水号 使用低16位 到了math.MaxUint16后+1重新变成0
+	platformSerialNumber atomic.Uint32
+	// serialNumberStore 平台流水号的存储 为空的时候每次连接从0开始
+	serialNumberStore SerialNumberStore
+	// serialNumberHandedOver 被新连接替换 流水号已经交给新连接 断开的时候不保存
+	serialNumberHandedOver atomic.Bool
+	// serialNumberTakenOver 从被替换的旧连接接手了流水号 加入的时候不从存储恢复
+	serialNumberTakenOver bool
+	hooks                 connectionHooks
+	key                   string
+	filter                bool
+	terminalEvent         TerminalEventer
+	// authenticator 注册鉴权 为空的时候不限制
+	authenticator Authenticator
+	// authenticated 终端是否鉴权成功了
+	authenticated atomic.Bool
+	// leaveReason 终端离开的原因 第一次设置的有效
+	leaveReason atomic.Uint32
+	// idleTimeout 多久没有收到数据就断开 0-不限制
+	idleTimeout time.Duration
+	// heartbeatMultiple 超时时间=心跳间隔*倍数 0-不使用
+	heartbeatMultiple int
+	// heartbeatInterval 终端的心跳间隔 单位秒
+	heartbeatInterval atomic.Uint32
+	// sessionPolicy 终端的key已经在线时的处理策略
+	sessionPolicy SessionPolicy
+	// stats 连接的流量统计
+	stats connectionStats
+	// correlator 关联终端应答和平台下发的指令
+	correlator Correlator
+	// rateLimit 限流 messageBucket和byteBucket只在reader中使用
+	rateLimit     RateLimit
+	messageBucket *tokenBucket
+	byteBucket    *tokenBucket
+	// inbound 终端上传的报文经过拦截器后分发
+	inbound InboundHandler
+	// outbound 写入终端的数据经过拦截器后写入
+	outbound OutboundHandler
+	// subcontract 分包的配置
+	subcontract subcontractConfig
+	// fragments 平台最近下发的分包 终端请求补传的时候使用
+	fragments *fragmentCache
+	// cipher 消息体的RSA加解密 保存了终端的公钥 没有设置平台私钥的为空
+	cipher *jt808.RSACipher
+	// protocolVersion 会话的协议版本 0-还未确定 只在reader中修改
+	protocolVersion atomic.Uint32
+	logger          *slog.Logger
+	// metrics 服务的指标 没有设置的为空
+	metrics *serviceMetrics
+	// capturer 抓包 没有设置的为空
+	capturer Capturer
+}
+
+// connectionHooks 连接和服务之间的回调.
+type connectionHooks struct {
+	join func(message *Message, conn *connection) (string, bool, error)
+	// ready 终端加入(设置了鉴权的情况是鉴权成功)后执行
+	ready func(key string)
+	leave func(key string)
+	// rateLimit 触发了限流
+	rateLimit func(key string, action RateLimitAction)
+	// loadVersion storeVersion 终端注册时判断的协议版本 key是手机号
+	loadVersion  func(phone string) (consts.ProtocolVersionType, bool)
+	storeVersion func(phone string, version consts.ProtocolVersionType)
+	// upgradeNotice 终端上传的升级结果(0x0108)
+	upgradeNotice func(key string, notice *model.T0x0108)
+}
+
+// connectionStats 连接的流量统计 会话查询的时候使用.
+type connectionStats struct {
+	// lastActiveTime 最后一次收到终端数据的时间 unix纳秒
+	lastActiveTime atomic.Int64
+	bytesIn        atomic.Uint64
+	bytesOut       atomic.Uint64
+	messagesIn     atomic.Uint64
+	messagesOut    atomic.Uint64
+}
+
+func newConnection(conn net.Conn, opts *Options, handles map[consts.JT808CommandType]Handler,
+	terminalEvent TerminalEventer, hooks connectionHooks, metrics *serviceMetrics) *connection {
+	c := &connection{
 		conn:                  conn,
 		handles:               handles,
 		stopOnce:              sync.Once{},
 		stopChan:              make(chan struct{}),
+		closeChan:             make(chan struct{}),
 		msgChan:               make(chan *Message, 10),
 		activeMsgChan:         make(chan *ActiveMessage, 3),
 		activeMsgCompleteChan: make(chan *Message, 3),
+		activeMsgCancelChan:   make(chan *ActiveMessage, 3),
 		reissuePackChan:       make(chan *Message, 3),
-		platformSerialNumber:  uint16(0),
-		joinFunc:              join,
-		leaveFunc:             leave,
-		filter:                filter,
+		serialNumberStore:     opts.SerialNumberStore,
+		hooks:                 hooks,
+		filter:                opts.FilterSubcontract,
 		terminalEvent:         terminalEvent,
+		authenticator:         opts.Authenticator,
+		idleTimeout:           opts.IdleTimeout,
+		heartbeatMultiple:     opts.HeartbeatMultiple,
+		sessionPolicy:         opts.SessionPolicy,
+		correlator:            opts.Correlator,
+		rateLimit:             opts.RateLimit,
+		messageBucket:         newTokenBucket(opts.RateLimit.MessagesPerSecond),
+		byteBucket:            newTokenBucket(opts.RateLimit.BytesPerSecond),
+		subcontract:           newSubcontractConfig(opts, metrics),
+		fragments:             newFragmentCache(opts.FragmentCacheSize),
+		logger:                opts.Logger,
+		metrics:               metrics,
+		capturer:              opts.Capturer,
 	}
+	if opts.RSAPrivateKey != nil {
+		c.cipher = jt808.NewRSACipher(opts.RSAPrivateKey)
+	}
+	c.inbound = chainInbound(opts.InboundInterceptors, c.dispatch)
+	c.outbound = chainOutbound(opts.OutboundInterceptors, c.writeFrame)
+	return c
 }
 
 func (c *connection) Start() {
@@ -61,6 +159,10 @@ func (c *connection) reader() {
 		pack    = newPackageParse()
 		join    = false
 	)
+	pack.config = c.subcontract
+	if c.cipher != nil {
+		pack.cipher = c.cipher
+	}
 
 	defer func() {
 		c.stop()
@@ -73,41 +175,69 @@ func (c *connection) reader() {
 		case <-c.stopChan:
 			return
 		default:
+			c.refreshReadDeadline()
 			if n, err := c.conn.Read(curData); err != nil {
+				if errors.Is(err, os.ErrDeadlineExceeded) {
+					c.setLeaveReason(LeaveReasonIdleTimeout)
+					c.logger.Debug("connection idle timeout",
+						slog.String("key", c.key),
+						slog.Duration("timeout", c.currentIdleTimeout()))
+					return
+				}
 				if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
-					slog.Debug("connection close",
+					c.setLeaveReason(LeaveReasonClosed)
+					c.logger.Debug("connection close",
 						slog.Bool("join", join),
-						slog.Any("platform num", c.platformSerialNumber),
+						slog.String("key", c.key),
+						slog.Any("platform seq", c.curPlatformSerialNumber()),
 						slog.Any("err", err))
 					return
 				}
-				slog.Error("read data",
+				c.setLeaveReason(LeaveReasonReadFail)
+				c.logger.Error("read data",
 					slog.Bool("join", join),
-					slog.Any("platform num", c.platformSerialNumber),
+					slog.String("key", c.key),
+					slog.Any("platform seq", c.curPlatformSerialNumber()),
 					slog.Any("err", err))
 				return
 			} else if n > 0 {
+				c.stats.lastActiveTime.Store(time.Now().UnixNano())
+				c.stats.bytesIn.Add(uint64(n))
+				c.capture(CaptureIn, curData[:n])
+				if !c.onRateLimitEvent(c.byteBucket, n) {
+					return
+				}
 				effectiveData := curData[:n]
 				msgs, err := pack.parse(effectiveData)
 				if err != nil {
-					slog.Error("parse data",
+					c.metrics.onParseError(err)
+					c.setLeaveReason(LeaveReasonParseFail)
+					c.logger.Error("parse data",
 						slog.Bool("join", join),
-						slog.Any("platform num", c.platformSerialNumber),
+						slog.String("key", c.key),
+						slog.Any("platform seq", c.curPlatformSerialNumber()),
 						slog.String("effective data", fmt.Sprintf("%x", effectiveData)),
 						slog.Any("err", err))
 					return
 				}
 				if len(msgs) > 0 {
+					for _, msg := range msgs {
+						c.onProtocolVersionEvent(msg)
+					}
 					if !join {
 						if err := c.joinHandle(msgs[0]); err == nil {
 							join = true
-						} else if errors.Is(err, _errKeyExist) {
-							slog.Warn("key",
+						} else if reason, ok := joinFailReason(err); ok {
+							c.setLeaveReason(reason)
+							c.logger.Warn("join fail",
 								slog.String("effective data", fmt.Sprintf("%x", effectiveData)),
 								slog.Any("err", err))
 							return
 						}
 					}
+					if !c.onRateLimitEvent(c.messageBucket, len(msgs)) {
+						return
+					}
 
 					c.handleMessages(msgs)
 				}
@@ -116,56 +246,231 @@ func (c *connection) reader() {
 	}
 }
 
+// onProtocolVersionEvent 2011和2013版本的请求头一样 注册消息判断是2011版本的 会话后续的报文都按照2011版本处理.
+func (c *connection) onProtocolVersionEvent(msg *Message) {
+	header := msg.JTMessage.Header
+	if header.ProtocolVersion != consts.JT808Protocol2019 {
+		phone := header.TerminalPhoneNo
+		if msg.Command == consts.T0100Register && msg.hasComplete() {
+			t0x0100 := &model.T0x0100{}
+			if err := t0x0100.Parse(msg.JTMessage); err == nil {
+				c.protocolVersion.Store(uint32(t0x0100.Version))
+				c.hooks.storeVersion(phone, t0x0100.Version)
+			}
+		}
+		version := consts.ProtocolVersionType(c.protocolVersion.Load())
+		if version == 0 {
+			// 之前连接注册时判断的 没有的情况按照请求头的
+			var ok bool
+			if version, ok = c.hooks.loadVersion(phone); !ok {
+				version = header.ProtocolVersion
+			}
+			c.protocolVersion.Store(uint32(version))
+		}
+		if version == consts.JT808Protocol2011 {
+			header.ProtocolVersion = version
+		}
+	} else {
+		c.protocolVersion.Store(uint32(header.ProtocolVersion))
+	}
+	msg.ProtocolVersion = header.ProtocolVersion
+}
+
+// joinFailReason 加入失败需要断开连接的情况.
+func joinFailReason(err error) (LeaveReason, bool) {
+	switch {
+	case errors.Is(err, _errKeyExist):
+		return LeaveReasonKeyExist, true
+	case errors.Is(err, _errKeyBanned):
+		return LeaveReasonRateLimit, true
+	default:
+	}
+	return 0, false
+}
+
+// onRateLimitEvent 超过限流的处理 返回false的说明需要断开连接.
+func (c *connection) onRateLimitEvent(bucket *tokenBucket, n int) bool {
+	wait := bucket.take(n, time.Now())
+	if wait <= 0 {
+		return true
+	}
+	action := c.rateLimit.Action
+	// 还没有加入的 使用终端的ip禁止接入
+	key := c.key
+	if key == "" {
+		key = remoteHost(c.conn.RemoteAddr())
+	}
+	c.hooks.rateLimit(key, action)
+	if v, ok := c.terminalEvent.(RateLimitEventer); ok {
+		v.OnRateLimitEvent(c.key, action)
+	}
+	if action == RateLimitDisconnect || action == RateLimitBan {
+		c.setLeaveReason(LeaveReasonRateLimit)
+		c.logger.Warn("rate limit",
+			slog.String("key", c.key),
+			slog.String("action", action.String()))
+		return false
+	}
+	// 暂停读取 终端的数据堆积在tcp缓冲区
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.closeChan:
+		return false
+	}
+}
+
 func (c *connection) joinHandle(msg *Message) error {
-	key, err := c.joinFunc(msg, c.activeMsgChan)
+	key, conflict, err := c.hooks.join(msg, c)
 	if err == nil {
 		c.key = key
+		c.loadSerialNumber()
+	}
+	if v, ok := c.terminalEvent.(SessionConflictEventer); ok && conflict {
+		v.OnSessionConflictEvent(key, c.sessionPolicy)
 	}
 
 	c.terminalEvent.OnJoinEvent(msg, key, err)
+	if err == nil && c.authenticator == nil {
+		c.hooks.ready(key)
+	}
 	return err
 }
 
 func (c *connection) handleMessages(msgs []*Message) {
+	c.stats.messagesIn.Add(uint64(len(msgs)))
+	now := time.Now()
 	for _, msg := range msgs {
 		msg.Key = c.key
-		if handler, ok := c.handles[msg.Command]; ok {
-			msg.Handler = handler
-			if msg.Command == consts.P8003ReissueSubcontractingRequest {
-				c.reissuePackChan <- msg
-				continue
-			}
-			c.onReadExecutionEvent(msg)
-		} else {
+		msg.readTime = now
+		handler, ok := c.handles[msg.Command]
+		if !ok {
 			c.terminalEvent.OnNotSupportedEvent(msg)
 			continue
 		}
+		msg.Handler = handler
+		if msg.Command == consts.P8003ReissueSubcontractingRequest {
+			c.reissuePackChan <- msg
+			continue
+		}
+		if !msg.ExtensionFields.SubcontractComplete {
+			// 分包合并后的不重复统计
+			c.metrics.onMessage("in", msg.Command)
+		}
+		c.onInboundEvent(msg)
+	}
+}
+
+// onInboundEvent 经过拦截器后分发 被拦截的报文只有设置了回复才回复.
+func (c *connection) onInboundEvent(msg *Message) {
+	err := c.inbound(msg)
+	if msg.dispatched {
+		if err != nil {
+			c.msgLogger(msg).Warn("inbound reject",
+				slog.Any("err", err))
+			c.close(LeaveReasonRejected)
+		}
+		return
+	}
+	if err == nil && msg.reply == nil {
+		return
+	}
+	handle := &replyHandle{Handler: msg.Handler, silent: true}
+	if msg.reply != nil {
+		handle.hasReply = true
+		handle.protocol = msg.reply.command
+		handle.body = msg.reply.body
+	}
+	if err != nil {
+		c.msgLogger(msg).Warn("inbound reject",
+			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
+			slog.Any("err", err))
+		handle.leaveReason = LeaveReasonRejected
+	}
+	msg.Handler = handle
+	c.msgChan <- msg
+}
+
+// dispatch 拦截器链的最后 分发给Handler.
+func (c *connection) dispatch(msg *Message) error {
+	msg.dispatched = true
+	if msg.reply != nil {
+		msg.Handler = &replyHandle{
+			Handler:  msg.Handler,
+			hasReply: true,
+			protocol: msg.reply.command,
+			body:     msg.reply.body,
+		}
+	}
+	if c.heartbeatMultiple > 0 && msg.Command == consts.T0104QueryParameter && msg.hasComplete() {
+		t0x0104 := &model.T0x0104{}
+		if err := t0x0104.Parse(msg.JTMessage); err == nil {
+			c.onHeartbeatParamEvent(&t0x0104.TerminalParamDetails)
+		}
+	}
+	if c.authenticator != nil && msg.hasComplete() && !c.onAuthEvent(msg) {
+		// 未鉴权的报文 只回复失败
 		c.msgChan <- msg
+		return nil
+	}
+	// 终端公钥和升级结果 鉴权通过后才处理
+	if c.cipher != nil && msg.Command == consts.T0A00TerminalRSAPublicKey && msg.hasComplete() {
+		t0x0A00 := &model.T0x0A00{}
+		if err := t0x0A00.Parse(msg.JTMessage); err == nil {
+			c.cipher.SetPublicKey(t0x0A00.PublicKey())
+		}
 	}
+	if msg.Command == consts.T0108UpgradeNotice && msg.hasComplete() {
+		t0x0108 := &model.T0x0108{}
+		if err := t0x0108.Parse(msg.JTMessage); err == nil {
+			c.hooks.upgradeNotice(c.key, t0x0108)
+		}
+	}
+	c.onReadExecutionEvent(msg)
+	c.msgChan <- msg
+	return nil
 }
 
 func (c *connection) write() {
 	record := map[uint16]*ActiveMessage{}
+	timer := newActiveTimer()
+	defer timer.stop()
 	for {
 		select {
 		case <-c.stopChan:
-			clear(record)
+			c.onActiveEventClosed(record)
 			return
 		case activeMsg, ok := <-c.activeMsgChan: // 平台主动下发的
 			if ok {
-				c.onActiveEvent(activeMsg, record)
+				c.onActiveEvent(activeMsg, record, timer)
 			}
 		case msg, ok := <-c.activeMsgCompleteChan: // 平台主动下发的完成情况
 			if ok {
 				c.onActiveEventComplete(msg, record)
 			}
+		case now := <-timer.C(): // 平台主动下发的超时情况
+			c.onActiveEventOverTime(now, record, timer)
+		case activeMsg := <-c.activeMsgCancelChan: // 平台主动下发的ctx结束了
+			seq := activeMsg.ExtensionFields.PlatformSeq
+			if record[seq] == activeMsg {
+				delete(record, seq)
+			}
 		case subPackMsg, ok := <-c.reissuePackChan: // 分包补传的
 			if ok {
 				c.onSubPackReplyEvent(subPackMsg)
 			}
 		case msg, ok := <-c.msgChan: // 终端上传的
 			if ok {
-				if len(record) > 0 && msg.hasComplete() { // 说明现在有主动的请求 等待回复中
+				reply, hasReply := msg.Handler.(*replyHandle)
+				if !hasReply && msg.Command == consts.T0005ReissueSubcontractingRequest {
+					// 终端请求补传平台下发的分包
+					c.onReissueRequestEvent(msg)
+					continue
+				}
+				// 说明现在有主动的请求 等待回复中 未鉴权或者被拦截的报文不处理
+				if len(record) > 0 && msg.hasComplete() && !(hasReply && reply.silent) {
 					if c.onActiveRespondEvent(record, msg) {
 						continue
 					}
@@ -173,6 +478,10 @@ func (c *connection) write() {
 				if msg.hasComplete() || !c.filter { // 默认完整包才触发回复
 					c.defaultReplyEvent(msg)
 				}
+				if hasReply && reply.leaveReason != 0 {
+					// 注册鉴权失败或者被拦截器拒绝的 回复后断开连接
+					c.close(reply.leaveReason)
+				}
 			}
 		}
 	}
@@ -180,11 +489,15 @@ func (c *connection) write() {
 
 func (c *connection) stop() {
 	c.stopOnce.Do(func() {
-		c.leaveFunc(c.key)
+		c.saveSerialNumber()
+		c.metrics.onLeave(c.currentLeaveReason())
+		c.hooks.leave(c.key)
 		c.terminalEvent.OnLeaveEvent(c.key)
+		if v, ok := c.terminalEvent.(LeaveReasonEventer); ok {
+			v.OnLeaveReasonEvent(c.key, c.currentLeaveReason())
+		}
 		close(c.stopChan)
 		_ = c.conn.Close()
-		clear(c.handles)
 		close(c.msgChan)
 		close(c.activeMsgChan)
 		close(c.activeMsgCompleteChan)
@@ -192,13 +505,56 @@ func (c *connection) stop() {
 	})
 }
 
+// close 主动断开连接 reader读取失败后退出.
+func (c *connection) close(reason LeaveReason) {
+	c.setLeaveReason(reason)
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+	})
+	_ = c.conn.Close()
+}
+
+func (c *connection) setLeaveReason(reason LeaveReason) {
+	c.leaveReason.CompareAndSwap(0, uint32(reason))
+}
+
+func (c *connection) currentLeaveReason() LeaveReason {
+	if v := c.leaveReason.Load(); v != 0 {
+		return LeaveReason(v)
+	}
+	return LeaveReasonClosed
+}
+
+func (c *connection) refreshReadDeadline() {
+	if timeout := c.currentIdleTimeout(); timeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+}
+
+// currentIdleTimeout 知道终端心跳间隔的情况 优先使用心跳间隔计算.
+func (c *connection) currentIdleTimeout() time.Duration {
+	if c.heartbeatMultiple > 0 {
+		if interval := c.heartbeatInterval.Load(); interval > 0 {
+			return time.Duration(interval) * time.Second * time.Duration(c.heartbeatMultiple)
+		}
+	}
+	return c.idleTimeout
+}
+
+// onHeartbeatParamEvent 从终端参数中获取心跳间隔(参数0x0001).
+func (c *connection) onHeartbeatParamEvent(details *model.TerminalParamDetails) {
+	if v := details.T0x001HeartbeatInterval; v.ID == 0x0001 && v.Value > 0 {
+		c.heartbeatInterval.Store(v.Value)
+	}
+}
+
 func (c *connection) defaultReplyEvent(msg *Message) {
 	if has := msg.HasReply(); !has {
 		return
 	}
 	body, err := msg.ReplyBody(msg.JTMessage)
 	if err != nil {
-		slog.Warn("reply body fail",
+		c.msgLogger(msg).Warn("reply body fail",
 			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
 			slog.Any("err", err))
 		return
@@ -207,14 +563,21 @@ func (c *connection) defaultReplyEvent(msg *Message) {
 	header.ReplyID = uint16(msg.ReplyProtocol())
 	seq := c.curSeq()
 	header.PlatformSerialNumber = seq
-	data := header.Encode(body)
-
-	if _, err = c.conn.Write(data); err != nil {
-		slog.Warn("write fail",
+	data, err := c.encode(header, msg.ReplyProtocol(), body)
+	frame := &OutboundFrame{Key: c.key, Command: msg.ReplyProtocol(), Seq: seq, Data: data}
+	if err == nil {
+		err = c.outbound(frame)
+	}
+	data = frame.Data
+	if err != nil {
+		c.frameLogger(frame).Warn("write fail",
 			slog.String("data", fmt.Sprintf("%x", data)),
 			slog.Any("err", err))
 		msg.ExtensionFields.Err = errors.Join(ErrWriteDataFail, err)
 	}
+	if err == nil {
+		c.metrics.onReply(msg.Command, msg.readTime)
+	}
 	msg.ExtensionFields.PlatformCommand = msg.ReplyProtocol()
 	msg.ExtensionFields.PlatformSeq = seq
 	msg.ExtensionFields.PlatformData = data
@@ -226,10 +589,19 @@ func (c *connection) onSubPackReplyEvent(msg *Message) {
 	seq := c.curSeq()
 	header.PlatformSerialNumber = seq
 	header.ReplyID = uint16(consts.P8003ReissueSubcontractingRequest)
-	data := header.Encode(msg.JTMessage.Body)
-
-	if _, err := c.conn.Write(data); err != nil {
-		slog.Warn("write fail",
+	data, err := c.encode(header, consts.P8003ReissueSubcontractingRequest, msg.JTMessage.Body)
+	frame := &OutboundFrame{
+		Key:     c.key,
+		Command: consts.P8003ReissueSubcontractingRequest,
+		Seq:     seq,
+		Data:    data,
+	}
+	if err == nil {
+		err = c.outbound(frame)
+	}
+	data = frame.Data
+	if err != nil {
+		c.frameLogger(frame).Warn("write fail",
 			slog.String("data", fmt.Sprintf("%x", data)),
 			slog.Any("err", err))
 		msg.ExtensionFields.Err = errors.Join(ErrWriteDataFail, err)
@@ -239,12 +611,30 @@ func (c *connection) onSubPackReplyEvent(msg *Message) {
 	c.onWriteExecutionEvent(msg)
 }
 
-func (c *connection) onActiveEvent(activeMsg *ActiveMessage, record map[uint16]*ActiveMessage) {
+func (c *connection) onActiveEvent(activeMsg *ActiveMessage, record map[uint16]*ActiveMessage, timer *activeTimer) {
+	if activeMsg.reply.canceled.Load() {
+		// ctx已经结束了 不再下发
+		return
+	}
 	header := activeMsg.header
-	seq := c.curSeq()
-	header.PlatformSerialNumber = seq
-	header.ReplyID = uint16(activeMsg.Command)
-	data := header.Encode(activeMsg.Body)
+	if c.heartbeatMultiple > 0 && activeMsg.Command == consts.P8103SetTerminalParams {
+		p8103 := &model.P0x8103{}
+		if err := p8103.Parse(&jt808.JTMessage{Header: header, Body: activeMsg.Body}); err == nil {
+			c.onHeartbeatParamEvent(&p8103.TerminalParamDetails)
+		}
+	}
+	seq, data, err := c.encodeActive(header, activeMsg)
+	frame := &OutboundFrame{
+		Key:        c.key,
+		Command:    activeMsg.Command,
+		Seq:        seq,
+		Data:       data,
+		ActiveSend: true,
+	}
+	if err == nil {
+		err = c.outbound(frame)
+	}
+	data = frame.Data
 	activeMsg.ExtensionFields = struct {
 		PlatformSeq uint16 `json:"platformSeq,omitempty"`
 		Data        []byte `json:"data,omitempty"`
@@ -253,7 +643,6 @@ func (c *connection) onActiveEvent(activeMsg *ActiveMessage, record map[uint16]*
 		Data:        data,
 	}
 	record[seq] = activeMsg
-	_, err := c.conn.Write(data)
 	replyMsg := newActiveMessage(seq, activeMsg.Command, data, err)
 	if v, ok := c.handles[activeMsg.Command]; ok {
 		replyMsg.Handler = v
@@ -266,104 +655,62 @@ func (c *connection) onActiveEvent(activeMsg *ActiveMessage, record map[uint16]*
 		if activeMsg.OverTimeDuration > 0 {
 			duration = activeMsg.OverTimeDuration
 		}
-		go func(overtimeMsg *Message) {
-			time.Sleep(duration)
-			select {
-			case <-c.stopChan:
-				return
-			default:
-			}
-			overtimeMsg.ExtensionFields.Err = errors.Join(ErrWriteDataOverTime,
-				fmt.Errorf("overtime is [%.2f]second", duration.Seconds()))
-			c.activeMsgCompleteChan <- overtimeMsg
-		}(replyMsg)
+		timer.add(&activeTimeout{
+			deadline:  time.Now().Add(duration),
+			duration:  duration,
+			seq:       seq,
+			activeMsg: activeMsg,
+			replyMsg:  replyMsg,
+		})
 	}
 }
 
-func (c *connection) onActiveRespondEvent(record map[uint16]*ActiveMessage, msg *Message) bool {
-	type respond struct {
-		JT808Handler
-		HasRespondFunc func(seq uint16) bool
+// onActiveEventOverTime 超时的平台下发指令 已经应答了的忽略.
+func (c *connection) onActiveEventOverTime(now time.Time, record map[uint16]*ActiveMessage, timer *activeTimer) {
+	for _, v := range timer.expired(now) {
+		if record[v.seq] != v.activeMsg {
+			continue
+		}
+		v.replyMsg.ExtensionFields.Err = errors.Join(ErrWriteDataOverTime,
+			fmt.Errorf("overtime is [%.2f]second", v.duration.Seconds()))
+		c.onActiveEventComplete(v.replyMsg, record)
 	}
-	tmp := respond{
-		JT808Handler:   nil,
-		HasRespondFunc: nil,
+}
+
+// cancelActive ctx结束了 删除等待中的平台下发指令.
+func (c *connection) cancelActive(activeMsg *ActiveMessage) {
+	select {
+	case c.activeMsgCancelChan <- activeMsg:
+	case <-c.stopChan:
 	}
-	switch msg.Command {
-	case consts.T0001GeneralRespond:
-		t0x0001 := &model.T0x0001{}
-		tmp.JT808Handler = t0x0001
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			// 如果是这些命令的话 等待后续应答 如 8801 -> 8805
-			switch record[seq].Command {
-			case consts.P8801CameraShootImmediateCommand, consts.P9003QueryTerminalAudioVideoProperties,
-				consts.P9205QueryResourceList, consts.P9206FileUploadInstructions:
-				return false
-			default:
-				return seq == t0x0001.SerialNumber
-			}
-		}
-	case consts.T0104QueryParameter:
-		t0x0104 := &model.T0x0104{}
-		tmp.JT808Handler = t0x0104
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x0104.RespondSerialNumber
-		}
-	case consts.T1003UploadAudioVideoAttr:
-		t0x1003 := &model.T0x1003{}
-		tmp.JT808Handler = t0x1003
-		tmp.HasRespondFunc = func(_ uint16) bool {
-			return true
-		}
-	case consts.T0201QueryLocation:
-		t0x0201 := &model.T0x0201{}
-		tmp.JT808Handler = t0x0201
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x0201.RespondSerialNumber
-		}
-	case consts.T0302QuestionAnswer:
-		t0x0302 := &model.T0x0302{}
-		tmp.JT808Handler = t0x0302
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x0302.SerialNumber
-		}
-	case consts.T1205UploadAudioVideoResourceList:
-		t0x1205 := &model.T0x1205{}
-		tmp.JT808Handler = t0x1205
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x1205.SerialNumber
-		}
-	case consts.T1206FileUploadCompleteNotice:
-		t0x1206 := &model.T0x1206{}
-		tmp.JT808Handler = t0x1206
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x1206.RespondSerialNumber
-		}
-	case consts.T0805CameraShootImmediately:
-		t0x0805 := &model.T0x0805{}
-		tmp.JT808Handler = t0x0805
-		tmp.HasRespondFunc = func(seq uint16) bool {
-			return seq == t0x0805.RespondSerialNumber
-		}
+}
+
+func (c *connection) onActiveRespondEvent(record map[uint16]*ActiveMessage, msg *Message) bool {
+	pending := make(map[uint16]ReplyMatcher, len(record))
+	for seq, v := range record {
+		pending[seq] = c.replyMatcher(v.Command)
 	}
-	if tmp.HasRespondFunc != nil {
-		if err := tmp.Parse(msg.JTMessage); err != nil {
-			slog.Warn("parse fail",
-				slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
-				slog.Any("err", err))
-			return true
-		}
-		for k := range record {
-			if tmp.HasRespondFunc(k) {
-				msg.ExtensionFields.PlatformSeq = k
-				msg.ExtensionFields.TerminalCommand = tmp.Protocol()
-				c.activeMsgCompleteChan <- msg
-				return true
-			}
-		}
+	seq, ok, err := c.correlator.Correlate(msg, pending)
+	if err != nil {
+		c.msgLogger(msg).Warn("parse fail",
+			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
+			slog.Any("err", err))
+		return true
+	}
+	if ok {
+		msg.ExtensionFields.PlatformSeq = seq
+		msg.ExtensionFields.TerminalCommand = msg.Command
+		c.activeMsgCompleteChan <- msg
 	}
+	return ok
+}
 
-	return false
+// replyMatcher 平台指令的处理实现了ReplyMatcher的优先使用.
+func (c *connection) replyMatcher(command consts.JT808CommandType) ReplyMatcher {
+	if v, ok := c.handles[command].(ReplyMatcher); ok {
+		return v
+	}
+	return newReplyRule(command)
 }
 
 func (c *connection) onActiveEventComplete(msg *Message, record map[uint16]*ActiveMessage) {
@@ -373,19 +720,124 @@ func (c *connection) onActiveEventComplete(msg *Message, record map[uint16]*Acti
 		msg.ExtensionFields.PlatformCommand = v.Command
 		msg.ExtensionFields.ActiveSend = true
 		c.onWriteExecutionEvent(msg)
-		v.replyChan <- msg
+		v.reply.reply(msg)
 
 		delete(record, seq)
 	}
 }
 
+// onActiveEventClosed 连接关闭了 还在等待中的平台下发指令直接返回失败.
+func (c *connection) onActiveEventClosed(record map[uint16]*ActiveMessage) {
+	for seq, v := range record {
+		v.reply.reply(newErrMessage(errors.Join(ErrConnectionClosed,
+			fmt.Errorf("key=[%s] seq=[%d]", c.key, seq))))
+	}
+	clear(record)
+	// stop会关闭activeMsgChan 已经提交的指令也需要返回
+	for activeMsg := range c.activeMsgChan {
+		activeMsg.reply.reply(newErrMessage(errors.Join(ErrConnectionClosed,
+			fmt.Errorf("key=[%s]", c.key))))
+	}
+}
+
+// onAuthEvent 注册鉴权 返回false的说明终端未鉴权 报文不需要处理.
+func (c *connection) onAuthEvent(msg *Message) bool {
+	switch msg.Command {
+	case consts.T0100Register:
+		c.onRegisterEvent(msg)
+	case consts.T0102RegisterAuth:
+		c.onRegisterAuthEvent(msg)
+	default:
+		if c.authenticated.Load() {
+			return true
+		}
+		p8001 := &model.P0x8001{
+			RespondSerialNumber: msg.JTMessage.Header.SerialNumber,
+			RespondID:           msg.JTMessage.Header.ID,
+			Result:              1,
+		}
+		// 不管原来是否需要回复 都使用通用应答回复失败
+		msg.Handler = &replyHandle{
+			Handler:  msg.Handler,
+			hasReply: true,
+			protocol: consts.P8001GeneralRespond,
+			body:     p8001.Encode(),
+			silent:   true,
+		}
+		return false
+	}
+	return true
+}
+
+func (c *connection) onRegisterEvent(msg *Message) {
+	replyHandle := &replyHandle{Handler: msg.Handler}
+	msg.Handler = replyHandle
+	t0x0100 := &model.T0x0100{}
+	if err := t0x0100.Parse(msg.JTMessage); err != nil {
+		c.msgLogger(msg).Warn("register parse fail",
+			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
+			slog.Any("err", err))
+		return
+	}
+	result, code, err := c.authenticator.Register(c.key, t0x0100)
+	if err != nil {
+		c.msgLogger(msg).Warn("register fail",
+			slog.Any("err", err))
+		return
+	}
+	p8100 := &model.P0x8100{
+		RespondSerialNumber: msg.JTMessage.Header.SerialNumber,
+		Result:              byte(result),
+		AuthCode:            code,
+	}
+	replyHandle.hasReply = true
+	replyHandle.body = p8100.Encode()
+	if result != RegisterSuccess {
+		replyHandle.leaveReason = LeaveReasonAuthFail
+	}
+}
+
+func (c *connection) onRegisterAuthEvent(msg *Message) {
+	replyHandle := &replyHandle{Handler: msg.Handler}
+	msg.Handler = replyHandle
+	t0x0102 := &model.T0x0102{}
+	if err := t0x0102.Parse(msg.JTMessage); err != nil {
+		c.msgLogger(msg).Warn("auth parse fail",
+			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
+			slog.Any("err", err))
+		return
+	}
+	pass, err := c.authenticator.Auth(c.key, t0x0102)
+	if err != nil {
+		c.msgLogger(msg).Warn("auth fail",
+			slog.Any("err", err))
+		return
+	}
+	if old := c.authenticated.Swap(pass); !old && pass {
+		c.hooks.ready(c.key)
+	}
+	result := byte(0)
+	if !pass {
+		result = 1
+	}
+	p8001 := &model.P0x8001{
+		RespondSerialNumber: msg.JTMessage.Header.SerialNumber,
+		RespondID:           msg.JTMessage.Header.ID,
+		Result:              result,
+	}
+	replyHandle.hasReply = true
+	replyHandle.body = p8001.Encode()
+	if !pass {
+		replyHandle.leaveReason = LeaveReasonAuthFail
+	}
+}
+
 func (c *connection) onReadExecutionEvent(msg *Message) {
 	if c.filter && !msg.hasComplete() {
 		return
 	}
 	if msg.Handler == nil {
-		slog.Warn("Handler is nil",
-			slog.String("read", msg.Header.String()))
+		c.msgLogger(msg).Warn("Handler is nil on read")
 		return
 	}
 	msg.Handler.OnReadExecutionEvent(msg)
@@ -398,8 +850,7 @@ func (c *connection) onWriteExecutionEvent(msg *Message) {
 		return
 	}
 	if msg.Handler == nil {
-		slog.Warn("Handler is nil",
-			slog.String("write", msg.Header.String()))
+		c.msgLogger(msg).Warn("Handler is nil on write")
 		return
 	}
 	msg.Handler.OnWriteExecutionEvent(*msg)
@@ -407,9 +858,188 @@ func (c *connection) onWriteExecutionEvent(msg *Message) {
 	c.terminalEvent.OnWriteExecutionEvent(*msg)
 }
 
+// writeFrame 拦截器链的最后 写入数据给终端 同时记录发送的流量.
+func (c *connection) writeFrame(frame *OutboundFrame) error {
+	n, err := c.conn.Write(frame.Data)
+	c.stats.bytesOut.Add(uint64(n))
+	if err == nil {
+		c.stats.messagesOut.Add(1)
+		c.metrics.onMessage("out", frame.Command)
+		c.capture(CaptureOut, frame.Data)
+	}
+	return err
+}
+
+// capture 设置了抓包的情况 记录原始数据 读取的缓冲区会复用 所以复制一份.
+func (c *connection) capture(direction CaptureDirection, data []byte) {
+	if c.capturer == nil {
+		return
+	}
+	c.capturer.Capture(CaptureRecord{
+		Time:      time.Now(),
+		Key:       c.key,
+		Remote:    c.conn.RemoteAddr().String(),
+		Direction: direction,
+		Data:      bytes.Clone(data),
+	})
+}
+
+// encode 平台下发的数据 分包的情况每个包占用一个流水号 并且缓存起来用于终端请求补传.
+// 终端上传了公钥的情况 消息体使用终端的公钥加密 加密失败的不下发.
+func (c *connection) encode(header *jt808.Header, command consts.JT808CommandType, body []byte) ([]byte, error) {
+	header.Property.EncryptMethod = 0
+	if c.cipher != nil && c.cipher.PublicKey() != nil && command != consts.P8A00PlatformRSAPublicKey {
+		// 有终端的公钥就加密 平台公钥本身不加密
+		header.Cipher = c.cipher
+		header.Property.EncryptMethod = 1
+		encrypted, err := header.Encrypt(body)
+		if err != nil {
+			return nil, err
+		}
+		body = encrypted
+	}
+	data := header.Encode(body)
+	if header.Property.PacketFragmented == 1 {
+		frames := splitFrames(data)
+		c.platformSerialNumber.Add(uint32(len(frames) - 1))
+		c.fragments.add(header.PlatformSerialNumber, command, frames)
+	}
+	return data, nil
+}
+
+// encodeActive 平台主动下发的数据 逐个下发的分包在连接上第一次下发的时候预留全部分包的流水号.
+// 重新下发的分包使用相同的流水号 断线重连后的新连接重新预留.
+func (c *connection) encodeActive(header *jt808.Header, activeMsg *ActiveMessage) (uint16, []byte, error) {
+	header.ReplyID = uint16(activeMsg.Command)
+	sub := activeMsg.subPackage
+	if sub == nil {
+		seq := c.curSeq()
+		header.PlatformSerialNumber = seq
+		data, err := c.encode(header, activeMsg.Command, activeMsg.Body)
+		return seq, data, err
+	}
+	group := sub.group
+	if group.conn != c {
+		group.conn = c
+		group.firstSeq = c.curSeq()
+		c.platformSerialNumber.Add(uint32(sub.sum - 1))
+		c.fragments.add(group.firstSeq, activeMsg.Command, make([][]byte, sub.sum))
+	}
+	header.PlatformSerialNumber = group.firstSeq
+	data := header.EncodeSubPackage(activeMsg.Body, sub.no, sub.sum)
+	if v, ok := c.fragments.load(group.firstSeq); ok && int(sub.no) <= len(v.frames) {
+		// 终端请求补传的时候使用
+		v.frames[sub.no-1] = data
+	}
+	return group.firstSeq + sub.no - 1, data, nil
+}
+
+// onReissueRequestEvent 终端请求补传分包(0x0005) 只重新发送请求的包.
+func (c *connection) onReissueRequestEvent(msg *Message) {
+	t0x0005 := &model.T0x0005{}
+	if err := t0x0005.Parse(msg.JTMessage); err != nil {
+		c.msgLogger(msg).Warn("reissue request parse fail",
+			slog.String("terminal data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)),
+			slog.Any("err", err))
+		return
+	}
+	seq := t0x0005.OriginalSerialNumber
+	v, ok := c.fragments.load(seq)
+	if !ok {
+		c.logger.Warn("reissue fragments not found",
+			slog.String("key", c.key),
+			slog.Any("seq", seq))
+		return
+	}
+	data := make([]byte, 0)
+	for _, no := range t0x0005.AgainPackageList {
+		if no == 0 || int(no) > len(v.frames) || v.frames[no-1] == nil {
+			c.logger.Warn("reissue fragment no invalid",
+				slog.String("key", c.key),
+				slog.Any("seq", seq),
+				slog.Any("no", no),
+				slog.Int("sum", len(v.frames)))
+			continue
+		}
+		frame := &OutboundFrame{Key: c.key, Command: v.command, Seq: seq + no - 1, Data: v.frames[no-1]}
+		if err := c.outbound(frame); err != nil {
+			c.frameLogger(frame).Warn("write fail",
+				slog.String("data", fmt.Sprintf("%x", frame.Data)),
+				slog.Any("err", err))
+			msg.ExtensionFields.Err = errors.Join(ErrWriteDataFail, err)
+			break
+		}
+		data = append(data, frame.Data...)
+	}
+	msg.ExtensionFields.PlatformCommand = v.command
+	msg.ExtensionFields.PlatformSeq = seq
+	msg.ExtensionFields.PlatformData = data
+	c.onWriteExecutionEvent(msg)
+}
+
 func (c *connection) curSeq() uint16 {
-	defer func() {
-		c.platformSerialNumber++
-	}()
-	return c.platformSerialNumber
+	return uint16(c.platformSerialNumber.Add(1) - 1)
+}
+
+// curPlatformSerialNumber 平台下一次下发使用的流水号.
+func (c *connection) curPlatformSerialNumber() uint16 {
+	return uint16(c.platformSerialNumber.Load())
+}
+
+// msgLogger 终端消息相关的日志 统一带上key phone command seq.
+func (c *connection) msgLogger(msg *Message) *slog.Logger {
+	attrs := []any{slog.String("key", c.key), slog.String("command", msg.Command.String())}
+	if msg.JTMessage != nil && msg.JTMessage.Header != nil {
+		attrs = append(attrs,
+			slog.String("phone", msg.JTMessage.Header.TerminalPhoneNo),
+			slog.Any("seq", msg.JTMessage.Header.SerialNumber))
+	}
+	return c.logger.With(attrs...)
+}
+
+// frameLogger 平台下发数据相关的日志 统一带上key command seq.
+func (c *connection) frameLogger(frame *OutboundFrame) *slog.Logger {
+	return c.logger.With(
+		slog.String("key", frame.Key),
+		slog.String("command", frame.Command.String()),
+		slog.Any("seq", frame.Seq))
+}
+
+// loadSerialNumber 终端加入后 从存储中恢复上一次连接的流水号.
+func (c *connection) loadSerialNumber() {
+	if c.serialNumberStore == nil || c.serialNumberTakenOver {
+		return
+	}
+	seq, ok, err := c.serialNumberStore.Load(c.key)
+	if err != nil {
+		c.logger.Warn("load serial number",
+			slog.String("key", c.key),
+			slog.Any("err", err))
+		return
+	}
+	if ok {
+		c.platformSerialNumber.Store(uint32(seq))
+	}
+}
+
+// saveSerialNumber 连接断开的时候保存流水号 下一次连接继续使用.
+func (c *connection) saveSerialNumber() {
+	if c.serialNumberStore == nil || c.key == "" || c.serialNumberHandedOver.Load() {
+		return
+	}
+	if err := c.serialNumberStore.Save(c.key, c.curPlatformSerialNumber()); err != nil {
+		c.logger.Warn("save serial number",
+			slog.String("key", c.key),
+			slog.Any("err", err))
+	}
+}
+
+// handOverSerialNumber 被next替换 旧连接的stop是异步的 所以直接把流水号交给next 会话管理中使用.
+func (c *connection) handOverSerialNumber(next *connection) {
+	if c.serialNumberStore == nil {
+		return
+	}
+	c.serialNumberHandedOver.Store(true)
+	next.platformSerialNumber.Store(c.platformSerialNumber.Load())
+	next.serialNumberTakenOver = true
 }
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"maps"
+	"slices"
+)
+
+type (
+	// ReplyMatcher 平台下发指令的终端应答 WithCustomHandleFunc注册的平台指令处理实现后 用于关联终端的应答.
+	ReplyMatcher interface {
+		// ReplyCommands 最终应答的终端指令 如0x8801是0x0805 终端先回复的0x0001不算.
+		ReplyCommands() []consts.JT808CommandType
+		// ReplySerialNumber 从终端应答中获取平台下发的流水号 ok=false的说明应答中没有流水号 指令匹配就算应答.
+		ReplySerialNumber(msg *Message) (seq uint16, ok bool, err error)
+	}
+
+	// Correlator 关联终端上传的报文和等待应答中的平台下发指令.
+	Correlator interface {
+		// Correlate pending是等待应答中的平台流水号和对应的匹配规则 返回应答的平台流水号 ok=false的不是应答.
+		Correlate(msg *Message, pending map[uint16]ReplyMatcher) (seq uint16, ok bool, err error)
+	}
+
+	defaultCorrelator struct{}
+
+	// replyRule 默认的平台指令和终端应答的关系.
+	replyRule []consts.JT808CommandType
+)
+
+// _defaultReplyRules 没有列出的平台指令 默认终端通用应答0x0001.
+var _defaultReplyRules = map[consts.JT808CommandType]replyRule{
+	consts.P8104QueryTerminalParams:     {consts.T0104QueryParameter, consts.T0001GeneralRespond},
+	consts.P8106QuerySpecifyParam:       {consts.T0104QueryParameter, consts.T0001GeneralRespond},
+	consts.P8107QueryTerminalProperties: {consts.T0107QueryAttribute, consts.T0001GeneralRespond},
+	consts.P8201QueryLocation:           {consts.T0201QueryLocation, consts.T0001GeneralRespond},
+	consts.P8302QuestionDistribution:    {consts.T0302QuestionAnswer, consts.T0001GeneralRespond},
+	consts.P8608QueryAreaOrRouteData:    {consts.T0608QueryRegionRespond, consts.T0001GeneralRespond},
+	// 这些指令先回复0x0001 等待后续的应答 如 8801 -> 0805
+	consts.P8801CameraShootImmediateCommand:       {consts.T0805CameraShootImmediately},
+	consts.P9003QueryTerminalAudioVideoProperties: {consts.T1003UploadAudioVideoAttr},
+	consts.P9205QueryResourceList:                 {consts.T1205UploadAudioVideoResourceList},
+	consts.P9206FileUploadInstructions:            {consts.T1206FileUploadCompleteNotice},
+}
+
+func newReplyRule(command consts.JT808CommandType) replyRule {
+	if v, ok := _defaultReplyRules[command]; ok {
+		return v
+	}
+	return replyRule{consts.T0001GeneralRespond}
+}
+
+func (r replyRule) ReplyCommands() []consts.JT808CommandType {
+	return r
+}
+
+func (r replyRule) ReplySerialNumber(msg *Message) (uint16, bool, error) {
+	return DefaultReplySerialNumber(msg)
+}
+
+// DefaultReplySerialNumber 默认支持的终端应答中获取平台下发的流水号 自定义的ReplyMatcher可以使用.
+func DefaultReplySerialNumber(msg *Message) (uint16, bool, error) {
+	switch msg.Command {
+	case consts.T0001GeneralRespond:
+		t0x0001 := &model.T0x0001{}
+		if err := t0x0001.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x0001.SerialNumber, true, nil
+	case consts.T0104QueryParameter:
+		t0x0104 := &model.T0x0104{}
+		if err := t0x0104.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x0104.RespondSerialNumber, true, nil
+	case consts.T0201QueryLocation:
+		t0x0201 := &model.T0x0201{}
+		if err := t0x0201.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x0201.RespondSerialNumber, true, nil
+	case consts.T0302QuestionAnswer:
+		t0x0302 := &model.T0x0302{}
+		if err := t0x0302.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x0302.SerialNumber, true, nil
+	case consts.T0805CameraShootImmediately:
+		t0x0805 := &model.T0x0805{}
+		if err := t0x0805.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x0805.RespondSerialNumber, true, nil
+	case consts.T1205UploadAudioVideoResourceList:
+		t0x1205 := &model.T0x1205{}
+		if err := t0x1205.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x1205.SerialNumber, true, nil
+	case consts.T1206FileUploadCompleteNotice:
+		t0x1206 := &model.T0x1206{}
+		if err := t0x1206.Parse(msg.JTMessage); err != nil {
+			return 0, false, err
+		}
+		return t0x1206.RespondSerialNumber, true, nil
+	default:
+	}
+	// 如0x0107 0x0608 0x1003 没有应答流水号
+	return 0, false, nil
+}
+
+// Correlate 按照下发的顺序匹配 应答中没有流水号的 多个等待中的指令都匹配时是最早下发的.
+func (d defaultCorrelator) Correlate(msg *Message, pending map[uint16]ReplyMatcher) (uint16, bool, error) {
+	for _, seq := range sendOrder(pending) {
+		matcher := pending[seq]
+		if !slices.Contains(matcher.ReplyCommands(), msg.Command) {
+			continue
+		}
+		replySeq, ok, err := matcher.ReplySerialNumber(msg)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok || replySeq == seq {
+			return seq, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// sendOrder 流水号按照下发的顺序 流水号是循环使用的 从最大的间隔之后开始算最早的.
+func sendOrder(pending map[uint16]ReplyMatcher) []uint16 {
+	seqs := slices.Sorted(maps.Keys(pending))
+	if len(seqs) < 2 {
+		return seqs
+	}
+	start := 0
+	maxGap := int(seqs[0]) + 1<<16 - int(seqs[len(seqs)-1])
+	for i := 1; i < len(seqs); i++ {
+		if gap := int(seqs[i]) - int(seqs[i-1]); gap > maxGap {
+			maxGap = gap
+			start = i
+		}
+	}
+	return append(seqs[start:], seqs[:start]...)
+}
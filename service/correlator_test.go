@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"testing"
+	"time"
+)
+
+const (
+	_vendorCommand consts.JT808CommandType = 0x8F01
+	_vendorRespond consts.JT808CommandType = 0x0F01
+)
+
+// vendorHandle 自定义的厂商指令 终端应答body前2个字节是平台流水号.
+type vendorHandle struct {
+	model.BaseHandle
+	command consts.JT808CommandType
+}
+
+func (v *vendorHandle) Protocol() consts.JT808CommandType {
+	return v.command
+}
+
+func (v *vendorHandle) OnReadExecutionEvent(_ *Message) {}
+
+func (v *vendorHandle) OnWriteExecutionEvent(_ Message) {}
+
+func (v *vendorHandle) ReplyCommands() []consts.JT808CommandType {
+	return []consts.JT808CommandType{_vendorRespond}
+}
+
+func (v *vendorHandle) ReplySerialNumber(msg *Message) (uint16, bool, error) {
+	if len(msg.JTMessage.Body) < 2 {
+		return 0, false, errors.New("body too short")
+	}
+	return binary.BigEndian.Uint16(msg.JTMessage.Body), true, nil
+}
+
+func TestGoJT808ReplyMatcher(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+		WithCustomHandleFunc(func() map[consts.JT808CommandType]Handler {
+			return map[consts.JT808CommandType]Handler{
+				_vendorCommand: &vendorHandle{command: _vendorCommand},
+				_vendorRespond: &vendorHandle{command: _vendorRespond},
+			}
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	replyChan := goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage(key, _vendorCommand, []byte{0x01}, time.Second))
+	jtMsg := readJTMessage(t, conn)
+	header := jtMsg.Header
+	// 先通用应答 再厂商自定义的应答
+	header.ReplyID = uint16(consts.T0001GeneralRespond)
+	t0x0001 := &model.P0x8001{RespondSerialNumber: jtMsg.Header.SerialNumber, RespondID: jtMsg.Header.ID}
+	if _, err := conn.Write(header.Encode(t0x0001.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	header.ReplyID = uint16(_vendorRespond)
+	if _, err := conn.Write(header.Encode(binary.BigEndian.AppendUint16(nil, jtMsg.Header.SerialNumber))); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-replyChan
+	if msg.ExtensionFields.Err != nil || msg.Command != _vendorRespond || msg.ExtensionFields.PlatformCommand != _vendorCommand {
+		t.Errorf("reply command=[%x] platform=[%x] err=[%v]",
+			uint16(msg.Command), uint16(msg.ExtensionFields.PlatformCommand), msg.ExtensionFields.Err)
+	}
+}
+
+func TestGoJT808QueryAttribute(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	replyChan := goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage(key, consts.P8107QueryTerminalProperties, nil, time.Second))
+	jtMsg := readJTMessage(t, conn)
+	if jtMsg.Header.ID != uint16(consts.P8107QueryTerminalProperties) {
+		t.Fatalf("command=[%x]", jtMsg.Header.ID)
+	}
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0107QueryAttribute)
+	attr := &model.T0x0107{
+		TerminalType:   0x45,
+		ManufacturerID: "ABCDE",
+		TerminalModel:  "JT808",
+		TerminalID:     "1234567",
+		ICCID:          "89860012345678901234",
+		GNSSProperty:   0x03,
+	}
+	if _, err := conn.Write(header.Encode(attr.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-replyChan
+	if msg.ExtensionFields.Err != nil || msg.Command != consts.T0107QueryAttribute {
+		t.Fatalf("reply command=[%x] err=[%v]", uint16(msg.Command), msg.ExtensionFields.Err)
+	}
+	t0x0107 := &model.T0x0107{}
+	if err := t0x0107.Parse(msg.JTMessage); err != nil {
+		t.Fatal(err)
+	}
+	if t0x0107.ICCID != attr.ICCID || t0x0107.TerminalID != attr.TerminalID || !t0x0107.BeiDou {
+		t.Errorf("T0x0107 = %s", t0x0107)
+	}
+}
+
+func TestGoJT808QuerySpecifyParam(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	p8106 := &model.P0x8106{ParamIDList: []uint32{0x001, 0x013}}
+	replyChan := goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage(key, consts.P8106QuerySpecifyParam, p8106.Encode(), time.Second))
+	jtMsg := readJTMessage(t, conn)
+	if jtMsg.Header.ID != uint16(consts.P8106QuerySpecifyParam) || !bytes.Equal(jtMsg.Body, p8106.Encode()) {
+		t.Fatalf("command=[%x] body=[%x]", jtMsg.Header.ID, jtMsg.Body)
+	}
+	// 只应答查询的参数 心跳间隔和主服务器地址
+	body := binary.BigEndian.AppendUint16(nil, jtMsg.Header.SerialNumber)
+	body = append(body, 2)
+	body = append(body, 0, 0, 0, 0x01, 4, 0, 0, 0, 0x3c)
+	body = append(body, 0, 0, 0, 0x13, 14)
+	body = append(body, "127.0.0.1:8080"...)
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0104QueryParameter)
+	if _, err := conn.Write(header.Encode(body)); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-replyChan
+	if msg.ExtensionFields.Err != nil || msg.Command != consts.T0104QueryParameter {
+		t.Fatalf("reply command=[%x] err=[%v]", uint16(msg.Command), msg.ExtensionFields.Err)
+	}
+	t0x0104 := &model.T0x0104{}
+	if err := t0x0104.Parse(msg.JTMessage); err != nil {
+		t.Fatal(err)
+	}
+	if t0x0104.T0x001HeartbeatInterval.Value != 60 || t0x0104.T0x013Address.Value != "127.0.0.1:8080" {
+		t.Errorf("T0x0104 = %s", t0x0104)
+	}
+}
+
+func TestDefaultCorrelator(t *testing.T) {
+	heartbeat := newTestMessage(t, _heartBeatMsg)
+	t0x0001 := &model.P0x8001{RespondSerialNumber: 7, RespondID: uint16(consts.P8801CameraShootImmediateCommand)}
+	header := heartbeat.JTMessage.Header
+	header.ReplyID = uint16(consts.T0001GeneralRespond)
+	msg := newTestMessage(t, fmt.Sprintf("%x", header.Encode(t0x0001.Encode())))
+
+	tests := []struct {
+		name    string
+		pending map[uint16]ReplyMatcher
+		wantSeq uint16
+		wantOk  bool
+	}{
+		{
+			name: "通用应答",
+			pending: map[uint16]ReplyMatcher{
+				6: newReplyRule(consts.P8300TextInfoDistribution),
+				7: newReplyRule(consts.P8300TextInfoDistribution),
+			},
+			wantSeq: 7,
+			wantOk:  true,
+		},
+		{
+			name:    "终端控制",
+			pending: map[uint16]ReplyMatcher{7: newReplyRule(consts.P8105TerminalControl)},
+			wantSeq: 7,
+			wantOk:  true,
+		},
+		{
+			name:    "等待后续的应答",
+			pending: map[uint16]ReplyMatcher{7: newReplyRule(consts.P8801CameraShootImmediateCommand)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, ok, err := defaultCorrelator{}.Correlate(msg, tt.pending)
+			if err != nil || seq != tt.wantSeq || ok != tt.wantOk {
+				t.Errorf("Correlate() = %d %v %v, want %d %v", seq, ok, err, tt.wantSeq, tt.wantOk)
+			}
+		})
+	}
+
+	// 0x0608没有应答流水号 收到就是0x8608的应答
+	header.ReplyID = uint16(consts.T0608QueryRegionRespond)
+	t0x0608 := &model.T0x0608{QueryType: 1}
+	msg = newTestMessage(t, fmt.Sprintf("%x", header.Encode(t0x0608.Encode())))
+	pending := map[uint16]ReplyMatcher{9: newReplyRule(consts.P8608QueryAreaOrRouteData)}
+	if seq, ok, err := (defaultCorrelator{}).Correlate(msg, pending); err != nil || !ok || seq != 9 {
+		t.Errorf("Correlate() = %d %v %v, want 9 true", seq, ok, err)
+	}
+	// 多个等待中的都匹配 是最早下发的 流水号循环使用的情况65535在0之前
+	for _, want := range []struct {
+		seqs []uint16
+		seq  uint16
+	}{
+		{seqs: []uint16{9, 3, 20}, seq: 3},
+		{seqs: []uint16{9, 65535, 2}, seq: 65535},
+	} {
+		pending = make(map[uint16]ReplyMatcher)
+		for _, seq := range want.seqs {
+			pending[seq] = newReplyRule(consts.P8608QueryAreaOrRouteData)
+		}
+		for range 10 {
+			if seq, ok, err := (defaultCorrelator{}).Correlate(msg, pending); err != nil || !ok || seq != want.seq {
+				t.Fatalf("Correlate() %v = %d %v %v, want %d true", want.seqs, seq, ok, err, want.seq)
+			}
+		}
+	}
+}
+
+func newTestMessage(t *testing.T, data string) *Message {
+	t.Helper()
+	raw, _ := hex.DecodeString(data)
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(raw); err != nil {
+		t.Fatal(err)
+	}
+	return newTerminalMessage(jtMsg, raw)
+}
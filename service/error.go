@@ -6,9 +6,16 @@ var (
 	ErrWriteDataFail     = errors.New("write data fail")
 	ErrWriteDataOverTime = errors.New("write data is overtime")
 	ErrNotExistKey       = errors.New("key not exist")
+	ErrServerClosed      = errors.New("server closed")
+	ErrConnectionClosed  = errors.New("connection closed")
+	ErrActiveQueued      = errors.New("active message queued")
+	ErrOfflineExpired    = errors.New("offline command expired")
+	ErrUpgradeRunning    = errors.New("upgrade job running")
+	ErrUpgradeFail       = errors.New("upgrade fail")
 )
 
 var (
 	_errKeyInvalid = errors.New("key invalid")
 	_errKeyExist   = errors.New("key exist")
+	_errKeyBanned  = errors.New("key banned")
 )
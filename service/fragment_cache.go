@@ -0,0 +1,74 @@
+package service
+
+import (
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+)
+
+// defaultFragmentCacheSize 默认缓存最近下发的分包消息数量.
+const defaultFragmentCacheSize = 16
+
+type (
+	// fragmentCache 平台最近下发的分包 终端0x0005请求补传的时候重新发送 只在writer中使用.
+	fragmentCache struct {
+		size int
+		// order 按照下发的顺序 超过size的删除最早的
+		order  []uint16
+		record map[uint16]*fragments
+	}
+
+	fragments struct {
+		command consts.JT808CommandType
+		// frames 每个分包完整的报文 下标是包序号-1
+		frames [][]byte
+	}
+)
+
+func newFragmentCache(size int) *fragmentCache {
+	return &fragmentCache{
+		size:   size,
+		order:  make([]uint16, 0, max(size, 0)),
+		record: make(map[uint16]*fragments),
+	}
+}
+
+// add seq是第一个分包的流水号.
+func (f *fragmentCache) add(seq uint16, command consts.JT808CommandType, frames [][]byte) {
+	if f.size <= 0 {
+		return
+	}
+	if _, ok := f.record[seq]; !ok {
+		if len(f.order) >= f.size {
+			delete(f.record, f.order[0])
+			f.order = f.order[1:]
+		}
+		f.order = append(f.order, seq)
+	}
+	f.record[seq] = &fragments{
+		command: command,
+		frames:  frames,
+	}
+}
+
+func (f *fragmentCache) load(seq uint16) (*fragments, bool) {
+	v, ok := f.record[seq]
+	return v, ok
+}
+
+// splitFrames 把多个报文拆分成单个的 每个报文都是7e开头7e结尾.
+func splitFrames(data []byte) [][]byte {
+	const sign = 0x7e
+	frames := make([][]byte, 0)
+	start := -1
+	for i, v := range data {
+		if v != sign {
+			continue
+		}
+		if start == -1 {
+			start = i
+			continue
+		}
+		frames = append(frames, data[start:i+1])
+		start = -1
+	}
+	return frames
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFragmentCache(t *testing.T) {
+	cache := newFragmentCache(2)
+	cache.add(1, consts.P8103SetTerminalParams, [][]byte{{1}})
+	cache.add(2, consts.P8103SetTerminalParams, [][]byte{{2}})
+	cache.add(3, consts.P8103SetTerminalParams, [][]byte{{3}})
+	if _, ok := cache.load(1); ok {
+		t.Error("load(1) want evicted")
+	}
+	if v, ok := cache.load(3); !ok || !bytes.Equal(v.frames[0], []byte{3}) {
+		t.Errorf("load(3) = %v %t", v, ok)
+	}
+
+	frames := splitFrames([]byte{0x7e, 1, 0x7e, 0x7e, 2, 3, 0x7e})
+	if len(frames) != 2 || !bytes.Equal(frames[1], []byte{0x7e, 2, 3, 0x7e}) {
+		t.Errorf("splitFrames() = %x", frames)
+	}
+}
+
+// readFrames 读取n个完整的报文.
+func readFrames(t *testing.T, conn net.Conn, n int) [][]byte {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	data := make([]byte, 0, 4096)
+	buf := make([]byte, 4096)
+	for {
+		if frames := splitFrames(data); len(frames) >= n {
+			return frames
+		}
+		size, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, buf[:size]...)
+	}
+}
+
+func TestGoJT808ReissueRequest(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	body := bytes.Repeat([]byte{0x01}, 2500)
+	_ = goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage(key, consts.P8300TextInfoDistribution, body, time.Second))
+	frames := readFrames(t, conn, 3)
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+	seq := jtMsg.Header.SerialNumber
+
+	// 终端请求补传第2个包
+	t0x0005 := &model.T0x0005{
+		OriginalSerialNumber: seq,
+		AgainPackageCount:    1,
+		AgainPackageList:     []uint16{2},
+	}
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0005ReissueSubcontractingRequest)
+	header.PlatformSerialNumber = 1
+	if _, err := conn.Write(header.Encode(t0x0005.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFrames(t, conn, 1); !bytes.Equal(got[0], frames[1]) {
+		t.Errorf("reissue = %x\nwant %x", got[0], frames[1])
+	}
+
+	// 每个分包占用一个流水号
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if p8001 := readJTMessage(t, conn); p8001.Header.SerialNumber != seq+3 {
+		t.Errorf("serial number = %d, want %d", p8001.Header.SerialNumber, seq+3)
+	}
+}
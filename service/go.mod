@@ -4,7 +4,7 @@ go 1.23.2
 
 require (
 	github.com/cuteLittleDevil/go-jt808/protocol v1.14.0
-	github.com/cuteLittleDevil/go-jt808/shared v1.6.0
+	github.com/cuteLittleDevil/go-jt808/shared v1.7.0
 )
 
 require golang.org/x/text v0.21.0 // indirect
@@ -29,6 +29,21 @@ type (
 		Eventer
 	}
 
+	// LeaveReasonEventer 终端离开的原因 TerminalEventer同时实现的话 在OnLeaveEvent之后触发.
+	LeaveReasonEventer interface {
+		OnLeaveReasonEvent(key string, reason LeaveReason)
+	}
+
+	// SessionConflictEventer 终端的key已经在线时 按照SessionPolicy处理后触发 在OnJoinEvent之前触发.
+	SessionConflictEventer interface {
+		OnSessionConflictEvent(key string, policy SessionPolicy)
+	}
+
+	// RateLimitEventer 终端超过限制的处理 超过最大连接数的情况key为空.
+	RateLimitEventer interface {
+		OnRateLimitEvent(key string, action RateLimitAction)
+	}
+
 	Eventer interface {
 		OnReadExecutionEvent(msg *Message) // 读到jt808数据时
 		OnWriteExecutionEvent(msg Message) // 写入数据给终端后
@@ -51,12 +66,13 @@ func (d *defaultHandle) OnWriteExecutionEvent(_ Message) {
 
 type defaultTerminalEvent struct {
 	createTime time.Time
+	logger     *slog.Logger
 }
 
 func (d *defaultTerminalEvent) OnJoinEvent(msg *Message, key string, err error) {
 	if err != nil {
 		d.createTime = time.Now()
-		slog.Debug("join",
+		d.logger.Debug("join",
 			slog.String("key", key),
 			slog.String("create time", d.createTime.Format(time.DateTime)),
 			slog.String("data", fmt.Sprintf("%x", msg.ExtensionFields.TerminalData)))
@@ -64,17 +80,19 @@ func (d *defaultTerminalEvent) OnJoinEvent(msg *Message, key string, err error)
 }
 
 func (d *defaultTerminalEvent) OnLeaveEvent(key string) {
-	slog.Debug("leave",
+	d.logger.Debug("leave",
 		slog.String("key", key),
 		slog.String("create time", d.createTime.Format(time.DateTime)),
 		slog.Float64("online time second", time.Since(d.createTime).Seconds()))
 }
 
 func (d *defaultTerminalEvent) OnNotSupportedEvent(msg *Message) {
-	slog.Warn("key not found",
+	d.logger.Warn("command not supported",
+		slog.String("key", msg.Key),
+		slog.String("phone", msg.JTMessage.Header.TerminalPhoneNo),
+		slog.String("command", msg.Command.String()),
 		slog.Any("seq", msg.ExtensionFields.TerminalSeq),
-		slog.Any("id", msg.JTMessage.Header.ID),
-		slog.String("remark", msg.Command.String()))
+		slog.Any("id", msg.JTMessage.Header.ID))
 }
 
 func (d *defaultTerminalEvent) OnReadExecutionEvent(_ *Message) {}
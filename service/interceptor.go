@@ -0,0 +1,61 @@
+package service
+
+import "github.com/cuteLittleDevil/go-jt808/shared/consts"
+
+type (
+	// InboundHandler 处理终端上传的报文.
+	InboundHandler func(msg *Message) error
+
+	// InboundInterceptor 终端上传的报文分发给Handler之前执行 调用next继续分发.
+	// 不调用next的报文不分发 可以用msg.SetReply回复终端
+	// 返回错误的说明拒绝 回复(设置了的话)后断开连接.
+	InboundInterceptor func(msg *Message, next InboundHandler) error
+
+	// OutboundHandler 写入数据给终端.
+	OutboundHandler func(frame *OutboundFrame) error
+
+	// OutboundInterceptor 写入数据给终端之前执行 可以修改frame.Data 返回错误的不写入.
+	OutboundInterceptor func(frame *OutboundFrame, next OutboundHandler) error
+
+	// OutboundFrame 平台写入终端的数据.
+	OutboundFrame struct {
+		// Key 唯一标识符 默认手机号
+		Key string `json:"key"`
+		// Command 平台的指令
+		Command consts.JT808CommandType `json:"command"`
+		// Seq 平台的流水号
+		Seq uint16 `json:"seq"`
+		// Data 写入的完整报文
+		Data []byte `json:"data"`
+		// ActiveSend 是否是平台主动下发的
+		ActiveSend bool `json:"activeSend"`
+	}
+
+	// interceptReply 拦截器设置的回复.
+	interceptReply struct {
+		command consts.JT808CommandType
+		body    []byte
+	}
+)
+
+// chainInbound 按照注册的顺序执行 第一个在最外层.
+func chainInbound(interceptors []InboundInterceptor, handler InboundHandler) InboundHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(msg *Message) error {
+			return interceptor(msg, next)
+		}
+	}
+	return handler
+}
+
+// chainOutbound 按照注册的顺序执行 第一个在最外层.
+func chainOutbound(interceptors []OutboundInterceptor, handler OutboundHandler) OutboundHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(frame *OutboundFrame) error {
+			return interceptor(frame, next)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGoJT808Interceptor(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		tags   []any
+		frames []OutboundFrame
+	)
+	tests := []struct {
+		name        string
+		interceptor InboundInterceptor
+		wantResult  byte
+		wantReason  LeaveReason
+	}{
+		{
+			name: "修改回复",
+			interceptor: func(msg *Message, next InboundHandler) error {
+				msg.ExtensionFields.CustomData = "tenant"
+				p8001 := &model.P0x8001{
+					RespondSerialNumber: msg.JTMessage.Header.SerialNumber,
+					RespondID:           msg.JTMessage.Header.ID,
+					Result:              3,
+				}
+				msg.SetReply(consts.P8001GeneralRespond, p8001.Encode())
+				return next(msg)
+			},
+			wantResult: 3,
+		},
+		{
+			name: "拒绝",
+			interceptor: func(msg *Message, _ InboundHandler) error {
+				p8001 := &model.P0x8001{
+					RespondSerialNumber: msg.JTMessage.Header.SerialNumber,
+					RespondID:           msg.JTMessage.Header.ID,
+					Result:              1,
+				}
+				msg.SetReply(consts.P8001GeneralRespond, p8001.Encode())
+				return errors.New("tenant not allowed")
+			},
+			wantResult: 1,
+			wantReason: LeaveReasonRejected,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			tags, frames = nil, nil
+			mu.Unlock()
+			addr := freeAddr(t)
+			event := &lifecycleTerminal{join: make(chan string, 1)}
+			goJt808 := New(
+				WithHostPorts(addr),
+				WithCustomTerminalEventer(func() TerminalEventer {
+					return event
+				}),
+				WithInboundInterceptor(tt.interceptor, func(msg *Message, next InboundHandler) error {
+					mu.Lock()
+					tags = append(tags, msg.ExtensionFields.CustomData)
+					mu.Unlock()
+					return next(msg)
+				}),
+				WithOutboundInterceptor(func(frame *OutboundFrame, next OutboundHandler) error {
+					mu.Lock()
+					frames = append(frames, *frame)
+					mu.Unlock()
+					return next(frame)
+				}),
+			)
+			go func() {
+				_ = goJt808.Start(context.Background())
+			}()
+			defer func() {
+				_ = goJt808.Shutdown(context.Background())
+			}()
+			conn := dialAndSend(t, addr, _heartBeatMsg)
+			defer func() {
+				_ = conn.Close()
+			}()
+			if p8001 := readP8001(t, conn); p8001.Result != tt.wantResult {
+				t.Errorf("result = %d, want %d", p8001.Result, tt.wantResult)
+			}
+			if tt.wantReason != 0 {
+				if reason := event.waitLeaveReason(t, 3*time.Second); reason != tt.wantReason {
+					t.Errorf("leave reason = %s", reason)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantReason == 0 && (len(tags) != 1 || tags[0] != "tenant") {
+				t.Errorf("tags = %v", tags)
+			}
+			if len(frames) != 1 || frames[0].Command != consts.P8001GeneralRespond || frames[0].Key != "14419999999" {
+				t.Errorf("frames = %v", frames)
+			}
+		})
+	}
+}
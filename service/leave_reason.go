@@ -0,0 +1,58 @@
+package service
+
+// LeaveReason 终端离开的原因.
+type LeaveReason uint8
+
+const (
+	// LeaveReasonClosed 终端断开了连接.
+	LeaveReasonClosed LeaveReason = iota + 1
+	// LeaveReasonReadFail 读取数据失败.
+	LeaveReasonReadFail
+	// LeaveReasonParseFail 解析数据失败.
+	LeaveReasonParseFail
+	// LeaveReasonKeyExist 终端的key已经存在.
+	LeaveReasonKeyExist
+	// LeaveReasonIdleTimeout 长时间没有收到终端数据.
+	LeaveReasonIdleTimeout
+	// LeaveReasonAuthFail 注册或者鉴权失败.
+	LeaveReasonAuthFail
+	// LeaveReasonShutdown 服务关闭.
+	LeaveReasonShutdown
+	// LeaveReasonReplaced 同一个key的新连接加入 旧连接被替换.
+	LeaveReasonReplaced
+	// LeaveReasonKicked 平台主动断开.
+	LeaveReasonKicked
+	// LeaveReasonRejected 拦截器拒绝.
+	LeaveReasonRejected
+	// LeaveReasonRateLimit 超过了限流 断开连接或者禁止接入.
+	LeaveReasonRateLimit
+)
+
+func (l LeaveReason) String() string {
+	switch l {
+	case LeaveReasonClosed:
+		return "终端断开连接"
+	case LeaveReasonReadFail:
+		return "读取数据失败"
+	case LeaveReasonParseFail:
+		return "解析数据失败"
+	case LeaveReasonKeyExist:
+		return "终端已存在"
+	case LeaveReasonIdleTimeout:
+		return "终端空闲超时"
+	case LeaveReasonAuthFail:
+		return "注册鉴权失败"
+	case LeaveReasonShutdown:
+		return "服务关闭"
+	case LeaveReasonReplaced:
+		return "被新连接替换"
+	case LeaveReasonKicked:
+		return "平台主动断开"
+	case LeaveReasonRejected:
+		return "拦截器拒绝"
+	case LeaveReasonRateLimit:
+		return "超过限流"
+	default:
+	}
+	return "未知的离开原因"
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"time"
 )
 
 type Message struct {
@@ -11,7 +12,9 @@ type Message struct {
 	// Key 唯一标识符 默认手机号 终端未加入的时候为空
 	Key string `json:"key"`
 	// Command 当前的指令类型
-	Command         consts.JT808CommandType `json:"command"`
+	Command consts.JT808CommandType `json:"command"`
+	// ProtocolVersion 协议版本 2011版本是根据终端注册消息(0x0100)判断的 平台下发的为空
+	ProtocolVersion consts.ProtocolVersionType `json:"protocolVersion,omitempty"`
 	ExtensionFields struct {
 		// TerminalSeq 终端流水号
 		TerminalSeq uint16 `json:"terminalSeq,omitempty"`
@@ -34,6 +37,12 @@ type Message struct {
 		// Err 异常情况
 		Err error `json:"err,omitempty"`
 	}
+	// reply 拦截器设置的回复 为空的使用Handler的
+	reply *interceptReply
+	// dispatched 是否已经分发给Handler了
+	dispatched bool
+	// readTime 读到报文的时间 统计回复耗时使用
+	readTime time.Time
 }
 
 func newTerminalMessage(jtMsg *jt808.JTMessage, terminalData []byte) *Message {
@@ -109,3 +118,8 @@ func (msg *Message) hasComplete() bool {
 	}
 	return msg.ExtensionFields.SubcontractComplete
 }
+
+// SetReply 替换平台回复终端的指令和body 拦截器中使用.
+func (msg *Message) SetReply(command consts.JT808CommandType, body []byte) {
+	msg.reply = &interceptReply{command: command, body: body}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"time"
+)
+
+// serviceMetrics 服务的指标 没有设置Registry的时候为空 方法都可以在为空的时候调用.
+// 每个服务创建一次 连接共用服务的.
+type serviceMetrics struct {
+	// messages 收发的报文数量 分包的每个包单独统计
+	messages *metrics.CounterVec
+	// parseErrors 解析失败的数量
+	parseErrors *metrics.CounterVec
+	// replyDuration 收到终端报文到平台回复的耗时
+	replyDuration *metrics.HistogramVec
+	// subcontracts 分包的完成 超时 丢弃 请求补传的数量
+	subcontracts *metrics.CounterVec
+	// activeMessages 平台下发指令的结果
+	activeMessages *metrics.CounterVec
+	// activeDuration 平台下发指令到收到终端应答的耗时
+	activeDuration *metrics.HistogramVec
+	// leaves 终端离开的原因
+	leaves *metrics.CounterVec
+}
+
+func newServiceMetrics(registry *metrics.Registry) *serviceMetrics {
+	if registry == nil {
+		return nil
+	}
+	return &serviceMetrics{
+		messages: registry.NewCounter("jt808_messages_total",
+			"jt808 messages by direction(in/out) and command", "direction", "command"),
+		parseErrors: registry.NewCounter("jt808_parse_errors_total",
+			"jt808 parse errors by kind", "kind"),
+		replyDuration: registry.NewHistogram("jt808_reply_duration_seconds",
+			"time from terminal message read to platform reply written", nil, "command"),
+		subcontracts: registry.NewCounter("jt808_subcontracts_total",
+			"jt808 sub-packages by outcome(complete/timeout/discard/reissue)", "outcome"),
+		activeMessages: registry.NewCounter("jt808_active_messages_total",
+			"platform active messages by command and outcome", "command", "outcome"),
+		activeDuration: registry.NewHistogram("jt808_active_message_duration_seconds",
+			"platform active message round trip by command", nil, "command"),
+		leaves: registry.NewCounter("jt808_leaves_total",
+			"terminal leaves by reason", "reason"),
+	}
+}
+
+func (s *serviceMetrics) onMessage(direction string, command consts.JT808CommandType) {
+	if s == nil {
+		return
+	}
+	s.messages.Inc(direction, commandLabel(command))
+}
+
+func (s *serviceMetrics) onParseError(err error) {
+	if s == nil {
+		return
+	}
+	s.parseErrors.Inc(parseErrorKind(err))
+}
+
+func (s *serviceMetrics) onReply(command consts.JT808CommandType, readTime time.Time) {
+	if s == nil || readTime.IsZero() {
+		return
+	}
+	s.replyDuration.Observe(time.Since(readTime).Seconds(), commandLabel(command))
+}
+
+func (s *serviceMetrics) onSubcontract(outcome string) {
+	if s == nil {
+		return
+	}
+	s.subcontracts.Inc(outcome)
+}
+
+func (s *serviceMetrics) onActiveMessage(command consts.JT808CommandType, err error, duration time.Duration) {
+	if s == nil {
+		return
+	}
+	outcome := activeOutcome(err)
+	s.activeMessages.Inc(commandLabel(command), outcome)
+	if outcome == "success" {
+		s.activeDuration.Observe(duration.Seconds(), commandLabel(command))
+	}
+}
+
+func (s *serviceMetrics) onLeave(reason LeaveReason) {
+	if s == nil {
+		return
+	}
+	s.leaves.Inc(leaveReasonLabel(reason))
+}
+
+func commandLabel(command consts.JT808CommandType) string {
+	return fmt.Sprintf("0x%04x", uint16(command))
+}
+
+// leaveReasonLabel 标签值使用固定的英文 String()的中文只用于日志.
+func leaveReasonLabel(reason LeaveReason) string {
+	switch reason {
+	case LeaveReasonClosed:
+		return "closed"
+	case LeaveReasonReadFail:
+		return "read_fail"
+	case LeaveReasonParseFail:
+		return "parse_fail"
+	case LeaveReasonKeyExist:
+		return "key_exist"
+	case LeaveReasonIdleTimeout:
+		return "timeout"
+	case LeaveReasonAuthFail:
+		return "auth_fail"
+	case LeaveReasonShutdown:
+		return "shutdown"
+	case LeaveReasonReplaced:
+		return "replaced"
+	case LeaveReasonKicked:
+		return "kicked"
+	case LeaveReasonRejected:
+		return "rejected"
+	case LeaveReasonRateLimit:
+		return "rate_limit"
+	default:
+		return "unknown"
+	}
+}
+
+func parseErrorKind(err error) string {
+	switch {
+	case errors.Is(err, protocol.ErrCheckCode):
+		return "check_code"
+	case errors.Is(err, protocol.ErrHeaderLength2Short):
+		return "header_length"
+	case errors.Is(err, protocol.ErrBodyLengthInconsistency):
+		return "body_length"
+	case errors.Is(err, protocol.ErrDecrypt):
+		return "decrypt"
+	case errors.Is(err, protocol.ErrUnqualifiedData):
+		return "unqualified_data"
+	default:
+		return "other"
+	}
+}
+
+// activeOutcome 和BroadcastStatus的分类一致.
+func activeOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrActiveQueued):
+		return "queued"
+	case errors.Is(err, ErrWriteDataOverTime):
+		return "timeout"
+	case errors.Is(err, ErrNotExistKey), errors.Is(err, ErrConnectionClosed):
+		return "offline"
+	case errors.Is(err, ErrWriteDataFail):
+		return "write_fail"
+	default:
+		return "fail"
+	}
+}
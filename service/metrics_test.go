@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoJT808Metrics(t *testing.T) {
+	addr := freeAddr(t)
+	registry := metrics.NewRegistry()
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithMetrics(registry),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	// 多个服务共用一个Registry 在线数量求和
+	other := New(WithMetrics(registry))
+	defer func() {
+		_ = other.Shutdown(context.Background())
+	}()
+
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = readFrames(t, conn, 1)
+	msg := goJt808.SendActiveMessage(NewActiveMessage("1001", consts.P8104QueryTerminalParams, nil, time.Second))
+	if msg.ExtensionFields.Err == nil {
+		t.Fatal("SendActiveMessage() offline key want err")
+	}
+
+	wants := []string{
+		`jt808_messages_total{direction="in",command="0x0002"} 1`,
+		`jt808_messages_total{direction="out",command="0x8001"} 1`,
+		`jt808_reply_duration_seconds_count{command="0x0002"} 1`,
+		`jt808_active_messages_total{command="0x8104",outcome="offline"} 1`,
+		"jt808_sessions 1",
+	}
+	// 回复写入后才统计 终端读到的时候可能还没有统计
+	waitMetrics(t, registry, wants)
+
+	_ = conn.Close()
+	waitMetrics(t, registry, []string{
+		`jt808_leaves_total{reason="closed"} 1`,
+		"jt808_sessions 0",
+	})
+}
+
+func waitMetrics(t *testing.T, registry *metrics.Registry, wants []string) {
+	t.Helper()
+	var data string
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); {
+		rec := httptest.NewRecorder()
+		registry.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body, _ := io.ReadAll(rec.Body)
+		if data = string(body); containsAll(data, wants) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("metrics [%s] want %v", data, wants)
+}
+
+func containsAll(s string, subs []string) bool {
+	for _, sub := range subs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOfflineTTL            = 24 * time.Hour // 离线指令默认的有效时间
+	defaultOfflineExpireInterval = time.Minute    // 默认清理过期离线指令的间隔
+)
+
+type (
+	// OfflineCommand 终端离线时缓存的平台下发指令.
+	OfflineCommand struct {
+		// ID 唯一标识
+		ID string `json:"id"`
+		// Key 唯一标识符 默认手机号
+		Key string `json:"key"`
+		// Command 平台下发的指令
+		Command consts.JT808CommandType `json:"command"`
+		// Body 平台下发的数据
+		Body []byte `json:"body"`
+		// OverTimeDuration 下发后等待应答的超时时间
+		OverTimeDuration time.Duration `json:"overTimeDuration"`
+		// CreateTime 缓存的时间
+		CreateTime time.Time `json:"createTime"`
+		// ExpireTime 过期时间 过期后不再下发
+		ExpireTime time.Time `json:"expireTime"`
+	}
+
+	// OfflineStore 离线指令的存储 同一个key的指令按照加入的顺序下发.
+	OfflineStore interface {
+		// Push 增加离线指令
+		Push(cmd OfflineCommand) error
+		// Load 获取key全部的离线指令 按照加入的顺序
+		Load(key string) ([]OfflineCommand, error)
+		// Remove 删除已经下发或者过期的指令
+		Remove(key string, id string) error
+	}
+
+	// OfflineExpirer OfflineStore可选实现 定时删除过期的指令 没有实现的只在终端加入的时候删除.
+	OfflineExpirer interface {
+		// RemoveExpired 删除now之前过期的指令 返回删除的指令
+		RemoveExpired(now time.Time) ([]OfflineCommand, error)
+	}
+
+	// MemoryOfflineStore 内存存储的离线指令 服务重启后丢失.
+	MemoryOfflineStore struct {
+		mu     sync.Mutex
+		record map[string][]OfflineCommand
+		// onChange 离线指令变化后的回调 用于持久化
+		onChange func(record map[string][]OfflineCommand) error
+	}
+)
+
+func NewMemoryOfflineStore() *MemoryOfflineStore {
+	return &MemoryOfflineStore{
+		record: make(map[string][]OfflineCommand),
+	}
+}
+
+func (m *MemoryOfflineStore) Push(cmd OfflineCommand) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record[cmd.Key] = append(m.record[cmd.Key], cmd)
+	if m.onChange != nil {
+		if err := m.onChange(m.record); err != nil {
+			m.record[cmd.Key] = m.record[cmd.Key][:len(m.record[cmd.Key])-1]
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryOfflineStore) Load(key string) ([]OfflineCommand, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.record[key]), nil
+}
+
+func (m *MemoryOfflineStore) Remove(key string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cmds := slices.DeleteFunc(m.record[key], func(cmd OfflineCommand) bool {
+		return cmd.ID == id
+	})
+	if len(cmds) == 0 {
+		delete(m.record, key)
+	} else {
+		m.record[key] = cmds
+	}
+	if m.onChange != nil {
+		return m.onChange(m.record)
+	}
+	return nil
+}
+
+func (m *MemoryOfflineStore) RemoveExpired(now time.Time) ([]OfflineCommand, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired []OfflineCommand
+	for key, cmds := range m.record {
+		cmds = slices.DeleteFunc(cmds, func(cmd OfflineCommand) bool {
+			if now.After(cmd.ExpireTime) {
+				expired = append(expired, cmd)
+				return true
+			}
+			return false
+		})
+		if len(cmds) == 0 {
+			delete(m.record, key)
+		} else {
+			m.record[key] = cmds
+		}
+	}
+	if len(expired) > 0 && m.onChange != nil {
+		return expired, m.onChange(m.record)
+	}
+	return expired, nil
+}
+
+// queueActiveMessage 终端不在线的情况 缓存平台下发的指令.
+func (g *GoJT808) queueActiveMessage(activeMsg *ActiveMessage) error {
+	id, err := newAuthCode()
+	if err != nil {
+		return err
+	}
+	ttl := activeMsg.OfflineTTL
+	if ttl <= 0 {
+		ttl = g.opts.OfflineTTL
+	}
+	now := time.Now()
+	cmd := OfflineCommand{
+		ID:               id,
+		Key:              activeMsg.Key,
+		Command:          activeMsg.Command,
+		Body:             activeMsg.Body,
+		OverTimeDuration: activeMsg.OverTimeDuration,
+		CreateTime:       now,
+		ExpireTime:       now.Add(ttl),
+	}
+	if err := g.opts.OfflineStore.Push(cmd); err != nil {
+		return err
+	}
+	// 缓存的时候终端刚好加入了
+	if _, ok := g.Session(activeMsg.Key); ok {
+		g.onTerminalReady(activeMsg.Key)
+	}
+	return nil
+}
+
+// onTerminalReady 终端加入(设置了鉴权的情况是鉴权成功) 通知等待中的升级任务 按顺序下发缓存的指令.
+func (g *GoJT808) onTerminalReady(key string) {
+	g.upgrades.ready(key)
+	if g.opts.OfflineStore == nil {
+		return
+	}
+	g.mu.Lock()
+	if _, ok := g.offlineDelivering[key]; ok || g.closing {
+		if ok {
+			// 正在下发的已经读取了指令 结束后再检查一次
+			g.offlineDelivering[key] = true
+		}
+		g.mu.Unlock()
+		return
+	}
+	g.offlineDelivering[key] = false
+	g.mu.Unlock()
+	go func() {
+		for {
+			g.deliverOffline(key)
+			g.mu.Lock()
+			if again := g.offlineDelivering[key]; !again || g.closing {
+				delete(g.offlineDelivering, key)
+				g.mu.Unlock()
+				return
+			}
+			g.offlineDelivering[key] = false
+			g.mu.Unlock()
+		}
+	}()
+}
+
+func (g *GoJT808) deliverOffline(key string) {
+	store := g.opts.OfflineStore
+	cmds, err := store.Load(key)
+	if err != nil {
+		g.opts.Logger.Warn("offline load fail",
+			slog.String("key", key),
+			slog.Any("err", err))
+		return
+	}
+	for _, cmd := range cmds {
+		var msg *Message
+		if time.Now().After(cmd.ExpireTime) {
+			msg = newOfflineExpiredMessage(cmd)
+		} else {
+			activeMsg := NewActiveMessage(key, cmd.Command, cmd.Body, cmd.OverTimeDuration)
+			msg = <-g.sendActiveMessage(context.Background(), activeMsg, false)
+			if err := msg.ExtensionFields.Err; errors.Is(err, ErrNotExistKey) ||
+				errors.Is(err, ErrConnectionClosed) || errors.Is(err, ErrServerClosed) {
+				// 终端又离线了 剩下的等下次加入
+				return
+			}
+		}
+		if err := store.Remove(key, cmd.ID); err != nil {
+			g.opts.Logger.Warn("offline remove fail",
+				slog.String("key", key),
+				slog.String("id", cmd.ID),
+				slog.Any("err", err))
+		}
+		g.opts.OfflineResultFunc(cmd, msg)
+	}
+}
+
+// runOfflineExpire 定时删除过期的离线指令 不等终端加入 服务关闭后退出.
+func (g *GoJT808) runOfflineExpire(expirer OfflineExpirer, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultOfflineExpireInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.doneChan:
+			return
+		case now := <-ticker.C:
+			cmds, err := expirer.RemoveExpired(now)
+			if err != nil {
+				g.opts.Logger.Warn("offline remove expired fail",
+					slog.Any("err", err))
+			}
+			for _, cmd := range cmds {
+				g.opts.OfflineResultFunc(cmd, newOfflineExpiredMessage(cmd))
+			}
+		}
+	}
+}
+
+func newOfflineExpiredMessage(cmd OfflineCommand) *Message {
+	return newErrMessage(errors.Join(ErrOfflineExpired,
+		fmt.Errorf("expire time [%s]", cmd.ExpireTime.Format(time.DateTime))))
+}
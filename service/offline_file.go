@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileOfflineStore 文件存储的离线指令 变化后写入json文件 服务重启后继续下发.
+type FileOfflineStore struct {
+	*MemoryOfflineStore
+	path string
+}
+
+// NewFileOfflineStore 读取path的离线指令 文件不存在的情况在第一次缓存的时候创建.
+func NewFileOfflineStore(path string) (*FileOfflineStore, error) {
+	f := &FileOfflineStore{
+		MemoryOfflineStore: NewMemoryOfflineStore(),
+		path:               path,
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read offline file [%s]: %w", path, err)
+	case len(data) > 0:
+		if err := json.Unmarshal(data, &f.MemoryOfflineStore.record); err != nil {
+			return nil, fmt.Errorf("unmarshal offline file [%s]: %w", path, err)
+		}
+	}
+	f.MemoryOfflineStore.onChange = f.save
+	return f, nil
+}
+
+// save 先写临时文件再替换 避免写入一半的情况.
+func (f *FileOfflineStore) save(record map[string][]OfflineCommand) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save offline file [%s]: %w", f.path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save offline file [%s]: %w", f.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save offline file [%s]: %w", f.path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save offline file [%s]: %w", f.path, err)
+	}
+	return nil
+}
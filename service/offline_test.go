@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileOfflineStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offline.json")
+	store, err := NewFileOfflineStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := store.Push(OfflineCommand{ID: id, Key: "1001", Command: consts.P8104QueryTerminalParams}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Remove("1001", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后继续使用
+	store, err = NewFileOfflineStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := store.Load("1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || cmds[0].ID != "1" || cmds[1].ID != "3" {
+		t.Errorf("Load() = %v", cmds)
+	}
+	if cmds, _ := store.Load("1002"); len(cmds) != 0 {
+		t.Errorf("Load() = %v", cmds)
+	}
+}
+
+func TestGoJT808OfflineStore(t *testing.T) {
+	addr := freeAddr(t)
+	var (
+		mu         sync.Mutex
+		results    []error
+		resultChan = make(chan struct{}, 2)
+	)
+	store := NewMemoryOfflineStore()
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithOfflineStore(store, time.Minute),
+		WithOfflineResultFunc(func(_ OfflineCommand, msg *Message) {
+			mu.Lock()
+			results = append(results, msg.ExtensionFields.Err)
+			mu.Unlock()
+			resultChan <- struct{}{}
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	const key = "14419999999"
+	expired := NewActiveMessage(key, consts.P8300TextInfoDistribution, nil, time.Second)
+	expired.OfflineTTL = time.Nanosecond
+	if msg := goJt808.SendActiveMessage(expired); !errors.Is(msg.ExtensionFields.Err, ErrActiveQueued) {
+		t.Fatalf("SendActiveMessage() error = %v", msg.ExtensionFields.Err)
+	}
+	activeMsg := NewActiveMessage(key, consts.P8104QueryTerminalParams, nil, 100*time.Millisecond)
+	if msg := goJt808.SendActiveMessage(activeMsg); !errors.Is(msg.ExtensionFields.Err, ErrActiveQueued) {
+		t.Fatalf("SendActiveMessage() error = %v", msg.ExtensionFields.Err)
+	}
+
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	// 心跳的回复和缓存的指令 可能一起读到
+	var received string
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for !strings.Contains(received, "7e8104") {
+		data := make([]byte, 1024)
+		n, err := conn.Read(data)
+		if err != nil {
+			t.Fatalf("read data [%s] err = %v", received, err)
+		}
+		received += hex.EncodeToString(data[:n])
+	}
+	for range 2 {
+		select {
+		case <-resultChan:
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait offline result timeout")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(results) != 2 || !errors.Is(results[0], ErrOfflineExpired) || !errors.Is(results[1], ErrWriteDataOverTime) {
+		t.Errorf("results = %v", results)
+	}
+	if cmds, _ := store.Load(key); len(cmds) != 0 {
+		t.Errorf("Load() = %v", cmds)
+	}
+}
+
+func TestGoJT808OfflineExpire(t *testing.T) {
+	resultChan := make(chan error, 1)
+	store := NewMemoryOfflineStore()
+	goJt808 := New(
+		WithOfflineStore(store, time.Minute),
+		WithOfflineExpireInterval(10*time.Millisecond),
+		WithOfflineResultFunc(func(_ OfflineCommand, msg *Message) {
+			resultChan <- msg.ExtensionFields.Err
+		}),
+	)
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	activeMsg := NewActiveMessage("1001", consts.P8300TextInfoDistribution, nil, time.Second)
+	activeMsg.OfflineTTL = time.Millisecond
+	if msg := goJt808.SendActiveMessage(activeMsg); !errors.Is(msg.ExtensionFields.Err, ErrActiveQueued) {
+		t.Fatalf("SendActiveMessage() error = %v", msg.ExtensionFields.Err)
+	}
+	// 终端一直不加入 也定时删除
+	select {
+	case err := <-resultChan:
+		if !errors.Is(err, ErrOfflineExpired) {
+			t.Errorf("result err = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait offline expire timeout")
+	}
+	if cmds, _ := store.Load("1001"); len(cmds) != 0 {
+		t.Errorf("Load() = %v", cmds)
+	}
+}
+
+// blockingOfflineStore 第一次Load读取后等待 模拟下发过程中又有新的指令.
+type blockingOfflineStore struct {
+	*MemoryOfflineStore
+	once    sync.Once
+	loaded  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingOfflineStore) Load(key string) ([]OfflineCommand, error) {
+	cmds, err := b.MemoryOfflineStore.Load(key)
+	b.once.Do(func() {
+		close(b.loaded)
+		<-b.release
+	})
+	return cmds, err
+}
+
+func TestGoJT808OfflineDelivering(t *testing.T) {
+	addr := freeAddr(t)
+	resultChan := make(chan consts.JT808CommandType, 2)
+	store := &blockingOfflineStore{
+		MemoryOfflineStore: NewMemoryOfflineStore(),
+		loaded:             make(chan struct{}),
+		release:            make(chan struct{}),
+	}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithOfflineStore(store, time.Minute),
+		WithOfflineResultFunc(func(cmd OfflineCommand, _ *Message) {
+			resultChan <- cmd.Command
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	const key = "14419999999"
+	first := NewActiveMessage(key, consts.P8104QueryTerminalParams, nil, 50*time.Millisecond)
+	if msg := goJt808.SendActiveMessage(first); !errors.Is(msg.ExtensionFields.Err, ErrActiveQueued) {
+		t.Fatalf("SendActiveMessage() error = %v", msg.ExtensionFields.Err)
+	}
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	<-store.loaded
+	// 下发中缓存的指令 本次下发结束后继续下发
+	second := NewActiveMessage(key, consts.P8201QueryLocation, nil, 50*time.Millisecond)
+	if err := goJt808.queueActiveMessage(second); err != nil {
+		t.Fatal(err)
+	}
+	close(store.release)
+	for _, want := range []consts.JT808CommandType{consts.P8104QueryTerminalParams, consts.P8201QueryLocation} {
+		select {
+		case command := <-resultChan:
+			if command != want {
+				t.Errorf("result command = %s, want %s", command, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("wait offline result [%s] timeout", want)
+		}
+	}
+}
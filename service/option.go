@@ -1,7 +1,11 @@
 package service
 
 import (
+	"crypto/rsa"
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"github.com/cuteLittleDevil/go-jt808/shared/metrics"
+	"log/slog"
+	"time"
 )
 
 type Option struct {
@@ -9,16 +13,19 @@ type Option struct {
 }
 
 const (
-	defaultAddr              = "0.0.0.0:808" // 服务默认地址
-	defaultNetwork           = "tcp"         // 服务默认网络协议
-	defaultFilterSubcontract = true          // 是否过滤分包的情况
+	defaultAddr              = "0.0.0.0:808"   // 服务默认地址
+	defaultNetwork           = "tcp"           // 服务默认网络协议
+	defaultFilterSubcontract = true            // 是否过滤分包的情况
+	defaultUDPSessionTimeout = 3 * time.Minute // udp会话默认的过期时间
 )
 
 type Options struct {
 	// Addr 服务地址 默认0.0.0.0:808.
 	Addr string
-	// Network 服务协议 默认tcp.
+	// Network 服务协议 默认tcp 支持tcp udp. udp的会话按照手机号区分 回复发往终端最后的地址.
 	Network string
+	// UDPSessionTimeout udp会话多久没有数据就过期 默认3分钟 小于等于0不过期.
+	UDPSessionTimeout time.Duration
 	// FilterSubcontract 是否过滤分包的情况 默认true.
 	FilterSubcontract bool
 	// KeyFunc 用于获取终端唯一标识 默认手机号.
@@ -27,26 +34,89 @@ type Options struct {
 	CustomTerminalEventerFunc func() TerminalEventer
 	// CustomHandleFunc 自定义消息处理.
 	CustomHandleFunc func() map[consts.JT808CommandType]Handler
+	// Authenticator 终端注册鉴权 默认不限制 鉴权码是手机号.
+	Authenticator Authenticator
+	// IdleTimeout 多久没有收到终端数据就断开连接 默认0不限制.
+	IdleTimeout time.Duration
+	// HeartbeatMultiple 超时时间=终端心跳间隔(参数0x0001)*倍数 默认0不使用.
+	// 心跳间隔从平台下发的0x8103和终端回复的0x0104中获取 获取不到的时候使用IdleTimeout.
+	HeartbeatMultiple int
+	// SessionPolicy 终端的key已经在线时的处理策略 默认拒绝新的连接.
+	SessionPolicy SessionPolicy
+	// OfflineStore 终端离线时缓存平台下发的指令 终端加入(设置了鉴权的是鉴权成功)后按顺序下发 默认不缓存.
+	OfflineStore OfflineStore
+	// OfflineTTL 离线指令默认的有效时间 默认24小时.
+	OfflineTTL time.Duration
+	// OfflineResultFunc 离线指令下发的结果 过期的情况Err是ErrOfflineExpired.
+	OfflineResultFunc func(cmd OfflineCommand, msg *Message)
+	// OfflineExpireInterval OfflineStore实现了OfflineExpirer的情况 定时删除过期指令的间隔 默认1分钟.
+	OfflineExpireInterval time.Duration
+	// Correlator 关联终端应答和平台下发的指令 默认根据平台指令的ReplyMatcher.
+	Correlator Correlator
+	// InboundInterceptors 终端上传的报文分发给Handler之前的拦截器 按照顺序执行.
+	InboundInterceptors []InboundInterceptor
+	// OutboundInterceptors 写入数据给终端之前的拦截器 按照顺序执行.
+	OutboundInterceptors []OutboundInterceptor
+	// RateLimit 每个连接的限流 默认不限制.
+	RateLimit RateLimit
+	// MaxConnections 最大连接数 超过的新连接直接断开 默认0不限制.
+	MaxConnections int
+	// SubcontractTimeout 分包多久没有完成就丢弃 默认60秒.
+	SubcontractTimeout time.Duration
+	// SubcontractReissueInterval 分包多久没有收到新的包就请求补传(0x8003) 默认5秒.
+	SubcontractReissueInterval time.Duration
+	// SubcontractMaxBytes 每个终端未完成的分包最多缓存的字节数 超过的丢弃当前分包消息 默认0不限制.
+	SubcontractMaxBytes int
+	// SubcontractStore 分包的存储 连接断开后终端重连可以继续上传 默认不存储.
+	SubcontractStore SubcontractStore
+	// FragmentCacheSize 每个连接缓存最近下发的分包消息数量 终端请求补传(0x0005)的时候重新发送 默认16 小于等于0不缓存.
+	FragmentCacheSize int
+	// RSAPrivateKey 平台的RSA私钥 设置后解密终端加密的消息体 终端上传公钥(0x0A00)后平台下发的消息体加密 默认不加密.
+	RSAPrivateKey *rsa.PrivateKey
+	// SerialNumberStore 平台流水号的存储 终端重连后继续使用上一次的流水号 默认每次连接从0开始.
+	SerialNumberStore SerialNumberStore
+	// Logger 服务的日志 默认slog.Default().
+	Logger *slog.Logger
+	// Metrics 服务的指标 Registry实现了http.Handler 输出Prometheus文本格式 默认不统计.
+	Metrics *metrics.Registry
+	// Capturer 抓包 记录终端上传和平台下发的原始数据 默认不记录.
+	Capturer Capturer
 }
 
 func newOptions(opts []Option) *Options {
 	options := &Options{
-		Addr:              defaultAddr,
-		Network:           defaultNetwork,
-		FilterSubcontract: defaultFilterSubcontract,
-		KeyFunc: func(message *Message) (string, bool) {
-			return message.JTMessage.Header.TerminalPhoneNo, true
-		},
-		CustomTerminalEventerFunc: func() TerminalEventer {
-			return &defaultTerminalEvent{}
-		},
-		CustomHandleFunc: func() map[consts.JT808CommandType]Handler {
-			return map[consts.JT808CommandType]Handler{}
-		},
+		Addr:                       defaultAddr,
+		Network:                    defaultNetwork,
+		FilterSubcontract:          defaultFilterSubcontract,
+		UDPSessionTimeout:          defaultUDPSessionTimeout,
+		OfflineTTL:                 defaultOfflineTTL,
+		OfflineExpireInterval:      defaultOfflineExpireInterval,
+		Correlator:                 defaultCorrelator{},
+		SubcontractTimeout:         defaultSubcontractTimeout,
+		SubcontractReissueInterval: defaultSubcontractReissueInterval,
+		FragmentCacheSize:          defaultFragmentCacheSize,
+	}
+	options.OfflineResultFunc = func(cmd OfflineCommand, msg *Message) {
+		options.Logger.Debug("offline command",
+			slog.String("key", cmd.Key),
+			slog.String("command", cmd.Command.String()),
+			slog.Any("err", msg.ExtensionFields.Err))
+	}
+	options.KeyFunc = func(message *Message) (string, bool) {
+		return message.JTMessage.Header.TerminalPhoneNo, true
+	}
+	options.CustomTerminalEventerFunc = func() TerminalEventer {
+		return &defaultTerminalEvent{logger: options.Logger}
+	}
+	options.CustomHandleFunc = func() map[consts.JT808CommandType]Handler {
+		return map[consts.JT808CommandType]Handler{}
 	}
 	for _, op := range opts {
 		op.F(options)
 	}
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
 	return options
 }
 
@@ -57,13 +127,20 @@ func WithHostPorts(address string) Option {
 	}}
 }
 
-// WithNetwork 修改启动协议,默认TCP.
+// WithNetwork 修改启动协议,默认TCP,支持tcp udp.
 func WithNetwork(network string) Option {
 	return Option{F: func(o *Options) {
 		o.Network = network
 	}}
 }
 
+// WithUDPSessionTimeout udp会话多久没有数据就过期,默认3分钟.
+func WithUDPSessionTimeout(timeout time.Duration) Option {
+	return Option{F: func(o *Options) {
+		o.UDPSessionTimeout = timeout
+	}}
+}
+
 // WithHasSubcontract 是否过滤分包的报文,默认过滤.
 func WithHasSubcontract(filter bool) Option {
 	return Option{F: func(o *Options) {
@@ -91,3 +168,161 @@ func WithCustomTerminalEventer(customTerminalEventerFunc func() TerminalEventer)
 		o.CustomTerminalEventerFunc = customTerminalEventerFunc
 	}}
 }
+
+// WithAuthenticator 自定义终端注册鉴权,默认不限制,鉴权码是手机号.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return Option{F: func(o *Options) {
+		o.Authenticator = authenticator
+	}}
+}
+
+// WithIdleTimeout 多久没有收到终端数据就断开连接,默认不限制.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return Option{F: func(o *Options) {
+		o.IdleTimeout = timeout
+	}}
+}
+
+// WithHeartbeatMultiple 根据终端心跳间隔(参数0x0001)计算超时时间,超时时间=心跳间隔*倍数.
+func WithHeartbeatMultiple(multiple int) Option {
+	return Option{F: func(o *Options) {
+		o.HeartbeatMultiple = multiple
+	}}
+}
+
+// WithSessionPolicy 终端的key已经在线时的处理策略,默认拒绝新的连接.
+func WithSessionPolicy(policy SessionPolicy) Option {
+	return Option{F: func(o *Options) {
+		o.SessionPolicy = policy
+	}}
+}
+
+// WithOfflineStore 终端离线时缓存平台下发的指令,终端加入后按顺序下发,ttl是默认的有效时间.
+func WithOfflineStore(store OfflineStore, ttl time.Duration) Option {
+	return Option{F: func(o *Options) {
+		o.OfflineStore = store
+		if ttl > 0 {
+			o.OfflineTTL = ttl
+		}
+	}}
+}
+
+// WithOfflineExpireInterval 定时删除过期离线指令的间隔,OfflineStore需要实现OfflineExpirer,默认1分钟.
+func WithOfflineExpireInterval(interval time.Duration) Option {
+	return Option{F: func(o *Options) {
+		o.OfflineExpireInterval = interval
+	}}
+}
+
+// WithOfflineResultFunc 离线指令下发的结果.
+func WithOfflineResultFunc(f func(cmd OfflineCommand, msg *Message)) Option {
+	return Option{F: func(o *Options) {
+		o.OfflineResultFunc = f
+	}}
+}
+
+// WithCorrelator 自定义终端应答和平台下发指令的关联方式.
+func WithCorrelator(correlator Correlator) Option {
+	return Option{F: func(o *Options) {
+		o.Correlator = correlator
+	}}
+}
+
+// WithInboundInterceptor 增加终端上传报文的拦截器,按照增加的顺序执行.
+func WithInboundInterceptor(interceptors ...InboundInterceptor) Option {
+	return Option{F: func(o *Options) {
+		o.InboundInterceptors = append(o.InboundInterceptors, interceptors...)
+	}}
+}
+
+// WithOutboundInterceptor 增加写入终端数据的拦截器,按照增加的顺序执行.
+func WithOutboundInterceptor(interceptors ...OutboundInterceptor) Option {
+	return Option{F: func(o *Options) {
+		o.OutboundInterceptors = append(o.OutboundInterceptors, interceptors...)
+	}}
+}
+
+// WithRateLimit 每个连接的限流,默认不限制.
+func WithRateLimit(limit RateLimit) Option {
+	return Option{F: func(o *Options) {
+		o.RateLimit = limit
+	}}
+}
+
+// WithMaxConnections 最大连接数,超过的新连接直接断开,默认不限制.
+func WithMaxConnections(sum int) Option {
+	return Option{F: func(o *Options) {
+		o.MaxConnections = sum
+	}}
+}
+
+// WithSubcontractTimeout 分包多久没有完成就丢弃,默认60秒,reissueInterval是多久没有收到新的包就请求补传,默认5秒.
+func WithSubcontractTimeout(timeout time.Duration, reissueInterval time.Duration) Option {
+	return Option{F: func(o *Options) {
+		if timeout > 0 {
+			o.SubcontractTimeout = timeout
+		}
+		if reissueInterval > 0 {
+			o.SubcontractReissueInterval = reissueInterval
+		}
+	}}
+}
+
+// WithSubcontractMaxBytes 每个终端未完成的分包最多缓存的字节数,默认不限制.
+func WithSubcontractMaxBytes(maxBytes int) Option {
+	return Option{F: func(o *Options) {
+		o.SubcontractMaxBytes = maxBytes
+	}}
+}
+
+// WithSubcontractStore 分包的存储,连接断开后终端重连可以继续上传,默认不存储.
+func WithSubcontractStore(store SubcontractStore) Option {
+	return Option{F: func(o *Options) {
+		o.SubcontractStore = store
+	}}
+}
+
+// WithFragmentCacheSize 每个连接缓存最近下发的分包消息数量,终端请求补传(0x0005)的时候使用,默认16.
+func WithFragmentCacheSize(size int) Option {
+	return Option{F: func(o *Options) {
+		o.FragmentCacheSize = size
+	}}
+}
+
+// WithRSA 平台的RSA私钥,解密终端加密的消息体,终端上传公钥(0x0A00)后平台下发的消息体使用终端公钥加密.
+// 协议中公钥的n固定128个字节,超过1024位的密钥Start返回错误,不会不加密就启动.
+func WithRSA(privateKey *rsa.PrivateKey) Option {
+	return Option{F: func(o *Options) {
+		o.RSAPrivateKey = privateKey
+	}}
+}
+
+// WithSerialNumberStore 平台流水号的存储,终端重连后继续使用上一次的流水号,默认每次连接从0开始.
+func WithSerialNumberStore(store SerialNumberStore) Option {
+	return Option{F: func(o *Options) {
+		o.SerialNumberStore = store
+	}}
+}
+
+// WithLogger 服务的日志,默认slog.Default(),可以给不同的服务或模块设置不同的日志级别和输出.
+func WithLogger(logger *slog.Logger) Option {
+	return Option{F: func(o *Options) {
+		o.Logger = logger
+	}}
+}
+
+// WithMetrics 统计报文 解析失败 回复耗时 分包 平台下发指令等指标,默认不统计.
+// registry作为http.Handler输出Prometheus文本格式,多个服务共用一个registry的情况指标合并统计.
+func WithMetrics(registry *metrics.Registry) Option {
+	return Option{F: func(o *Options) {
+		o.Metrics = registry
+	}}
+}
+
+// WithCapture 抓包,记录终端上传和平台下发的原始数据,默认不记录.
+// 可以使用NewFileCapture写入轮转的文件,再通过Replay或者ReplayParse回放.
+func WithCapture(capturer Capturer) Option {
+	return Option{F: func(o *Options) {
+		o.Capturer = capturer
+	}}
+}
@@ -12,8 +12,13 @@ import (
 type (
 	packageParse struct {
 		historyData          []byte
-		subcontractingRecord map[uint16][][]byte
-		timeoutRecord        map[uint16]*packageComplete
+		subcontractingRecord map[SubcontractKey][][]byte
+		timeoutRecord        map[SubcontractKey]*packageComplete
+		// bufferedBytes 未完成的分包缓存的字节数
+		bufferedBytes int
+		config        subcontractConfig
+		// cipher 消息体的解密 为空的时候不解密
+		cipher jt808.Cipher
 	}
 
 	packageComplete struct {
@@ -21,25 +26,59 @@ type (
 		updateTime time.Time
 		initHeader *jt808.Header
 	}
+
+	// subcontractConfig 分包的配置.
+	subcontractConfig struct {
+		// timeout 多久没有完成就丢弃
+		timeout time.Duration
+		// reissueInterval 多久没有收到新的包就请求补传
+		reissueInterval time.Duration
+		// maxBytes 未完成的分包最多缓存的字节数 0-不限制
+		maxBytes int
+		// store 分包的存储 为空的时候只在内存中
+		store   SubcontractStore
+		logger  *slog.Logger
+		metrics *serviceMetrics
+	}
 )
 
 func newPackageParse() *packageParse {
 	return &packageParse{
-		subcontractingRecord: make(map[uint16][][]byte),
-		timeoutRecord:        make(map[uint16]*packageComplete),
+		subcontractingRecord: make(map[SubcontractKey][][]byte),
+		timeoutRecord:        make(map[SubcontractKey]*packageComplete),
 		historyData:          make([]byte, 0),
+		config: subcontractConfig{
+			timeout:         defaultSubcontractTimeout,
+			reissueInterval: defaultSubcontractReissueInterval,
+			logger:          slog.Default(),
+		},
+	}
+}
+
+func newSubcontractConfig(opts *Options, metrics *serviceMetrics) subcontractConfig {
+	return subcontractConfig{
+		timeout:         opts.SubcontractTimeout,
+		reissueInterval: opts.SubcontractReissueInterval,
+		maxBytes:        opts.SubcontractMaxBytes,
+		store:           opts.SubcontractStore,
+		logger:          opts.Logger,
+		metrics:         metrics,
 	}
 }
 
 func (p *packageParse) clear() {
 	clear(p.historyData)
-	for id, datas := range p.subcontractingRecord {
-		slog.Warn("package no complete",
-			slog.Any("id", id),
-			slog.Int("data sum", len(datas)))
+	for key, datas := range p.subcontractingRecord {
+		// 设置了存储的情况 终端重连后继续上传
+		p.config.logger.Warn("package no complete",
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()),
+			slog.Int("data sum", len(datas)),
+			slog.Bool("stored", p.config.store != nil))
 	}
 	clear(p.subcontractingRecord)
 	clear(p.timeoutRecord)
+	p.bufferedBytes = 0
 }
 
 // parse 返回一个或者多个完成的包.
@@ -71,7 +110,9 @@ func (p *packageParse) unpack(data []byte) (msgs []*Message, err error) {
 			return count == 2
 		})
 		if index == len(data)-1 {
-			jtMsg := jt808.NewJTMessage()
+			// 读取的缓冲区会复用 报文会交给writer和会话使用 所以复制一份
+			data = bytes.Clone(data)
+			jtMsg := p.newJTMessage()
 			if err := jtMsg.Decode(data); err != nil {
 				return nil, fmt.Errorf("%w [%x]", err, data)
 			}
@@ -93,8 +134,8 @@ func (p *packageParse) unpack(data []byte) (msgs []*Message, err error) {
 		if end == -1 {
 			break
 		}
-		originalData := p.historyData[:end]
-		jtMsg := jt808.NewJTMessage()
+		originalData := bytes.Clone(p.historyData[:end])
+		jtMsg := p.newJTMessage()
 		if err := jtMsg.Decode(originalData); err != nil {
 			p.historyData = p.historyData[end:]
 			return msgs, fmt.Errorf("%w [%x]", err, originalData)
@@ -111,77 +152,197 @@ func (p *packageParse) unpack(data []byte) (msgs []*Message, err error) {
 	return msgs, nil
 }
 
+func (p *packageParse) newJTMessage() *jt808.JTMessage {
+	jtMsg := jt808.NewJTMessage()
+	jtMsg.Header.Cipher = p.cipher
+	return jtMsg
+}
+
 func (p *packageParse) completePack(msg *Message) (*Message, bool) {
 	header := msg.JTMessage.Header
-	if sum := int(header.SubPackageSum); sum > 0 {
-		id := header.ID
-		seq := int(header.SubPackageNo)
-		if seq == 1 {
-			// 第一个包 如果有老id未完成的 就覆盖了
-			if _, ok := p.subcontractingRecord[id]; ok {
-				slog.Warn("not complete package",
-					slog.String("phone", header.TerminalPhoneNo),
-					slog.Int("seq", seq),
-					slog.Any("id", id))
-			}
-			p.add(id, header)
-		}
-
-		if seq > len(p.subcontractingRecord[id]) {
-			slog.Warn("abnormal packet length",
-				slog.Int("seq", seq),
-				slog.Int("record sum", len(p.subcontractingRecord[id])),
-				slog.String("phone", header.TerminalPhoneNo),
-				slog.Any("id", id))
+	sum := int(header.SubPackageSum)
+	if sum == 0 {
+		return nil, false
+	}
+	seq := int(header.SubPackageNo)
+	key := newSubcontractKey(header)
+	if _, ok := p.subcontractingRecord[key]; !ok && !p.restore(key, header) {
+		if seq != 1 {
+			p.config.logger.Warn("abnormal packet length",
+				slog.Int("no", seq),
+				slog.Int("record sum", 0),
+				slog.String("phone", key.Phone),
+				slog.String("subcontract", key.String()))
 			return nil, false
 		}
+		p.add(key, header)
+	}
 
-		p.subcontractingRecord[id][seq-1] = msg.JTMessage.Body
-		p.timeoutRecord[id].updateTime = time.Now()
-		receivedSum := 0
-		for _, data := range p.subcontractingRecord[id] {
-			if len(data) != 0 {
-				receivedSum++
-			}
+	record := p.subcontractingRecord[key]
+	if seq < 1 || seq > len(record) {
+		p.config.logger.Warn("abnormal packet length",
+			slog.Int("no", seq),
+			slog.Int("record sum", len(record)),
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()))
+		return nil, false
+	}
+
+	body := msg.JTMessage.Body
+	p.bufferedBytes += len(body) - len(record[seq-1])
+	record[seq-1] = body
+	p.timeoutRecord[key].updateTime = time.Now()
+	if p.config.maxBytes > 0 && p.bufferedBytes > p.config.maxBytes {
+		p.config.logger.Warn("subcontract over max bytes",
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()),
+			slog.Int("buffered", p.bufferedBytes),
+			slog.Int("max", p.config.maxBytes))
+		p.config.metrics.onSubcontract("discard")
+		p.discard(key)
+		return nil, false
+	}
+	receivedSum := 0
+	for _, data := range record {
+		if len(data) != 0 {
+			receivedSum++
 		}
-		// 接收的和记录的一样 说明完成了
-		if receivedSum == sum {
-			data := make([]byte, 0, sum*1023)
-			for i := 0; i < sum; i++ {
-				data = append(data, p.subcontractingRecord[id][i]...)
-			}
-			p.remove(id)
-			completeMsg := newTerminalMessage(msg.JTMessage, data)
-			completeMsg.Body = data
-			completeMsg.ExtensionFields.SubcontractComplete = true
-			return completeMsg, true
+	}
+	// 接收的和记录的一样 说明完成了
+	if receivedSum == sum {
+		data := make([]byte, 0, sum*1023)
+		for i := 0; i < sum; i++ {
+			data = append(data, record[i]...)
 		}
+		p.discard(key)
+		// 加密的分包 合并后再解密
+		data, err := header.Decrypt(data)
+		if err != nil {
+			p.config.logger.Warn("subcontract decrypt fail",
+				slog.String("phone", key.Phone),
+				slog.String("subcontract", key.String()),
+				slog.Any("err", err))
+			return nil, false
+		}
+		completeMsg := newTerminalMessage(msg.JTMessage, data)
+		completeMsg.Body = data
+		completeMsg.ExtensionFields.SubcontractComplete = true
+		p.config.metrics.onSubcontract("complete")
+		return completeMsg, true
 	}
+	p.save(key, header, body)
 	return nil, false
 }
 
-func (p *packageParse) add(id uint16, header *jt808.Header) {
-	p.subcontractingRecord[id] = make([][]byte, header.SubPackageSum)
+// newSubcontractKey 分包的流水号是连续的 第一个包的流水号=当前流水号-(包序号-1).
+func newSubcontractKey(header *jt808.Header) SubcontractKey {
+	return SubcontractKey{
+		Phone:        header.TerminalPhoneNo,
+		ID:           header.ID,
+		SerialNumber: header.SerialNumber - (header.SubPackageNo - 1),
+	}
+}
+
+func (p *packageParse) add(key SubcontractKey, header *jt808.Header) {
+	for k := range p.subcontractingRecord {
+		if k.ID == key.ID {
+			// 同一个id的新消息 老的未完成的就丢弃了
+			p.config.logger.Warn("not complete package",
+				slog.String("phone", k.Phone),
+				slog.String("subcontract", k.String()),
+				slog.String("new subcontract", key.String()))
+			p.discard(k)
+		}
+	}
+	p.subcontractingRecord[key] = make([][]byte, header.SubPackageSum)
 	now := time.Now()
-	p.timeoutRecord[id] = &packageComplete{
+	p.timeoutRecord[key] = &packageComplete{
 		createTime: now,
 		updateTime: now,
 		initHeader: header,
 	}
 }
 
-func (p *packageParse) remove(id uint16) {
-	delete(p.subcontractingRecord, id)
-	delete(p.timeoutRecord, id)
+// restore 从存储中恢复之前连接收到的分包.
+func (p *packageParse) restore(key SubcontractKey, header *jt808.Header) bool {
+	if p.config.store == nil {
+		return false
+	}
+	record, ok, err := p.config.store.Load(key)
+	if err != nil {
+		p.config.logger.Warn("load subcontract",
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()),
+			slog.Any("err", err))
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if record.Sum != header.SubPackageSum || len(record.Bodies) != int(record.Sum) ||
+		time.Since(record.UpdateTime) > p.config.timeout {
+		p.deleteStore(key)
+		return false
+	}
+	p.add(key, header)
+	for _, body := range record.Bodies {
+		p.bufferedBytes += len(body)
+	}
+	p.subcontractingRecord[key] = record.Bodies
+	p.config.logger.Debug("restore subcontract",
+		slog.String("phone", key.Phone),
+		slog.String("subcontract", key.String()),
+		slog.Int("sum", int(record.Sum)))
+	return true
+}
+
+func (p *packageParse) save(key SubcontractKey, header *jt808.Header, body []byte) {
+	if p.config.store == nil {
+		return
+	}
+	if err := p.config.store.Save(key, header.SubPackageSum, header.SubPackageNo, body); err != nil {
+		p.config.logger.Warn("save subcontract",
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()),
+			slog.Any("err", err))
+	}
+}
+
+func (p *packageParse) deleteStore(key SubcontractKey) {
+	if p.config.store == nil {
+		return
+	}
+	if err := p.config.store.Delete(key); err != nil {
+		p.config.logger.Warn("delete subcontract",
+			slog.String("phone", key.Phone),
+			slog.String("subcontract", key.String()),
+			slog.Any("err", err))
+	}
+}
+
+func (p *packageParse) remove(key SubcontractKey) {
+	for _, body := range p.subcontractingRecord[key] {
+		p.bufferedBytes -= len(body)
+	}
+	delete(p.subcontractingRecord, key)
+	delete(p.timeoutRecord, key)
+}
+
+// discard 完成或者丢弃的分包 存储中的也删除.
+func (p *packageParse) discard(key SubcontractKey) {
+	p.remove(key)
+	p.deleteStore(key)
 }
 
 func (p *packageParse) deleteTimeoutPackage() {
-	now := time.Now().Add(-60 * time.Second)
+	now := time.Now().Add(-p.config.timeout)
 	for k, v := range p.timeoutRecord {
 		if now.After(v.createTime) { // x秒内还没有完成的 就删除了
-			p.remove(k)
-			slog.Warn("timeout",
-				slog.Any("id", k),
+			p.config.metrics.onSubcontract("timeout")
+			p.discard(k)
+			p.config.logger.Warn("timeout",
+				slog.String("phone", k.Phone),
+				slog.String("subcontract", k.String()),
 				slog.String("remove", v.initHeader.String()))
 		}
 	}
@@ -189,18 +350,18 @@ func (p *packageParse) deleteTimeoutPackage() {
 
 func (p *packageParse) supplementarySubPackage() ([]*Message, bool) {
 	msgs := make([]*Message, 0)
-	now := time.Now().Add(-5 * time.Second)
-	for id, v := range p.timeoutRecord {
+	now := time.Now().Add(-p.config.reissueInterval)
+	for key, v := range p.timeoutRecord {
 		if now.After(v.updateTime) {
 			seqs := make([]uint16, 0, v.initHeader.SubPackageSum)
-			for k, record := range p.subcontractingRecord[id] {
+			for k, record := range p.subcontractingRecord[key] {
 				if len(record) == 0 {
 					seqs = append(seqs, uint16(k+1))
 				}
 			}
 			p0x8003 := model.P0x8003{
 				BaseHandle:           model.BaseHandle{},
-				OriginalSerialNumber: v.initHeader.SerialNumber,
+				OriginalSerialNumber: key.SerialNumber,
 				AgainPackageCount:    byte(len(seqs)),
 				AgainPackageList:     seqs,
 			}
@@ -212,6 +373,7 @@ func (p *packageParse) supplementarySubPackage() ([]*Message, bool) {
 			subMsg := newTerminalMessage(jtMsg, data)
 			msgs = append(msgs, subMsg)
 			v.updateTime = time.Now()
+			p.config.metrics.onSubcontract("reissue")
 		}
 	}
 	return msgs, len(msgs) != 0
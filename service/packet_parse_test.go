@@ -1,10 +1,14 @@
 package service
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_packageParse_unpack(t *testing.T) {
@@ -111,3 +115,196 @@ func Test_packageParse_unpack(t *testing.T) {
 		})
 	}
 }
+
+func Test_packageParse_reuseBuffer(t *testing.T) {
+	frames := make([][]byte, 0, 3)
+	for _, v := range []string{
+		"7e0102000801234567890100014141414141414141827e",
+		"7e0102000801234567890100014242424242424242827e",
+		"7e0102000801234567890100014343434343434343827e",
+	} {
+		data, _ := hex.DecodeString(v)
+		frames = append(frames, data)
+	}
+	check := func(t *testing.T, msgs []*Message, want ...[]byte) {
+		t.Helper()
+		if len(msgs) != len(want) {
+			t.Fatalf("unpack() msgs len = %d, want %d", len(msgs), len(want))
+		}
+		for i, msg := range msgs {
+			if !bytes.Equal(msg.ExtensionFields.TerminalData, want[i]) || !bytes.Equal(msg.JTMessage.Body, want[i][13:21]) {
+				t.Errorf("msg[%d] data=[%x] body=[%x] want [%x]", i, msg.ExtensionFields.TerminalData, msg.JTMessage.Body, want[i])
+			}
+		}
+	}
+	// 读取的缓冲区会复用 之前解析的报文不能被后面读取的数据覆盖
+	t.Run("单个-完整包", func(t *testing.T) {
+		p := newPackageParse()
+		buf := make([]byte, 1024)
+		n := copy(buf, frames[0])
+		first, _ := p.unpack(buf[:n])
+		n = copy(buf, frames[1])
+		second, _ := p.unpack(buf[:n])
+		check(t, first, frames[0])
+		check(t, second, frames[1])
+	})
+	t.Run("多个-不完整包", func(t *testing.T) {
+		p := newPackageParse()
+		buf := make([]byte, 1024)
+		n := copy(buf, append(bytes.Clone(frames[0]), frames[1]...))
+		first, _ := p.unpack(buf[:n])
+		// 不完整的包会先缓存起来
+		n = copy(buf, frames[2][:10])
+		_, _ = p.unpack(buf[:n])
+		n = copy(buf, frames[2][10:])
+		second, _ := p.unpack(buf[:n])
+		check(t, first, frames[0], frames[1])
+		check(t, second, frames[2])
+	})
+}
+
+// newSubPackage 生成分包的报文 手机号014419999999.
+func newSubPackage(id uint16, serial uint16, sum uint16, no uint16, body []byte) []byte {
+	data := []byte{byte(id >> 8), byte(id), byte(len(body)>>8) | 0x20, byte(len(body))}
+	data = append(data, 0x01, 0x44, 0x19, 0x99, 0x99, 0x99)
+	data = append(data, byte(serial>>8), byte(serial), byte(sum>>8), byte(sum), byte(no>>8), byte(no))
+	data = append(data, body...)
+	code := byte(0)
+	for _, v := range data {
+		code ^= v
+	}
+	data = append(data, code)
+	frame := []byte{0x7e}
+	for _, v := range data {
+		switch v {
+		case 0x7e:
+			frame = append(frame, 0x7d, 0x02)
+		case 0x7d:
+			frame = append(frame, 0x7d, 0x01)
+		default:
+			frame = append(frame, v)
+		}
+	}
+	return append(frame, 0x7e)
+}
+
+func Test_packageParse_subcontract(t *testing.T) {
+	parse := func(p *packageParse, frame []byte) *Message {
+		msgs, err := p.parse(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range msgs {
+			if msg.ExtensionFields.SubcontractComplete {
+				return msg
+			}
+		}
+		return nil
+	}
+
+	t.Run("重连后继续上传", func(t *testing.T) {
+		store := NewMemorySubcontractStore()
+		p := newPackageParse()
+		p.config.store = store
+		if msg := parse(p, newSubPackage(0x0801, 10, 3, 1, []byte{1, 2})); msg != nil {
+			t.Fatal("not complete")
+		}
+		_ = parse(p, newSubPackage(0x0801, 11, 3, 2, []byte{3, 4}))
+		p.clear()
+
+		p = newPackageParse()
+		p.config.store = store
+		msg := parse(p, newSubPackage(0x0801, 12, 3, 3, []byte{5}))
+		if msg == nil {
+			t.Fatal("want complete")
+		}
+		if !reflect.DeepEqual(msg.Body, []byte{1, 2, 3, 4, 5}) {
+			t.Fatalf("body = %x", msg.Body)
+		}
+		if _, ok, _ := store.Load(SubcontractKey{Phone: "14419999999", ID: 0x0801, SerialNumber: 10}); ok {
+			t.Fatal("want delete after complete")
+		}
+	})
+
+	t.Run("没有存储的情况丢弃", func(t *testing.T) {
+		p := newPackageParse()
+		_ = parse(p, newSubPackage(0x0801, 10, 2, 1, []byte{1, 2}))
+		p.clear()
+		if msg := parse(p, newSubPackage(0x0801, 11, 2, 2, []byte{3})); msg != nil {
+			t.Fatal("want discard")
+		}
+	})
+
+	t.Run("超过最大字节数", func(t *testing.T) {
+		store := NewMemorySubcontractStore()
+		p := newPackageParse()
+		p.config.store = store
+		p.config.maxBytes = 3
+		_ = parse(p, newSubPackage(0x0704, 1, 3, 1, []byte{1, 2}))
+		_ = parse(p, newSubPackage(0x0704, 2, 3, 2, []byte{3, 4}))
+		if len(p.subcontractingRecord) != 0 || p.bufferedBytes != 0 {
+			t.Fatalf("record = %d buffered = %d", len(p.subcontractingRecord), p.bufferedBytes)
+		}
+		if _, ok, _ := store.Load(SubcontractKey{Phone: "14419999999", ID: 0x0704, SerialNumber: 1}); ok {
+			t.Fatal("want delete")
+		}
+	})
+
+	t.Run("补传间隔", func(t *testing.T) {
+		p := newPackageParse()
+		p.config.reissueInterval = time.Millisecond
+		heartbeat, _ := hex.DecodeString("7e000200000144199999990007c07e")
+		_ = parse(p, newSubPackage(0x0801, 20, 3, 1, []byte{1}))
+		time.Sleep(5 * time.Millisecond)
+		msgs, err := p.parse(heartbeat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 2 || msgs[1].Command != consts.P8003ReissueSubcontractingRequest {
+			t.Fatalf("want 0x8003 msgs = %d", len(msgs))
+		}
+		p0x8003 := model.P0x8003{}
+		if err := p0x8003.Parse(msgs[1].JTMessage); err != nil {
+			t.Fatal(err)
+		}
+		if p0x8003.OriginalSerialNumber != 20 || !reflect.DeepEqual(p0x8003.AgainPackageList, []uint16{2, 3}) {
+			t.Fatalf("0x8003 = %+v", p0x8003)
+		}
+	})
+}
+
+func TestFileSubcontractStore(t *testing.T) {
+	store, err := NewFileSubcontractStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := SubcontractKey{Phone: "014419999999", ID: 0x0801, SerialNumber: 1}
+	if err := store.Save(key, 3, 1, []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(key, 3, 3, []byte{5}); err != nil {
+		t.Fatal(err)
+	}
+	record, ok, err := store.Load(key)
+	if err != nil || !ok {
+		t.Fatalf("load ok = %t err = %v", ok, err)
+	}
+	want := [][]byte{{1, 2}, nil, {5}}
+	if record.Sum != 3 || !reflect.DeepEqual(record.Bodies, want) {
+		t.Fatalf("record = %+v", record)
+	}
+	// 总包数不一样 之前的无效
+	if err := store.Save(key, 2, 1, []byte{9}); err != nil {
+		t.Fatal(err)
+	}
+	record, _, _ = store.Load(key)
+	if record.Sum != 2 || !reflect.DeepEqual(record.Bodies, [][]byte{{9}, nil}) {
+		t.Fatalf("record = %+v", record)
+	}
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Load(key); ok {
+		t.Fatal("want delete")
+	}
+}
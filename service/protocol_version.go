@@ -0,0 +1,47 @@
+package service
+
+import (
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"sync"
+)
+
+// defaultProtocolVersionSize 默认记录的终端协议版本数量.
+const defaultProtocolVersionSize = 100000
+
+// protocolVersions 终端的协议版本 key是手机号.
+// 2011和2013版本的请求头一样 只能从注册消息(0x0100)判断 记录下来 终端重连后只鉴权的情况也能使用.
+// 终端离开后也要保留 超过size的删除最早记录的.
+type protocolVersions struct {
+	mu   sync.RWMutex
+	size int
+	// order 按照记录的顺序
+	order  []string
+	record map[string]consts.ProtocolVersionType
+}
+
+func newProtocolVersions(size int) *protocolVersions {
+	return &protocolVersions{
+		size:   size,
+		record: make(map[string]consts.ProtocolVersionType),
+	}
+}
+
+func (p *protocolVersions) load(phone string) (consts.ProtocolVersionType, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	v, ok := p.record[phone]
+	return v, ok
+}
+
+func (p *protocolVersions) store(phone string, version consts.ProtocolVersionType) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.record[phone]; !ok {
+		if len(p.order) >= p.size {
+			delete(p.record, p.order[0])
+			p.order = p.order[1:]
+		}
+		p.order = append(p.order, phone)
+	}
+	p.record[phone] = version
+}
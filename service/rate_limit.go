@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitAction 终端超过限制后的处理.
+type RateLimitAction uint8
+
+const (
+	// RateLimitThrottle 限速 暂停读取终端的数据 默认.
+	RateLimitThrottle RateLimitAction = iota
+	// RateLimitDisconnect 断开连接.
+	RateLimitDisconnect
+	// RateLimitBan 断开连接 并且一段时间内禁止接入.
+	RateLimitBan
+	// RateLimitConnectionLimit 超过了最大连接数 新的连接直接断开 只用于事件和统计.
+	RateLimitConnectionLimit
+)
+
+func (r RateLimitAction) String() string {
+	switch r {
+	case RateLimitThrottle:
+		return "限速"
+	case RateLimitDisconnect:
+		return "断开连接"
+	case RateLimitBan:
+		return "禁止接入"
+	case RateLimitConnectionLimit:
+		return "超过最大连接数"
+	default:
+	}
+	return "未知的限流处理"
+}
+
+type (
+	// RateLimit 每个连接的限制.
+	RateLimit struct {
+		// MessagesPerSecond 每秒最多的报文数 分包的每个包都算 0-不限制
+		MessagesPerSecond int
+		// BytesPerSecond 每秒最多的字节数 0-不限制
+		BytesPerSecond int
+		// Action 超过限制后的处理 默认限速
+		Action RateLimitAction
+		// BanDuration Action是RateLimitBan的情况 禁止接入的时间 默认1分钟
+		BanDuration time.Duration
+	}
+
+	// RateLimitStats 限流的统计.
+	RateLimitStats struct {
+		// Throttled 限速的次数
+		Throttled uint64 `json:"throttled"`
+		// Disconnected 断开连接的次数
+		Disconnected uint64 `json:"disconnected"`
+		// Banned 禁止接入的次数
+		Banned uint64 `json:"banned"`
+		// ConnectionRejected 超过最大连接数断开的次数
+		ConnectionRejected uint64 `json:"connectionRejected"`
+	}
+
+	rateLimitCounter struct {
+		throttled          atomic.Uint64
+		disconnected       atomic.Uint64
+		banned             atomic.Uint64
+		connectionRejected atomic.Uint64
+	}
+
+	// tokenBucket 令牌桶 每秒补充rate个 最多rate个.
+	tokenBucket struct {
+		rate   float64
+		tokens float64
+		last   time.Time
+	}
+)
+
+// defaultBanDuration 默认禁止接入的时间.
+const defaultBanDuration = time.Minute
+
+func newTokenBucket(rate int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// take 取出n个令牌 不够的情况返回需要等待的时间.
+func (t *tokenBucket) take(n int, now time.Time) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.tokens = min(t.rate, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	t.last = now
+	t.tokens -= float64(n)
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / t.rate * float64(time.Second))
+}
+
+// RateLimitStats 限流的统计.
+func (g *GoJT808) RateLimitStats() RateLimitStats {
+	return RateLimitStats{
+		Throttled:          g.rateLimitCounter.throttled.Load(),
+		Disconnected:       g.rateLimitCounter.disconnected.Load(),
+		Banned:             g.rateLimitCounter.banned.Load(),
+		ConnectionRejected: g.rateLimitCounter.connectionRejected.Load(),
+	}
+}
+
+func (g *GoJT808) onRateLimit(key string, action RateLimitAction) {
+	switch action {
+	case RateLimitThrottle:
+		g.rateLimitCounter.throttled.Add(1)
+	case RateLimitDisconnect:
+		g.rateLimitCounter.disconnected.Add(1)
+	case RateLimitBan:
+		if g.ban(key) {
+			g.rateLimitCounter.banned.Add(1)
+		}
+	case RateLimitConnectionLimit:
+		g.rateLimitCounter.connectionRejected.Add(1)
+	}
+}
+
+// ban 禁止key接入 key是终端的key或者加入之前的ip 返回false的说明没有记录.
+func (g *GoJT808) ban(key string) bool {
+	if key == "" {
+		return false
+	}
+	duration := g.opts.RateLimit.BanDuration
+	if duration <= 0 {
+		duration = defaultBanDuration
+	}
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// 顺便清理已经过期的 避免一直增长
+	for k, until := range g.bans {
+		if now.After(until) {
+			delete(g.bans, k)
+		}
+	}
+	g.bans[key] = now.Add(duration)
+	return true
+}
+
+// banned 是否在禁止接入的时间内 过期的直接删除.
+func (g *GoJT808) banned(key string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until, ok := g.bans[key]
+	if ok && time.Now().After(until) {
+		delete(g.bans, key)
+		return until, false
+	}
+	return until, ok
+}
+
+// join 禁止接入的终端直接返回失败.
+func (g *GoJT808) join(message *Message, conn *connection) (string, bool, error) {
+	if key, ok := g.opts.KeyFunc(message); ok {
+		if until, banned := g.banned(key); banned {
+			return key, false, errors.Join(fmt.Errorf("key[%s] ban until[%s]",
+				key, until.Format(time.RFC3339)), _errKeyBanned)
+		}
+	}
+	return g.sessionManager.join(message, conn)
+}
+
+// remoteHost 终端的ip 终端加入之前限流使用.
+func remoteHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
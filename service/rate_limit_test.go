@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(10)
+	bucket.last = now
+	if wait := bucket.take(10, now); wait != 0 {
+		t.Errorf("take() = %s, want 0", wait)
+	}
+	if wait := bucket.take(5, now); wait != 500*time.Millisecond {
+		t.Errorf("take() = %s, want 500ms", wait)
+	}
+	// 1秒后补充10个 还有5个
+	if wait := bucket.take(5, now.Add(time.Second)); wait != 0 {
+		t.Errorf("take() = %s, want 0", wait)
+	}
+	if wait := newTokenBucket(0).take(100, now); wait != 0 {
+		t.Errorf("nil take() = %s, want 0", wait)
+	}
+}
+
+func TestGoJT808RateLimit(t *testing.T) {
+	// 一次发送3个心跳
+	heartbeats := strings.Repeat(_heartBeatMsg, 3)
+	tests := []struct {
+		name       string
+		action     RateLimitAction
+		wantReason LeaveReason
+		wantStats  RateLimitStats
+	}{
+		{name: "限速", action: RateLimitThrottle, wantStats: RateLimitStats{Throttled: 1}},
+		{name: "断开连接", action: RateLimitDisconnect, wantReason: LeaveReasonRateLimit, wantStats: RateLimitStats{Disconnected: 1}},
+		{name: "禁止接入", action: RateLimitBan, wantReason: LeaveReasonRateLimit, wantStats: RateLimitStats{Banned: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			event := &lifecycleTerminal{join: make(chan string, 2)}
+			goJt808 := New(
+				WithHostPorts(addr),
+				WithCustomTerminalEventer(func() TerminalEventer {
+					return event
+				}),
+				WithRateLimit(RateLimit{MessagesPerSecond: 2, Action: tt.action}),
+			)
+			go func() {
+				_ = goJt808.Start(context.Background())
+			}()
+			defer func() {
+				_ = goJt808.Shutdown(context.Background())
+			}()
+			conn := dialAndSend(t, addr, heartbeats)
+			defer func() {
+				_ = conn.Close()
+			}()
+			if tt.wantReason == 0 {
+				// 限速的情况 等待后3个心跳都会回复
+				replies := 0
+				for replies < 3 {
+					data := make([]byte, 1024)
+					_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+					n, err := conn.Read(data)
+					if err != nil {
+						t.Fatal(err)
+					}
+					replies += strings.Count(string(data[:n]), "\x7e\x80\x01")
+				}
+			} else if reason := event.waitLeaveReason(t, 3*time.Second); reason != tt.wantReason {
+				t.Errorf("leave reason = %s", reason)
+			}
+			if stats := goJt808.RateLimitStats(); stats != tt.wantStats {
+				t.Errorf("RateLimitStats() = %+v, want %+v", stats, tt.wantStats)
+			}
+			event.mu.Lock()
+			if len(event.actions) != 1 || event.actions[0] != tt.action {
+				t.Errorf("actions = %v", event.actions)
+			}
+			event.mu.Unlock()
+
+			if tt.action == RateLimitBan {
+				// 禁止接入的时间内 重新连接直接断开
+				conn := dialAndSend(t, addr, _heartBeatMsg)
+				defer func() {
+					_ = conn.Close()
+				}()
+				_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+				// 断开的时候终端的数据没有读取 可能是EOF或者reset
+				if _, err := conn.Read(make([]byte, 1024)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+					t.Errorf("banned read err = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGoJT808MaxConnections(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithMaxConnections(1),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	<-event.join
+
+	other := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = other.Close()
+	}()
+	_ = other.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := other.Read(make([]byte, 1024)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("read err = %v", err)
+	}
+	if stats := goJt808.RateLimitStats(); stats.ConnectionRejected != 1 {
+		t.Errorf("RateLimitStats() = %+v", stats)
+	}
+}
+
+func TestGoJT808RateLimitBeforeJoin(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+		WithRateLimit(RateLimit{BytesPerSecond: 10, Action: RateLimitBan}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	// 还没有完整的包 终端没有加入
+	conn := dialAndSend(t, addr, "7e0100002d0144199999990001000b0065373034343358485830303030")
+	defer func() {
+		_ = conn.Close()
+	}()
+	if reason := event.waitLeaveReason(t, 3*time.Second); reason != LeaveReasonRateLimit {
+		t.Errorf("leave reason = %s", reason)
+	}
+	if stats := goJt808.RateLimitStats(); stats.Banned != 1 {
+		t.Errorf("RateLimitStats() = %+v", stats)
+	}
+	// 同一个ip 禁止的时间内重新连接直接断开
+	again := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = again.Close()
+	}()
+	_ = again.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := again.Read(make([]byte, 1024)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("banned read err = %v", err)
+	}
+}
+
+func TestGoJT808Ban(t *testing.T) {
+	goJt808 := New(WithRateLimit(RateLimit{BanDuration: time.Millisecond}))
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	// 没有key的不记录 也不统计
+	goJt808.onRateLimit("", RateLimitBan)
+	if stats := goJt808.RateLimitStats(); stats.Banned != 0 {
+		t.Errorf("RateLimitStats() = %+v", stats)
+	}
+	goJt808.onRateLimit("1001", RateLimitBan)
+	if _, ok := goJt808.banned("1001"); !ok {
+		t.Error("banned() want true")
+	}
+	time.Sleep(5 * time.Millisecond)
+	// 新的禁止接入的时候 清理过期的
+	goJt808.onRateLimit("1002", RateLimitBan)
+	goJt808.mu.Lock()
+	_, ok := goJt808.bans["1001"]
+	sum := len(goJt808.bans)
+	goJt808.mu.Unlock()
+	if ok || sum != 1 {
+		t.Errorf("bans = %d expired exist = %t", sum, ok)
+	}
+	if stats := goJt808.RateLimitStats(); stats.Banned != 2 {
+		t.Errorf("RateLimitStats() = %+v", stats)
+	}
+}
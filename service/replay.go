@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// Replay 按照抓包的原始时间间隔 把终端上传的数据发送给addr的tcp服务 用于离线复现现场的问题.
+// 每个远程地址单独建立一个tcp连接 平台下发的记录不发送 服务的回复读取后丢弃.
+// speed是回放速度的倍数 例如2是两倍速 小于等于0的不等待. udp服务使用ReplayNetwork.
+func Replay(ctx context.Context, addr string, records []CaptureRecord, speed float64) error {
+	return ReplayNetwork(ctx, "tcp", addr, records, speed)
+}
+
+// ReplayNetwork 和Replay一样 network支持tcp udp.
+// udp的每个远程地址使用单独的本地端口 每条记录作为一个数据报发送.
+func ReplayNetwork(ctx context.Context, network string, addr string, records []CaptureRecord, speed float64) error {
+	conns := make(map[string]net.Conn)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	var dialer net.Dialer
+	return replayInbound(ctx, records, speed, func(record CaptureRecord) error {
+		conn, ok := conns[record.Remote]
+		if !ok {
+			var err error
+			if conn, err = dialer.DialContext(ctx, network, addr); err != nil {
+				return fmt.Errorf("replay dial %s [%s]: %w", network, addr, err)
+			}
+			conns[record.Remote] = conn
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+		if _, err := conn.Write(record.Data); err != nil {
+			return fmt.Errorf("replay write remote [%s]: %w", record.Remote, err)
+		}
+		return nil
+	})
+}
+
+// ReplayParse 按照抓包的原始时间间隔 把终端上传的数据交给分包解析 返回解析完成的消息.
+// 每个远程地址使用单独的解析 不需要启动服务就可以复现分包和转义的问题.
+func ReplayParse(ctx context.Context, records []CaptureRecord, speed float64) ([]*Message, error) {
+	packs := make(map[string]*packageParse)
+	msgs := make([]*Message, 0)
+	err := replayInbound(ctx, records, speed, func(record CaptureRecord) error {
+		pack, ok := packs[record.Remote]
+		if !ok {
+			pack = newPackageParse()
+			packs[record.Remote] = pack
+		}
+		v, err := pack.parse(record.Data)
+		msgs = append(msgs, v...)
+		if err != nil {
+			return errors.Join(fmt.Errorf("replay parse remote [%s] data [%x]", record.Remote, []byte(record.Data)), err)
+		}
+		return nil
+	})
+	return msgs, err
+}
+
+// replayInbound 终端上传的记录按照时间排序后 根据原始的时间间隔依次执行f.
+func replayInbound(ctx context.Context, records []CaptureRecord, speed float64, f func(record CaptureRecord) error) error {
+	inbound := make([]CaptureRecord, 0, len(records))
+	for _, record := range records {
+		if record.Direction == CaptureIn {
+			inbound = append(inbound, record)
+		}
+	}
+	sort.SliceStable(inbound, func(i, j int) bool {
+		return inbound[i].Time.Before(inbound[j].Time)
+	})
+	for i, record := range inbound {
+		if i > 0 && speed > 0 {
+			wait := time.Duration(float64(record.Time.Sub(inbound[i-1].Time)) / speed)
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
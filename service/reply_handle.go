@@ -0,0 +1,48 @@
+package service
+
+import (
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+)
+
+// replyHandle 替换报文的回复 注册鉴权和拦截器使用.
+type replyHandle struct {
+	Handler
+	// hasReply 是否回复终端
+	hasReply bool
+	// protocol 回复的协议类型 为0的使用Handler的
+	protocol consts.JT808CommandType
+	// body 回复的body
+	body []byte
+	// leaveReason 回复后断开连接的原因 为0的不断开
+	leaveReason LeaveReason
+	// silent 不触发读写事件 未鉴权或者被拦截的报文使用
+	silent bool
+}
+
+func (r *replyHandle) HasReply() bool {
+	return r.hasReply
+}
+
+func (r *replyHandle) ReplyBody(_ *jt808.JTMessage) ([]byte, error) {
+	return r.body, nil
+}
+
+func (r *replyHandle) ReplyProtocol() consts.JT808CommandType {
+	if r.protocol != 0 {
+		return r.protocol
+	}
+	return r.Handler.ReplyProtocol()
+}
+
+func (r *replyHandle) OnReadExecutionEvent(msg *Message) {
+	if !r.silent {
+		r.Handler.OnReadExecutionEvent(msg)
+	}
+}
+
+func (r *replyHandle) OnWriteExecutionEvent(msg Message) {
+	if !r.silent {
+		r.Handler.OnWriteExecutionEvent(msg)
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"sync"
+)
+
+type (
+	// SerialNumberStore 平台流水号的存储 终端重连后继续使用上一次的流水号 避免终端丢弃重复流水号的指令.
+	SerialNumberStore interface {
+		// Load 获取key下一次下发使用的流水号 不存在的情况返回false
+		Load(key string) (uint16, bool, error)
+		// Save 连接断开的时候保存key下一次下发使用的流水号
+		Save(key string, seq uint16) error
+	}
+
+	// MemorySerialNumberStore 内存存储的平台流水号 服务重启后从0开始.
+	MemorySerialNumberStore struct {
+		mu     sync.Mutex
+		record map[string]uint16
+		// onChange 流水号变化后的回调 用于持久化
+		onChange func(record map[string]uint16) error
+	}
+)
+
+func NewMemorySerialNumberStore() *MemorySerialNumberStore {
+	return &MemorySerialNumberStore{
+		record: make(map[string]uint16),
+	}
+}
+
+func (m *MemorySerialNumberStore) Load(key string) (uint16, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seq, ok := m.record[key]
+	return seq, ok, nil
+}
+
+func (m *MemorySerialNumberStore) Save(key string, seq uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.record[key]
+	m.record[key] = seq
+	if m.onChange != nil {
+		if err := m.onChange(m.record); err != nil {
+			if ok {
+				m.record[key] = old
+			} else {
+				delete(m.record, key)
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileSerialNumberStore 文件存储的平台流水号 连接断开后写入json文件 服务重启后继续使用.
+type FileSerialNumberStore struct {
+	*MemorySerialNumberStore
+	path string
+}
+
+// NewFileSerialNumberStore 读取path的平台流水号 文件不存在的情况在第一次保存的时候创建.
+func NewFileSerialNumberStore(path string) (*FileSerialNumberStore, error) {
+	f := &FileSerialNumberStore{
+		MemorySerialNumberStore: NewMemorySerialNumberStore(),
+		path:                    path,
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read serial number file [%s]: %w", path, err)
+	case len(data) > 0:
+		if err := json.Unmarshal(data, &f.MemorySerialNumberStore.record); err != nil {
+			return nil, fmt.Errorf("unmarshal serial number file [%s]: %w", path, err)
+		}
+	}
+	f.MemorySerialNumberStore.onChange = f.save
+	return f, nil
+}
+
+// save 先写临时文件再替换 避免写入一半的情况.
+func (f *FileSerialNumberStore) save(record map[string]uint16) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save serial number file [%s]: %w", f.path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save serial number file [%s]: %w", f.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save serial number file [%s]: %w", f.path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save serial number file [%s]: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSerialNumberStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serial.json")
+	store, err := NewFileSerialNumberStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("1001", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("1001", 65535); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后继续使用
+	store, err = NewFileSerialNumberStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq, ok, err := store.Load("1001"); err != nil || !ok || seq != 65535 {
+		t.Errorf("Load() = %d %t %v", seq, ok, err)
+	}
+	if _, ok, _ := store.Load("1002"); ok {
+		t.Errorf("Load() key not exist")
+	}
+}
+
+func TestGoJT808SerialNumberStore(t *testing.T) {
+	addr := freeAddr(t)
+	store := NewMemorySerialNumberStore()
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithSerialNumberStore(store),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	const key = "14419999999"
+	replySeq := func() uint16 {
+		conn := dialAndSend(t, addr, _heartBeatMsg)
+		defer func() {
+			_ = conn.Close()
+		}()
+		frames := readFrames(t, conn, 1)
+		jtMsg := jt808.NewJTMessage()
+		if err := jtMsg.Decode(frames[0]); err != nil {
+			t.Fatalf("decode [%s] err = %v", hex.EncodeToString(frames[0]), err)
+		}
+		if info, ok := goJt808.Session(key); !ok || info.PlatformSerialNumber != jtMsg.Header.SerialNumber+1 {
+			t.Errorf("Session() = %v %t", info, ok)
+		}
+		return jtMsg.Header.SerialNumber
+	}
+	waitSaved := func(want uint16) {
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if seq, ok, _ := store.Load(key); ok && seq == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("wait serial number [%d] saved timeout", want)
+	}
+
+	if seq := replySeq(); seq != 0 {
+		t.Fatalf("first reply seq = %d", seq)
+	}
+	waitSaved(1)
+	// 重连后继续使用上一次的流水号
+	if seq := replySeq(); seq != 1 {
+		t.Errorf("reconnect reply seq = %d", seq)
+	}
+	waitSaved(2)
+}
+
+func TestGoJT808SerialNumberReplaced(t *testing.T) {
+	addr := freeAddr(t)
+	store := NewMemorySerialNumberStore()
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithSerialNumberStore(store),
+		WithSessionPolicy(SessionPolicyReplaceOld),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	const key = "14419999999"
+	old := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = old.Close()
+	}()
+	_ = readFrames(t, old, 1)
+	// 旧连接下发中 还没有收到终端应答
+	const sum = 5
+	for i := 0; i < sum; i++ {
+		_ = goJt808.SendActiveMessageAsync(context.Background(),
+			NewActiveMessage(key, consts.P8201QueryLocation, nil, time.Second))
+	}
+	_ = readFrames(t, old, sum)
+
+	cur := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = cur.Close()
+	}()
+	jtMsg := jt808.NewJTMessage()
+	if err := jtMsg.Decode(readFrames(t, cur, 1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if jtMsg.Header.SerialNumber != sum+1 {
+		t.Errorf("replaced reply seq = %d, want %d", jtMsg.Header.SerialNumber, sum+1)
+	}
+	// 旧连接断开 不覆盖新连接的流水号
+	_ = old.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := old.Read(make([]byte, 1024)); !errors.Is(err, io.EOF) {
+		t.Errorf("old read err = %v, want EOF", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if seq, ok, _ := store.Load(key); ok {
+		t.Errorf("old saved seq = %d", seq)
+	}
+
+	_ = cur.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if seq, ok, _ := store.Load(key); ok {
+			if seq != sum+2 {
+				t.Errorf("saved seq = %d, want %d", seq, sum+2)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("wait serial number saved timeout")
+}
@@ -1,93 +1,411 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/cuteLittleDevil/go-jt808/protocol/model"
 	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"io"
 	"log/slog"
 	"net"
+	"sync"
+	"time"
 )
 
 type GoJT808 struct {
 	opts *Options
 	*sessionManager
+	// mu 保护listener和conns
+	mu       sync.Mutex
+	listener io.Closer
+	// conns 当前所有的连接 关闭服务的时候使用
+	conns map[*connection]struct{}
+	// closing 服务是否正在关闭
+	closing      bool
+	shutdownOnce sync.Once
+	// doneChan 服务完全关闭后close
+	doneChan chan struct{}
+	// activeWg 正在进行中的平台下发指令
+	activeWg sync.WaitGroup
+	// offlineDelivering 正在下发离线指令的终端 true的说明下发中又有新的指令 结束后再检查一次
+	offlineDelivering map[string]bool
+	// bans 禁止接入的终端和截止时间 终端加入之前使用ip
+	bans             map[string]time.Time
+	rateLimitCounter rateLimitCounter
+	// versions 终端注册时判断的协议版本
+	versions *protocolVersions
+	// metrics 服务的指标 没有设置的为空
+	metrics *serviceMetrics
+	// upgrades 进行中的远程升级任务
+	upgrades *upgradeJobs
 }
 
 func New(opts ...Option) *GoJT808 {
 	options := newOptions(opts)
 	g := &GoJT808{
-		opts: options,
+		opts:              options,
+		conns:             make(map[*connection]struct{}),
+		doneChan:          make(chan struct{}),
+		offlineDelivering: make(map[string]bool),
+		bans:              make(map[string]time.Time),
+		versions:          newProtocolVersions(defaultProtocolVersionSize),
+		metrics:           newServiceMetrics(options.Metrics),
+		upgrades:          newUpgradeJobs(),
 	}
 	keyFunc := g.opts.KeyFunc
-	g.sessionManager = newSessionManager(keyFunc)
+	g.sessionManager = newSessionManager(keyFunc, g.opts.SessionPolicy)
 	go g.sessionManager.run()
+	if v, ok := options.OfflineStore.(OfflineExpirer); ok {
+		go g.runOfflineExpire(v, options.OfflineExpireInterval)
+	}
+	if options.Metrics != nil {
+		options.Metrics.NewGaugeFunc("jt808_sessions", "online jt808 sessions", func() float64 {
+			return float64(g.SessionCount())
+		})
+	}
 	return g
 }
 
+// Run 启动服务 阻塞直到服务关闭 监听失败的情况只打印日志.
 func (g *GoJT808) Run() {
-	addr, err := net.ResolveTCPAddr(g.opts.Network, g.opts.Addr)
-	if err != nil {
-		slog.Error("resolve tcp addr error",
+	if err := g.Start(context.Background()); err != nil && !errors.Is(err, ErrServerClosed) {
+		g.opts.Logger.Error("run fail",
 			slog.String("addr", g.opts.Addr),
 			slog.String("network", g.opts.Network),
 			slog.Any("err", err))
 	}
+}
+
+// Start 启动服务 阻塞直到服务完全关闭.
+// 监听失败或者RSA密钥不支持直接返回错误 ctx结束或者调用Shutdown后返回ErrServerClosed
+// ctx结束的情况不等待平台下发的指令完成 直接关闭全部连接.
+func (g *GoJT808) Start(ctx context.Context) error {
+	if key := g.opts.RSAPrivateKey; key != nil {
+		// 平台公钥需要通过0x8A00下发 不支持的密钥不启动
+		if err := (&model.P0x8A00{}).SetPublicKey(&key.PublicKey); err != nil {
+			return fmt.Errorf("rsa private key: %w", err)
+		}
+	}
+	switch g.opts.Network {
+	case "udp", "udp4", "udp6":
+		return g.startUDP(ctx)
+	default:
+		return g.startTCP(ctx)
+	}
+}
+
+func (g *GoJT808) startTCP(ctx context.Context) error {
+	addr, err := net.ResolveTCPAddr(g.opts.Network, g.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("resolve tcp addr [%s]: %w", g.opts.Addr, err)
+	}
 
 	in, err := net.ListenTCP(g.opts.Network, addr)
 	if err != nil {
-		slog.Error("tcp listen fail",
-			slog.Any("addr", addr),
-			slog.Any("err", err))
-		return
+		return fmt.Errorf("tcp listen [%s]: %w", g.opts.Addr, err)
+	}
+	stop, ok := g.trackListener(ctx, in)
+	if !ok {
+		return ErrServerClosed
 	}
+	defer stop()
 
 	for {
 		c, err := in.AcceptTCP()
 		if err != nil {
-			slog.Warn("accept fail",
+			if g.shuttingDown() {
+				<-g.doneChan
+				return ErrServerClosed
+			}
+			g.opts.Logger.Warn("accept fail",
+				slog.Any("err", err))
+			continue
+		}
+		g.serve(c)
+	}
+}
+
+// startUDP udp的情况 每个终端(手机号)模拟成一个连接 不区分远程地址.
+// 终端的地址变化(如NAT重新映射)还是同一个会话 回复和平台下发的都发往终端最后的地址.
+func (g *GoJT808) startUDP(ctx context.Context) error {
+	pc, err := net.ListenPacket(g.opts.Network, g.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("udp listen [%s]: %w", g.opts.Addr, err)
+	}
+	stop, ok := g.trackListener(ctx, pc)
+	if !ok {
+		return ErrServerClosed
+	}
+	defer stop()
+
+	sessions := newUDPSessions(pc, g.opts.Logger)
+	expireStop := make(chan struct{})
+	defer close(expireStop)
+	go sessions.runExpire(g.opts.UDPSessionTimeout, expireStop)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if g.shuttingDown() {
+				<-g.doneChan
+				return ErrServerClosed
+			}
+			g.opts.Logger.Warn("udp read fail",
 				slog.Any("err", err))
 			continue
 		}
-		handles := g.createDefaultHandle()
-		customHandles := g.opts.CustomHandleFunc()
-		for k, v := range customHandles {
-			handles[k] = v
+		data := bytes.Clone(buf[:n])
+		u, data, created := sessions.load(data, addr)
+		if u == nil {
+			continue
+		}
+		if created {
+			g.serve(u)
 		}
-		terminalEvent := g.opts.CustomTerminalEventerFunc()
-		conn := newConnection(c, handles, terminalEvent, g.opts.FilterSubcontract,
-			g.sessionManager.join, g.sessionManager.leave)
-		go conn.Start()
+		u.deliver(data, addr)
 	}
 }
 
+// Shutdown 优雅关闭服务.
+// 先停止接收新的连接 然后等待平台下发的指令完成(或者ctx结束)
+// 最后关闭全部连接 每个连接都会触发OnLeaveEvent.
+func (g *GoJT808) Shutdown(ctx context.Context) error {
+	var err error
+	g.shutdownOnce.Do(func() {
+		g.mu.Lock()
+		g.closing = true
+		if g.listener != nil {
+			_ = g.listener.Close()
+		}
+		g.mu.Unlock()
+
+		err = g.waitActiveMessages(ctx)
+
+		g.mu.Lock()
+		conns := make([]*connection, 0, len(g.conns))
+		for conn := range g.conns {
+			conns = append(conns, conn)
+		}
+		g.mu.Unlock()
+		for _, conn := range conns {
+			conn.close(LeaveReasonShutdown)
+		}
+		for _, conn := range conns {
+			<-conn.stopChan
+		}
+		g.sessionManager.stop()
+		close(g.doneChan)
+	})
+	return err
+}
+
+// SendActiveMessage 平台下发指令 阻塞直到终端应答或者超时.
 func (g *GoJT808) SendActiveMessage(activeMsg *ActiveMessage) *Message {
-	return g.sessionManager.write(activeMsg)
+	return g.SendActiveMessageContext(context.Background(), activeMsg)
+}
+
+// SendActiveMessageContext 平台下发指令 ctx结束的情况直接返回ctx.Err().
+func (g *GoJT808) SendActiveMessageContext(ctx context.Context, activeMsg *ActiveMessage) *Message {
+	return <-g.SendActiveMessageAsync(ctx, activeMsg)
+}
+
+// SendActiveMessageAsync 平台下发指令 不阻塞 结果(终端应答 超时 ctx结束等)写入返回的chan.
+// 等待中的指令不占用协程 适合大量下发的情况.
+// 设置了离线缓存的情况 终端不在线的时候缓存指令 返回ErrActiveQueued.
+func (g *GoJT808) SendActiveMessageAsync(ctx context.Context, activeMsg *ActiveMessage) <-chan *Message {
+	return g.sendActiveMessage(ctx, activeMsg, g.opts.OfflineStore != nil)
+}
+
+// sendActiveMessage queue=true的情况 终端不在线的时候缓存指令.
+func (g *GoJT808) sendActiveMessage(ctx context.Context, activeMsg *ActiveMessage, queue bool) <-chan *Message {
+	replyChan := make(chan *Message, 1)
+	start := time.Now()
+	g.mu.Lock()
+	if g.closing {
+		g.mu.Unlock()
+		g.metrics.onActiveMessage(activeMsg.Command, ErrServerClosed, time.Since(start))
+		replyChan <- newErrMessage(ErrServerClosed)
+		return replyChan
+	}
+	g.activeWg.Add(1)
+	g.mu.Unlock()
+	g.sessionManager.write(ctx, activeMsg, func(msg *Message) {
+		if queue && errors.Is(msg.ExtensionFields.Err, ErrNotExistKey) {
+			// 不在线的回复是在sessionManager中执行的 缓存可能写文件 不阻塞sessionManager
+			go func() {
+				if err := g.queueActiveMessage(activeMsg); err != nil {
+					msg = newErrMessage(errors.Join(msg.ExtensionFields.Err, err))
+				} else {
+					msg = newErrMessage(errors.Join(ErrActiveQueued,
+						fmt.Errorf("key=[%s] command=[%s]", activeMsg.Key, activeMsg.Command)))
+				}
+				g.metrics.onActiveMessage(activeMsg.Command, msg.ExtensionFields.Err, time.Since(start))
+				replyChan <- msg
+				g.activeWg.Done()
+			}()
+			return
+		}
+		g.metrics.onActiveMessage(activeMsg.Command, msg.ExtensionFields.Err, time.Since(start))
+		replyChan <- msg
+		g.activeWg.Done()
+	})
+	return replyChan
+}
+
+// Sessions 全部在线终端的会话信息.
+func (g *GoJT808) Sessions() []SessionInfo {
+	return g.sessionManager.sessions("")
+}
+
+// Session 查询key的会话信息 允许多个连接的情况返回最后加入的.
+func (g *GoJT808) Session(key string) (SessionInfo, bool) {
+	if infos := g.sessionManager.sessions(key); len(infos) > 0 {
+		return infos[len(infos)-1], true
+	}
+	return SessionInfo{}, false
+}
+
+// SessionCount 在线会话的数量.
+func (g *GoJT808) SessionCount() int {
+	return g.sessionManager.count()
+}
+
+// Disconnect 平台主动断开key的连接 触发OnLeaveEvent.
+func (g *GoJT808) Disconnect(key string) error {
+	return g.sessionManager.disconnect(key)
+}
+
+func (g *GoJT808) serve(c net.Conn) {
+	if _, banned := g.banned(remoteHost(c.RemoteAddr())); banned {
+		// 加入之前被限流禁止接入的ip
+		_ = c.Close()
+		return
+	}
+	handles := g.createDefaultHandle()
+	customHandles := g.opts.CustomHandleFunc()
+	for k, v := range customHandles {
+		handles[k] = v
+	}
+	terminalEvent := g.opts.CustomTerminalEventerFunc()
+	var conn *connection
+	conn = newConnection(c, g.opts, handles, terminalEvent, connectionHooks{
+		join:  g.join,
+		ready: g.onTerminalReady,
+		leave: func(key string) {
+			g.sessionManager.leave(key, conn)
+			g.mu.Lock()
+			delete(g.conns, conn)
+			g.mu.Unlock()
+		},
+		rateLimit:     g.onRateLimit,
+		loadVersion:   g.versions.load,
+		storeVersion:  g.versions.store,
+		upgradeNotice: g.onUpgradeNotice,
+	}, g.metrics)
+
+	g.mu.Lock()
+	if g.closing {
+		g.mu.Unlock()
+		_ = c.Close()
+		return
+	}
+	if limit := g.opts.MaxConnections; limit > 0 && len(g.conns) >= limit {
+		g.mu.Unlock()
+		_ = c.Close()
+		g.onRateLimit("", RateLimitConnectionLimit)
+		if v, ok := terminalEvent.(RateLimitEventer); ok {
+			v.OnRateLimitEvent("", RateLimitConnectionLimit)
+		}
+		return
+	}
+	g.conns[conn] = struct{}{}
+	g.mu.Unlock()
+	conn.Start()
+}
+
+// trackListener 记录监听 用于Shutdown的时候关闭 ctx结束的时候直接关闭服务.
+func (g *GoJT808) trackListener(ctx context.Context, in io.Closer) (func() bool, bool) {
+	g.mu.Lock()
+	if g.closing {
+		g.mu.Unlock()
+		_ = in.Close()
+		return nil, false
+	}
+	g.listener = in
+	g.mu.Unlock()
+	stop := context.AfterFunc(ctx, func() {
+		_ = g.Shutdown(ctx)
+	})
+	return stop, true
+}
+
+func (g *GoJT808) shuttingDown() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closing
+}
+
+func (g *GoJT808) waitActiveMessages(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.activeWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (g *GoJT808) createDefaultHandle() map[consts.JT808CommandType]Handler {
 	return map[consts.JT808CommandType]Handler{
 		// 终端上传的
-		consts.T0001GeneralRespond:            newDefaultHandle(&model.T0x0001{}),
-		consts.T0100Register:                  newDefaultHandle(&model.T0x0100{}),
-		consts.T0102RegisterAuth:              newDefaultHandle(&model.T0x0102{}),
-		consts.T0002HeartBeat:                 newDefaultHandle(&model.T0x0002{}),
-		consts.T0200LocationReport:            newDefaultHandle(&model.T0x0200{}),
-		consts.T0201QueryLocation:             newDefaultHandle(&model.T0x0201{}),
-		consts.T0302QuestionAnswer:            newDefaultHandle(&model.T0x0302{}),
-		consts.T0704LocationBatchUpload:       newDefaultHandle(&model.T0x0704{}),
-		consts.T0104QueryParameter:            newDefaultHandle(&model.T0x0104{}),
-		consts.T0805CameraShootImmediately:    newDefaultHandle(&model.T0x0805{}),
-		consts.T0800MultimediaEventInfoUpload: newDefaultHandle(&model.T0x0800{}),
-		consts.T0801MultimediaDataUpload:      newDefaultHandle(&model.T0x0801{}),
+		consts.T0001GeneralRespond:               newDefaultHandle(&model.T0x0001{}),
+		consts.T0100Register:                     newDefaultHandle(&model.T0x0100{}),
+		consts.T0102RegisterAuth:                 newDefaultHandle(&model.T0x0102{}),
+		consts.T0002HeartBeat:                    newDefaultHandle(&model.T0x0002{}),
+		consts.T0005ReissueSubcontractingRequest: newDefaultHandle(&model.T0x0005{}),
+		consts.T0200LocationReport:               newDefaultHandle(&model.T0x0200{}),
+		consts.T0201QueryLocation:                newDefaultHandle(&model.T0x0201{}),
+		consts.T0302QuestionAnswer:               newDefaultHandle(&model.T0x0302{}),
+		consts.T0608QueryRegionRespond:           newDefaultHandle(&model.T0x0608{}),
+		consts.T0704LocationBatchUpload:          newDefaultHandle(&model.T0x0704{}),
+		consts.T0104QueryParameter:               newDefaultHandle(&model.T0x0104{}),
+		consts.T0107QueryAttribute:               newDefaultHandle(&model.T0x0107{}),
+		consts.T0108UpgradeNotice:                newDefaultHandle(&model.T0x0108{}),
+		consts.T0805CameraShootImmediately:       newDefaultHandle(&model.T0x0805{}),
+		consts.T0800MultimediaEventInfoUpload:    newDefaultHandle(&model.T0x0800{}),
+		consts.T0801MultimediaDataUpload:         newDefaultHandle(&model.T0x0801{}),
+		consts.T0A00TerminalRSAPublicKey:         newDefaultHandle(&model.T0x0A00{}),
 
 		// 平台下发的
-		consts.P8003ReissueSubcontractingRequest: newDefaultHandle(&model.P0x8003{}),
-		consts.P8103SetTerminalParams:            newDefaultHandle(&model.P0x8103{}),
-		consts.P8104QueryTerminalParams:          newDefaultHandle(&model.P0x8104{}),
-		consts.P8201QueryLocation:                newDefaultHandle(&model.P0x8201{}),
-		consts.P8202TmpLocationTrack:             newDefaultHandle(&model.P0x8202{}),
-		consts.P8300TextInfoDistribution:         newDefaultHandle(&model.P0x8300{}),
-		consts.P8302QuestionDistribution:         newDefaultHandle(&model.P0x8302{}),
-		consts.P8801CameraShootImmediateCommand:  newDefaultHandle(&model.P0x8801{}),
+		consts.P8003ReissueSubcontractingRequest:     newDefaultHandle(&model.P0x8003{}),
+		consts.P8103SetTerminalParams:                newDefaultHandle(&model.P0x8103{}),
+		consts.P8104QueryTerminalParams:              newDefaultHandle(&model.P0x8104{}),
+		consts.P8105TerminalControl:                  newDefaultHandle(&model.P0x8105{}),
+		consts.P8106QuerySpecifyParam:                newDefaultHandle(&model.P0x8106{}),
+		consts.P8107QueryTerminalProperties:          newDefaultHandle(&model.P0x8107{}),
+		consts.P8108DistributeTerminalUpgradePackage: newDefaultHandle(&model.P0x8108{}),
+		consts.P8201QueryLocation:                    newDefaultHandle(&model.P0x8201{}),
+		consts.P8202TmpLocationTrack:                 newDefaultHandle(&model.P0x8202{}),
+		consts.P8300TextInfoDistribution:             newDefaultHandle(&model.P0x8300{}),
+		consts.P8302QuestionDistribution:             newDefaultHandle(&model.P0x8302{}),
+		consts.P8600SetCircularArea:                  newDefaultHandle(&model.P0x8600{}),
+		consts.P8601DeleteArea:                       newDefaultHandle(&model.P0x8601{}),
+		consts.P8602SetRectArea:                      newDefaultHandle(&model.P0x8602{}),
+		consts.P8603DeleteRectArea:                   newDefaultHandle(&model.P0x8603{}),
+		consts.P8604PolygonArea:                      newDefaultHandle(&model.P0x8604{}),
+		consts.P8605DeletePolygonArea:                newDefaultHandle(&model.P0x8605{}),
+		consts.P8606SetRoute:                         newDefaultHandle(&model.P0x8606{}),
+		consts.P8607DeleteRoute:                      newDefaultHandle(&model.P0x8607{}),
+		consts.P8608QueryAreaOrRouteData:             newDefaultHandle(&model.P0x8608{}),
+		consts.P8801CameraShootImmediateCommand:      newDefaultHandle(&model.P0x8801{}),
+		consts.P8A00PlatformRSAPublicKey:             newDefaultHandle(&model.P0x8A00{}),
 
 		// JT1078相关的
 		consts.P9003QueryTerminalAudioVideoProperties: newDefaultHandle(&model.P0x9003{}),
@@ -0,0 +1,857 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/protocol"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 心跳 手机号14419999999.
+const _heartBeatMsg = "7e000200000144199999990007c07e"
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	return l.Addr().String()
+}
+
+type lifecycleTerminal struct {
+	defaultTerminalEvent
+	mu        sync.Mutex
+	join      chan string
+	leave     []string
+	reasons   []LeaveReason
+	conflicts []SessionPolicy
+	actions   []RateLimitAction
+}
+
+func (l *lifecycleTerminal) OnJoinEvent(_ *Message, key string, err error) {
+	if err == nil {
+		l.join <- key
+	}
+}
+
+func (l *lifecycleTerminal) OnLeaveEvent(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.leave = append(l.leave, key)
+}
+
+func (l *lifecycleTerminal) OnLeaveReasonEvent(_ string, reason LeaveReason) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reasons = append(l.reasons, reason)
+}
+
+func (l *lifecycleTerminal) OnSessionConflictEvent(_ string, policy SessionPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conflicts = append(l.conflicts, policy)
+}
+
+func (l *lifecycleTerminal) OnRateLimitEvent(_ string, action RateLimitAction) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.actions = append(l.actions, action)
+}
+
+func (l *lifecycleTerminal) waitLeaveReason(t *testing.T, timeout time.Duration) LeaveReason {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		if len(l.reasons) > 0 {
+			reason := l.reasons[0]
+			l.mu.Unlock()
+			return reason
+		}
+		l.mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("wait leave timeout")
+	return 0
+}
+
+func dialAndSend(t *testing.T, addr string, msg string) net.Conn {
+	t.Helper()
+	var (
+		conn net.Conn
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := hex.DecodeString(msg)
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestGoJT808StartShutdown(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- goJt808.Start(context.Background())
+	}()
+
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	select {
+	case key := <-event.join:
+		if key != "14419999999" {
+			t.Fatalf("join key=[%s]", key)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("join timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := goJt808.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if err := <-errChan; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Start() error = %v, want %v", err, ErrServerClosed)
+	}
+	event.mu.Lock()
+	if len(event.leave) != 1 || event.leave[0] != "14419999999" {
+		t.Errorf("leave = %v", event.leave)
+	}
+	if len(event.reasons) != 1 || event.reasons[0] != LeaveReasonShutdown {
+		t.Errorf("leave reason = %v", event.reasons)
+	}
+	event.mu.Unlock()
+
+	activeMsg := NewActiveMessage("14419999999", consts.P8104QueryTerminalParams, nil, time.Second)
+	if msg := goJt808.SendActiveMessage(activeMsg); !errors.Is(msg.ExtensionFields.Err, ErrServerClosed) {
+		t.Errorf("SendActiveMessage() error = %v, want %v", msg.ExtensionFields.Err, ErrServerClosed)
+	}
+	if err := goJt808.Start(context.Background()); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Start() after shutdown error = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestGoJT808StartContextCancel(t *testing.T) {
+	addr := freeAddr(t)
+	goJt808 := New(WithHostPorts(addr))
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- goJt808.Start(ctx)
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	cancel()
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Fatalf("Start() error = %v, want %v", err, ErrServerClosed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start() not return")
+	}
+}
+
+func TestGoJT808StartListenFail(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	goJt808 := New(WithHostPorts(l.Addr().String()))
+	if err := goJt808.Start(context.Background()); err == nil || errors.Is(err, ErrServerClosed) {
+		t.Errorf("Start() error = %v, want listen error", err)
+	}
+	_ = goJt808.Shutdown(context.Background())
+}
+
+func TestGoJT808UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithNetwork("udp"),
+		WithUDPSessionTimeout(200*time.Millisecond),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	reply := make([]byte, 1024)
+	var n int
+	for i := 0; i < 50; i++ {
+		_, _ = conn.Write(data)
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if n, err = conn.Read(reply); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("read reply error = %v", err)
+	}
+	// 平台通用应答 回复心跳
+	if got := hex.EncodeToString(reply[:n]); got[2:6] != "8001" {
+		t.Errorf("reply = %s", got)
+	}
+	if key := <-event.join; key != "14419999999" {
+		t.Errorf("join key=[%s]", key)
+	}
+
+	// 长时间没有数据 会话过期
+	if reason := event.waitLeaveReason(t, 3*time.Second); reason != LeaveReasonIdleTimeout {
+		t.Errorf("leave reason = %s", reason)
+	}
+}
+
+func TestGoJT808UDPLastAddress(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	_ = pc.Close()
+
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithNetwork("udp"),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	dial := func() net.Conn {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	// 服务启动前发送的会丢失 重复发送直到收到回复
+	first := dial()
+	defer func() {
+		_ = first.Close()
+	}()
+	for i := 0; i < 50; i++ {
+		_, _ = first.Write(data)
+		_ = first.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if _, err = first.Read(make([]byte, 1024)); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("read reply error = %v", err)
+	}
+
+	// 同一个终端换了地址 平台下发的发往最后的地址
+	second := dial()
+	defer func() {
+		_ = second.Close()
+	}()
+	if _, err := second.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	_ = readFrames(t, second, 1)
+	if sessions := goJt808.Sessions(); len(sessions) != 1 {
+		t.Fatalf("Sessions() = %d", len(sessions))
+	}
+	_ = goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage("14419999999", consts.P8201QueryLocation, nil, time.Second))
+	if jtMsg := readJTMessage(t, second); jtMsg.Header.ID != uint16(consts.P8201QueryLocation) {
+		t.Errorf("second reply id = %04x", jtMsg.Header.ID)
+	}
+	_ = first.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := first.Read(make([]byte, 1024)); err == nil {
+		t.Errorf("first read %d bytes want timeout", n)
+	}
+}
+
+func Test_udpSessions(t *testing.T) {
+	heartbeat, _ := hex.DecodeString(_heartBeatMsg)
+	remote := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10000}
+	sessions := newUDPSessions(nil, slog.Default())
+	// 第一个数据报是半包 先缓存 不使用地址创建会话
+	if u, _, _ := sessions.load(heartbeat[:5], remote); u != nil {
+		t.Fatalf("load() partial = %s", u.key)
+	}
+	u, data, created := sessions.load(heartbeat[5:], remote)
+	if u == nil || !created || u.key != "14419999999" || !bytes.Equal(data, heartbeat) {
+		t.Fatalf("load() = %v %x %t", u, data, created)
+	}
+	// 上一个报文剩余的部分 发往同一个会话
+	v, _, created := sessions.load(append(bytes.Clone(heartbeat), heartbeat[:5]...), remote)
+	if v != u || created {
+		t.Fatalf("load() full = %v %t", v, created)
+	}
+	if v, _, _ = sessions.load(heartbeat[5:], remote); v != u {
+		t.Fatalf("load() rest = %v", v)
+	}
+	// 终端的地址变化 还是同一个会话 只保留最后的地址
+	other := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10001}
+	if v, _, created = sessions.load(heartbeat, other); v != u || created {
+		t.Fatalf("load() other = %v %t", v, created)
+	}
+	sessions.mu.Lock()
+	if len(sessions.routes) != 1 || sessions.routes[other.String()] != u || u.route != other.String() {
+		t.Errorf("routes = %v route = %s", sessions.routes, u.route)
+	}
+	sessions.mu.Unlock()
+	_ = u.Close()
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	if len(sessions.record) != 0 || len(sessions.routes) != 0 || len(sessions.pending) != 0 {
+		t.Errorf("record = %d routes = %d pending = %d", len(sessions.record), len(sessions.routes), len(sessions.pending))
+	}
+}
+
+func TestGoJT808IdleTimeout(t *testing.T) {
+	heartbeat, _ := hex.DecodeString(_heartBeatMsg)
+	jtMsg := jt808.NewJTMessage()
+	_ = jtMsg.Decode(heartbeat)
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0104QueryParameter)
+	// 应答流水号0 参数个数1 参数0x0001心跳间隔1秒
+	body, _ := hex.DecodeString("000001000000010400000001")
+	t0x0104 := hex.EncodeToString(header.Encode(body))
+
+	tests := []struct {
+		name string
+		msg  string
+		opts []Option
+	}{
+		{
+			name: "空闲超时",
+			msg:  _heartBeatMsg,
+			opts: []Option{WithIdleTimeout(200 * time.Millisecond)},
+		},
+		{
+			name: "根据心跳间隔超时",
+			msg:  t0x0104,
+			opts: []Option{WithIdleTimeout(time.Hour), WithHeartbeatMultiple(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			event := &lifecycleTerminal{join: make(chan string, 1)}
+			opts := append([]Option{
+				WithHostPorts(addr),
+				WithCustomTerminalEventer(func() TerminalEventer {
+					return event
+				}),
+			}, tt.opts...)
+			goJt808 := New(opts...)
+			go func() {
+				_ = goJt808.Start(context.Background())
+			}()
+			defer func() {
+				_ = goJt808.Shutdown(context.Background())
+			}()
+			conn := dialAndSend(t, addr, tt.msg)
+			defer func() {
+				_ = conn.Close()
+			}()
+			if reason := event.waitLeaveReason(t, 3*time.Second); reason != LeaveReasonIdleTimeout {
+				t.Errorf("leave reason = %s", reason)
+			}
+		})
+	}
+}
+
+func TestGoJT808SessionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SessionPolicy
+		// closed 哪个连接被断开 0-旧连接 1-新连接 -1-都不断开
+		closed int
+		reason LeaveReason
+	}{
+		{name: "拒绝新连接", policy: SessionPolicyRejectNew, closed: 1, reason: LeaveReasonKeyExist},
+		{name: "替换旧连接", policy: SessionPolicyReplaceOld, closed: 0, reason: LeaveReasonReplaced},
+		{name: "允许多个连接", policy: SessionPolicyAllowMultiple, closed: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := freeAddr(t)
+			event := &lifecycleTerminal{join: make(chan string, 2)}
+			goJt808 := New(
+				WithHostPorts(addr),
+				WithSessionPolicy(tt.policy),
+				WithCustomTerminalEventer(func() TerminalEventer {
+					return event
+				}),
+			)
+			go func() {
+				_ = goJt808.Start(context.Background())
+			}()
+			defer func() {
+				_ = goJt808.Shutdown(context.Background())
+			}()
+
+			conns := make([]net.Conn, 0, 2)
+			for i := 0; i < 2; i++ {
+				conn := dialAndSend(t, addr, _heartBeatMsg)
+				defer func() {
+					_ = conn.Close()
+				}()
+				conns = append(conns, conn)
+				if i == 0 {
+					<-event.join
+				}
+			}
+			for i, conn := range conns {
+				// 先读取心跳的回复 再判断连接是否断开
+				_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+				_, _ = conn.Read(make([]byte, 1024))
+				_, err := conn.Read(make([]byte, 1024))
+				if closed := errors.Is(err, io.EOF); closed != (i == tt.closed) {
+					t.Errorf("conn[%d] closed=%v err=%v", i, closed, err)
+				}
+			}
+			if tt.closed >= 0 {
+				if reason := event.waitLeaveReason(t, 3*time.Second); reason != tt.reason {
+					t.Errorf("leave reason = %s, want %s", reason, tt.reason)
+				}
+			}
+			if tt.closed != 1 {
+				// 新连接仍然在线 旧连接离开不影响新连接
+				activeMsg := NewActiveMessage("14419999999", consts.P8104QueryTerminalParams, nil, 100*time.Millisecond)
+				if msg := goJt808.SendActiveMessage(activeMsg); !errors.Is(msg.ExtensionFields.Err, ErrWriteDataOverTime) {
+					t.Errorf("SendActiveMessage() error = %v", msg.ExtensionFields.Err)
+				}
+			}
+			event.mu.Lock()
+			if len(event.conflicts) != 1 || event.conflicts[0] != tt.policy {
+				t.Errorf("conflicts = %v", event.conflicts)
+			}
+			event.mu.Unlock()
+		})
+	}
+}
+
+func TestGoJT808Sessions(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	// 等待心跳的回复
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Read(make([]byte, 1024))
+
+	if sum := goJt808.SessionCount(); sum != 1 {
+		t.Errorf("SessionCount() = %d", sum)
+	}
+	if infos := goJt808.Sessions(); len(infos) != 1 || infos[0].Key != key {
+		t.Errorf("Sessions() = %v", infos)
+	}
+	info, ok := goJt808.Session(key)
+	if !ok {
+		t.Fatalf("Session(%s) not found", key)
+	}
+	if info.RemoteAddr != conn.LocalAddr().String() {
+		t.Errorf("RemoteAddr = %s, want %s", info.RemoteAddr, conn.LocalAddr())
+	}
+	if info.BytesIn != uint64(len(_heartBeatMsg)/2) || info.MessagesIn != 1 || info.MessagesOut != 1 || info.BytesOut == 0 {
+		t.Errorf("stats in=[%d %d] out=[%d %d]", info.BytesIn, info.MessagesIn, info.BytesOut, info.MessagesOut)
+	}
+	if info.JoinTime.IsZero() || info.LastActiveTime.IsZero() || info.ProtocolVersion != consts.JT808Protocol2013 {
+		t.Errorf("info = %+v", info)
+	}
+	if _, ok := goJt808.Session("1"); ok {
+		t.Error("Session(1) found")
+	}
+
+	// 下发的时候writer会修改请求头 会话信息返回的是加入时的
+	replyChans := make([]<-chan *Message, 0, 10)
+	for i := 0; i < 10; i++ {
+		replyChans = append(replyChans, goJt808.SendActiveMessageAsync(context.Background(),
+			NewActiveMessage(key, consts.P8201QueryLocation, nil, 100*time.Millisecond)))
+		if info, _ := goJt808.Session(key); info.Header.SerialNumber != 7 || info.Header.PlatformSerialNumber != 0 {
+			t.Errorf("Header = %+v", info.Header)
+		}
+	}
+	for _, ch := range replyChans {
+		<-ch
+	}
+
+	if err := goJt808.Disconnect(key); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	if reason := event.waitLeaveReason(t, 3*time.Second); reason != LeaveReasonKicked {
+		t.Errorf("leave reason = %s", reason)
+	}
+	if err := goJt808.Disconnect(key); !errors.Is(err, ErrNotExistKey) {
+		t.Errorf("Disconnect() error = %v, want %v", err, ErrNotExistKey)
+	}
+}
+
+func TestGoJT808SendActiveMessageContext(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	_ = readJTMessage(t, conn)
+
+	// 终端应答
+	replyChan := goJt808.SendActiveMessageAsync(context.Background(),
+		NewActiveMessage(key, consts.P8104QueryTerminalParams, nil, time.Second))
+	p8104 := readJTMessage(t, conn)
+	header := p8104.Header
+	header.ReplyID = uint16(consts.T0104QueryParameter)
+	header.PlatformSerialNumber = 1
+	body := []byte{byte(p8104.Header.SerialNumber >> 8), byte(p8104.Header.SerialNumber), 0}
+	if _, err := conn.Write(header.Encode(body)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-replyChan; msg.ExtensionFields.Err != nil || msg.Command != consts.T0104QueryParameter {
+		t.Errorf("SendActiveMessageAsync() command=[%s] err=[%v]", msg.Command, msg.ExtensionFields.Err)
+	}
+
+	// ctx结束 不等待超时
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	activeMsg := NewActiveMessage(key, consts.P8104QueryTerminalParams, nil, -1)
+	if msg := goJt808.SendActiveMessageContext(ctx, activeMsg); !errors.Is(msg.ExtensionFields.Err, context.DeadlineExceeded) {
+		t.Errorf("SendActiveMessageContext() error = %v", msg.ExtensionFields.Err)
+	}
+
+	// 大量下发 等待中的不占用协程
+	goroutines := runtime.NumGoroutine()
+	replyChans := make([]<-chan *Message, 0, 100)
+	for i := 0; i < 100; i++ {
+		replyChans = append(replyChans, goJt808.SendActiveMessageAsync(context.Background(),
+			NewActiveMessage(key, consts.P8201QueryLocation, nil, 300*time.Millisecond)))
+	}
+	if sum := runtime.NumGoroutine() - goroutines; sum > 10 {
+		t.Errorf("goroutines increase %d", sum)
+	}
+	for _, ch := range replyChans {
+		if msg := <-ch; !errors.Is(msg.ExtensionFields.Err, ErrWriteDataOverTime) {
+			t.Errorf("SendActiveMessageAsync() error = %v", msg.ExtensionFields.Err)
+		}
+	}
+}
+
+func TestGoJT808RSA(t *testing.T) {
+	platformKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminalKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过1024位的密钥不启动 不会不加密就运行
+	largeKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largeJt808 := New(WithHostPorts(freeAddr(t)), WithRSA(largeKey))
+	if err := largeJt808.Start(context.Background()); !errors.Is(err, protocol.ErrRSAKeySize) {
+		t.Errorf("Start() 2048 bits key err = %v", err)
+	}
+	_ = largeJt808.Shutdown(context.Background())
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	bodyChan := make(chan []byte, 1)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithRSA(platformKey),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+		WithInboundInterceptor(func(msg *Message, next InboundHandler) error {
+			if msg.Command == consts.T0001GeneralRespond {
+				bodyChan <- msg.JTMessage.Body
+			}
+			return next(msg)
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _heartBeatMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := <-event.join
+	header := readJTMessage(t, conn).Header
+
+	// 终端上传公钥 平台的应答使用终端的公钥加密
+	p8a00 := &model.P0x8A00{}
+	if err := p8a00.SetPublicKey(&terminalKey.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	header.ReplyID = uint16(consts.T0A00TerminalRSAPublicKey)
+	header.PlatformSerialNumber = 1
+	if _, err := conn.Write(header.Encode(p8a00.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	terminalCipher := jt808.NewRSACipher(terminalKey)
+	terminalCipher.SetPublicKey(&platformKey.PublicKey)
+	data := readFrames(t, conn, 1)[0]
+	jtMsg := jt808.NewJTMessage()
+	jtMsg.Header.Cipher = terminalCipher
+	if err := jtMsg.Decode(data); err != nil || jtMsg.Header.Property.EncryptMethod != 1 {
+		t.Fatalf("Decode() err = %v encrypt = %d", err, jtMsg.Header.Property.EncryptMethod)
+	}
+	p8001 := &model.P0x8001{}
+	if err := p8001.Parse(jtMsg); err != nil || p8001.RespondID != uint16(consts.T0A00TerminalRSAPublicKey) {
+		t.Fatalf("P0x8001 = %+v err = %v", p8001, err)
+	}
+	if info, ok := goJt808.Session(key); !ok || !info.RSAPublicKey.Equal(&terminalKey.PublicKey) {
+		t.Errorf("Session() RSAPublicKey = %v", info.RSAPublicKey)
+	}
+
+	// 终端使用平台的公钥加密 平台解密后分发
+	t0x0001 := &model.T0x0001{SerialNumber: 1, ID: 0x8300, Result: 0}
+	header.ReplyID = uint16(consts.T0001GeneralRespond)
+	header.PlatformSerialNumber = 2
+	header.Property.EncryptMethod = 1
+	header.Cipher = terminalCipher
+	encrypted, err := header.Encrypt(t0x0001.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(header.Encode(encrypted)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-bodyChan:
+		if !bytes.Equal(body, t0x0001.Encode()) {
+			t.Errorf("body = %x, want %x", body, t0x0001.Encode())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait 0x0001 timeout")
+	}
+}
+
+func TestGoJT808ProtocolVersion2011(t *testing.T) {
+	const (
+		register2011 = "7e010000200123456789010000001f007363640000007777772e3830382e3736353433323101b2e24131323334a17e"
+		auth         = "7e0102000c0123456789010001303132333435363738393031867e"
+	)
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	versionChan := make(chan consts.ProtocolVersionType, 1)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+		WithInboundInterceptor(func(msg *Message, next InboundHandler) error {
+			if msg.Command == consts.T0102RegisterAuth {
+				versionChan <- msg.ProtocolVersion
+			}
+			return next(msg)
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	register := dialAndSend(t, addr, register2011)
+	key := <-event.join
+	_ = readJTMessage(t, register)
+	data, _ := hex.DecodeString(auth)
+	if _, err := register.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-versionChan; v != consts.JT808Protocol2011 {
+		t.Errorf("ProtocolVersion = %s, want %s", v, consts.JT808Protocol2011)
+	}
+	if info, ok := goJt808.Session(key); !ok || info.ProtocolVersion != consts.JT808Protocol2011 {
+		t.Errorf("Session() ProtocolVersion = %s", info.ProtocolVersion)
+	}
+	_ = register.Close()
+	_ = event.waitLeaveReason(t, 3*time.Second)
+
+	// 重连后只鉴权 也是2011版本
+	conn := dialAndSend(t, addr, auth)
+	defer func() {
+		_ = conn.Close()
+	}()
+	if v := <-versionChan; v != consts.JT808Protocol2011 {
+		t.Errorf("reconnect ProtocolVersion = %s, want %s", v, consts.JT808Protocol2011)
+	}
+	_ = readJTMessage(t, conn)
+
+	// 2011版本的多媒体数据上传 没有位置基本信息
+	data, _ = hex.DecodeString("7e0801000d01234567890100010000007b010201020d7b0d7b7b8d7e")
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	// 回复多媒体数据ID 没有重传的包
+	if reply := readJTMessage(t, conn); reply.Header.ID != uint16(consts.P8800MultimediaUploadRespond) ||
+		!bytes.HasPrefix(reply.Body, []byte{0, 0, 0, 0x7b}) {
+		t.Errorf("reply id=[%04x] body=[%x]", reply.Header.ID, reply.Body)
+	}
+}
+
+func Test_protocolVersions(t *testing.T) {
+	versions := newProtocolVersions(2)
+	versions.store("1001", consts.JT808Protocol2011)
+	versions.store("1002", consts.JT808Protocol2013)
+	// 已经存在的更新 不影响顺序
+	versions.store("1001", consts.JT808Protocol2013)
+	// 超过数量删除最早的
+	versions.store("1003", consts.JT808Protocol2011)
+	if _, ok := versions.load("1001"); ok {
+		t.Error("load(1001) want evicted")
+	}
+	if v, ok := versions.load("1003"); !ok || v != consts.JT808Protocol2011 {
+		t.Errorf("load(1003) = %s %t", v, ok)
+	}
+	if len(versions.record) != 2 || len(versions.order) != 2 {
+		t.Errorf("record = %d order = %d", len(versions.record), len(versions.order))
+	}
+}
+
+// logWriter 每一行日志发送到chan.
+type logWriter chan string
+
+func (l logWriter) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestGoJT808WithLogger(t *testing.T) {
+	// 未实现的指令0x0f01 手机号14419999999 流水号1
+	const unknownMsg = "7e0f0100000144199999990001ca7e"
+	addr := freeAddr(t)
+	lines := make(logWriter, 100)
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithLogger(slog.New(slog.NewJSONHandler(lines, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+
+	conn := dialAndSend(t, addr, unknownMsg)
+	defer func() {
+		_ = conn.Close()
+	}()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if !strings.Contains(line, "command not supported") {
+				continue
+			}
+			for _, want := range []string{`"key":"14419999999"`, `"phone":"14419999999"`, `"seq":1`} {
+				if !strings.Contains(line, want) {
+					t.Errorf("log [%s] want [%s]", line, want)
+				}
+			}
+			return
+		case <-timeout:
+			t.Fatal("wait log timeout")
+		}
+	}
+}
@@ -1,88 +1,328 @@
 package service
 
 import (
+	"context"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"slices"
 	"time"
 )
 
-type sessionOperationFunc func(record map[string]*session)
+type sessionOperationFunc func(record map[string][]*session)
 
 type (
 	sessionManager struct {
 		operationFuncChan chan sessionOperationFunc
 		keyFunc           func(message *Message) (string, bool)
+		// policy 终端的key已经在线时的处理策略
+		policy SessionPolicy
+		// ctx 结束的时候说明已经停止了
+		ctx      context.Context
+		cancel   context.CancelFunc
+		stopChan <-chan struct{}
 	}
 
 	session struct {
+		// header 下发指令使用 会被连接的writer修改
 		header *jt808.Header
+		// snapshot 加入时请求头的副本 只读 会话信息使用
+		snapshot jt808.Header
 		// 加入时间
 		joinTime time.Time
 		// 数据发送到终端
 		activeMsgChan chan<- *ActiveMessage
+		// conn 会话对应的连接
+		conn *connection
+	}
+
+	// SessionInfo 在线终端的会话信息.
+	SessionInfo struct {
+		// Key 唯一标识符 默认手机号
+		Key string `json:"key"`
+		// RemoteAddr 终端地址
+		RemoteAddr string `json:"remoteAddr"`
+		// JoinTime 加入时间
+		JoinTime time.Time `json:"joinTime"`
+		// LastActiveTime 最后一次收到终端数据的时间
+		LastActiveTime time.Time `json:"lastActiveTime"`
+		// ProtocolVersion 协议版本 2011版本是根据终端注册消息(0x0100)判断的
+		ProtocolVersion consts.ProtocolVersionType `json:"protocolVersion"`
+		// Header 加入时的请求头
+		Header jt808.Header `json:"header"`
+		// BytesIn 收到终端的字节数
+		BytesIn uint64 `json:"bytesIn"`
+		// BytesOut 发送给终端的字节数
+		BytesOut uint64 `json:"bytesOut"`
+		// MessagesIn 收到终端的报文数 分包的每个包都算
+		MessagesIn uint64 `json:"messagesIn"`
+		// MessagesOut 发送给终端的报文数
+		MessagesOut uint64 `json:"messagesOut"`
+		// PlatformSerialNumber 平台下一次下发使用的流水号
+		PlatformSerialNumber uint16 `json:"platformSerialNumber"`
+		// RSAPublicKey 终端上传(0x0A00)的RSA公钥 没有上传的为空
+		RSAPublicKey *rsa.PublicKey `json:"-"`
+	}
+
+	// joinResult 加入的结果 conflict说明key已经在线.
+	joinResult struct {
+		conflict bool
+		err      error
 	}
 )
 
-func newSessionManager(keyFunc func(message *Message) (string, bool)) *sessionManager {
+func newSessionManager(keyFunc func(message *Message) (string, bool), policy SessionPolicy) *sessionManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &sessionManager{
 		operationFuncChan: make(chan sessionOperationFunc, 10),
 		keyFunc:           keyFunc,
+		policy:            policy,
+		ctx:               ctx,
+		cancel:            cancel,
+		stopChan:          ctx.Done(),
 	}
 }
 
 func (s *sessionManager) run() {
-	record := make(map[string]*session, 1000)
-	for opFunc := range s.operationFuncChan {
-		opFunc(record)
+	record := make(map[string][]*session, 1000)
+	for {
+		select {
+		case opFunc := <-s.operationFuncChan:
+			opFunc(record)
+		case <-s.stopChan:
+			clear(record)
+			return
+		}
+	}
+}
+
+func (s *sessionManager) stop() {
+	s.cancel()
+}
+
+// execute 提交操作 已经停止的情况返回false.
+func (s *sessionManager) execute(opFunc sessionOperationFunc) bool {
+	select {
+	case s.operationFuncChan <- opFunc:
+		return true
+	case <-s.stopChan:
+		return false
 	}
 }
 
-func (s *sessionManager) join(message *Message, activeChan chan<- *ActiveMessage) (string, error) {
+// join 加入会话 key已经在线的情况按照policy处理 conflict=true.
+func (s *sessionManager) join(message *Message, conn *connection) (string, bool, error) {
 	key, ok := s.keyFunc(message)
 	if !ok {
-		return "", _errKeyInvalid
-	}
-	ch := make(chan error)
-	defer close(ch)
-	s.operationFuncChan <- func(record map[string]*session) {
-		if v, ok := record[key]; ok {
-			ch <- errors.Join(fmt.Errorf("key[%s] join time[%s]",
-				key, v.joinTime.Format(time.RFC3339)), _errKeyExist)
-			return
-		}
-		record[key] = &session{
+		return "", false, _errKeyInvalid
+	}
+	// 在连接的reader中复制 这时候请求头还没有交给writer
+	snapshot := *message.Header
+	if snapshot.Property != nil {
+		property := *snapshot.Property
+		snapshot.Property = &property
+	}
+	ch := make(chan joinResult, 1)
+	if !s.execute(func(record map[string][]*session) {
+		cur := &session{
 			header:        message.Header,
+			snapshot:      snapshot,
 			joinTime:      time.Now(),
-			activeMsgChan: activeChan,
+			activeMsgChan: conn.activeMsgChan,
+			conn:          conn,
 		}
-		ch <- nil
+		olds, ok := record[key]
+		if !ok || len(olds) == 0 {
+			record[key] = []*session{cur}
+			ch <- joinResult{}
+			return
+		}
+		switch s.policy {
+		case SessionPolicyReplaceOld:
+			for _, v := range olds {
+				// 先交接流水号 避免旧连接退出的时候保存
+				v.conn.handOverSerialNumber(conn)
+				// 只断开连接 旧连接的reader退出后再leave
+				v.conn.close(LeaveReasonReplaced)
+			}
+			record[key] = []*session{cur}
+			ch <- joinResult{conflict: true}
+		case SessionPolicyAllowMultiple:
+			record[key] = append(olds, cur)
+			ch <- joinResult{conflict: true}
+		default:
+			ch <- joinResult{conflict: true, err: errors.Join(fmt.Errorf("key[%s] join time[%s]",
+				key, olds[0].joinTime.Format(time.RFC3339)), _errKeyExist)}
+		}
+	}) {
+		return "", false, ErrServerClosed
+	}
+	select {
+	case result := <-ch:
+		return key, result.conflict, result.err
+	case <-s.stopChan:
+		return "", false, ErrServerClosed
 	}
-	return key, <-ch
 }
 
-func (s *sessionManager) leave(key string) {
+// leave 离开会话 只删除conn对应的 key被新连接替换的情况不影响新连接.
+func (s *sessionManager) leave(key string, conn *connection) {
 	ch := make(chan struct{})
-	s.operationFuncChan <- func(record map[string]*session) {
+	if !s.execute(func(record map[string][]*session) {
 		defer close(ch)
-		delete(record, key)
+		sessions := slices.DeleteFunc(record[key], func(v *session) bool {
+			return v.conn == conn
+		})
+		if len(sessions) == 0 {
+			delete(record, key)
+			return
+		}
+		record[key] = sessions
+	}) {
+		return
+	}
+	select {
+	case <-ch:
+	case <-s.stopChan:
 	}
-	<-ch
 }
 
-func (s *sessionManager) write(activeMsg *ActiveMessage) *Message {
-	replyChan := make(chan *Message)
-	defer close(replyChan)
-	s.operationFuncChan <- func(record map[string]*session) {
+// sessions 查询会话 key为空的时候查询全部.
+func (s *sessionManager) sessions(key string) []SessionInfo {
+	ch := make(chan []SessionInfo, 1)
+	if !s.execute(func(record map[string][]*session) {
+		if key != "" {
+			infos := make([]SessionInfo, 0, len(record[key]))
+			for _, v := range record[key] {
+				infos = append(infos, v.info(key))
+			}
+			ch <- infos
+			return
+		}
+		infos := make([]SessionInfo, 0, len(record))
+		for k, sessions := range record {
+			for _, v := range sessions {
+				infos = append(infos, v.info(k))
+			}
+		}
+		ch <- infos
+	}) {
+		return nil
+	}
+	select {
+	case infos := <-ch:
+		return infos
+	case <-s.stopChan:
+		return nil
+	}
+}
+
+// count 在线会话的数量 允许多个连接的情况每个连接都算.
+func (s *sessionManager) count() int {
+	ch := make(chan int, 1)
+	if !s.execute(func(record map[string][]*session) {
+		sum := 0
+		for _, sessions := range record {
+			sum += len(sessions)
+		}
+		ch <- sum
+	}) {
+		return 0
+	}
+	select {
+	case sum := <-ch:
+		return sum
+	case <-s.stopChan:
+		return 0
+	}
+}
+
+// disconnect 断开key的全部连接 连接退出后再leave.
+func (s *sessionManager) disconnect(key string) error {
+	ch := make(chan error, 1)
+	if !s.execute(func(record map[string][]*session) {
+		sessions, ok := record[key]
+		if !ok || len(sessions) == 0 {
+			ch <- errors.Join(ErrNotExistKey, fmt.Errorf("key=[%s] sum=[%d] ", key, len(record)))
+			return
+		}
+		for _, v := range sessions {
+			v.conn.close(LeaveReasonKicked)
+		}
+		ch <- nil
+	}) {
+		return ErrServerClosed
+	}
+	select {
+	case err := <-ch:
+		return err
+	case <-s.stopChan:
+		return ErrServerClosed
+	}
+}
+
+// write 平台下发指令 callback只会执行一次 ctx结束或者服务停止的情况也会执行.
+func (s *sessionManager) write(ctx context.Context, activeMsg *ActiveMessage, callback func(msg *Message)) {
+	r := newActiveReply(callback)
+	r.afterFunc(ctx, func() {
+		r.canceled.Store(true)
+		r.reply(newErrMessage(ctx.Err()))
+		if conn := r.conn.Load(); conn != nil {
+			conn.cancelActive(activeMsg)
+		}
+	})
+	r.afterFunc(s.ctx, func() {
+		r.reply(newErrMessage(ErrServerClosed))
+	})
+	if !s.execute(func(record map[string][]*session) {
 		key := activeMsg.Key
-		if v, ok := record[key]; ok {
+		if sessions, ok := record[key]; ok && len(sessions) > 0 {
+			// 多个连接的情况 发往最后加入的
+			v := sessions[len(sessions)-1]
 			activeMsg.header = v.header
-			activeMsg.replyChan = replyChan
+			activeMsg.reply = r
+			r.conn.Store(v.conn)
 			v.activeMsgChan <- activeMsg
 			return
 		}
-		replyChan <- newErrMessage(errors.Join(ErrNotExistKey,
-			fmt.Errorf("key=[%s] sum=[%d] ", key, len(record))))
+		r.reply(newErrMessage(errors.Join(ErrNotExistKey,
+			fmt.Errorf("key=[%s] sum=[%d] ", key, len(record)))))
+	}) {
+		r.reply(newErrMessage(ErrServerClosed))
+	}
+}
+
+func (s *session) info(key string) SessionInfo {
+	info := SessionInfo{
+		Key:         key,
+		JoinTime:    s.joinTime,
+		BytesIn:     s.conn.stats.bytesIn.Load(),
+		BytesOut:    s.conn.stats.bytesOut.Load(),
+		MessagesIn:  s.conn.stats.messagesIn.Load(),
+		MessagesOut: s.conn.stats.messagesOut.Load(),
+
+		PlatformSerialNumber: s.conn.curPlatformSerialNumber(),
+	}
+	if s.conn.cipher != nil {
+		info.RSAPublicKey = s.conn.cipher.PublicKey()
+	}
+	if addr := s.conn.conn.RemoteAddr(); addr != nil {
+		info.RemoteAddr = addr.String()
+	}
+	if v := s.conn.stats.lastActiveTime.Load(); v > 0 {
+		info.LastActiveTime = time.Unix(0, v)
+	}
+	info.Header = s.snapshot
+	info.ProtocolVersion = s.snapshot.ProtocolVersion
+	if v := s.conn.protocolVersion.Load(); v != 0 {
+		info.ProtocolVersion = consts.ProtocolVersionType(v)
+	}
+	if s.snapshot.Property != nil {
+		// 返回的也是副本 调用方修改不影响会话
+		property := *s.snapshot.Property
+		info.Header.Property = &property
 	}
-	return <-replyChan
+	return info
 }
@@ -0,0 +1,26 @@
+package service
+
+// SessionPolicy 终端的key已经在线时 新连接加入的处理策略.
+type SessionPolicy uint8
+
+const (
+	// SessionPolicyRejectNew 拒绝新的连接 默认.
+	SessionPolicyRejectNew SessionPolicy = iota
+	// SessionPolicyReplaceOld 断开旧的连接 使用新的连接 适合终端网络切换后重连的情况.
+	SessionPolicyReplaceOld
+	// SessionPolicyAllowMultiple 允许同一个key多个连接 平台下发的指令发往最后加入的连接.
+	SessionPolicyAllowMultiple
+)
+
+func (s SessionPolicy) String() string {
+	switch s {
+	case SessionPolicyRejectNew:
+		return "拒绝新连接"
+	case SessionPolicyReplaceOld:
+		return "替换旧连接"
+	case SessionPolicyAllowMultiple:
+		return "允许多个连接"
+	default:
+	}
+	return "未知的会话策略"
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileSubcontractStore 文件存储的分包 每个分包消息一个目录 每个分包一个文件 服务重启后继续上传.
+type FileSubcontractStore struct {
+	dir string
+}
+
+// NewFileSubcontractStore 分包保存在dir目录下 目录不存在的情况创建.
+func NewFileSubcontractStore(dir string) (*FileSubcontractStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create subcontract dir [%s]: %w", dir, err)
+	}
+	return &FileSubcontractStore{dir: dir}, nil
+}
+
+func (f *FileSubcontractStore) Save(key SubcontractKey, sum uint16, no uint16, body []byte) error {
+	if no == 0 || no > sum {
+		return fmt.Errorf("subcontract [%s] no [%d] sum [%d]", key, no, sum)
+	}
+	dir := f.path(key)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if _, v, ok := parseSubcontractFileName(entry.Name()); ok && v != sum {
+			// 总包数变了 说明是新的消息 之前的分包无效
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("save subcontract [%s]: %w", key, err)
+			}
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("save subcontract [%s]: %w", key, err)
+	}
+	// 先写临时文件再替换 避免写入一半的情况
+	name := filepath.Join(dir, fmt.Sprintf("%d-%d", no, sum))
+	tmp, err := os.CreateTemp(dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("save subcontract [%s]: %w", key, err)
+	}
+	if _, err := tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save subcontract [%s]: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save subcontract [%s]: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("save subcontract [%s]: %w", key, err)
+	}
+	return nil
+}
+
+func (f *FileSubcontractStore) Load(key SubcontractKey) (SubcontractRecord, bool, error) {
+	dir := f.path(key)
+	entries, err := os.ReadDir(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return SubcontractRecord{}, false, nil
+	case err != nil:
+		return SubcontractRecord{}, false, fmt.Errorf("load subcontract [%s]: %w", key, err)
+	}
+	var record SubcontractRecord
+	for _, entry := range entries {
+		no, sum, ok := parseSubcontractFileName(entry.Name())
+		if !ok || no > sum || (record.Sum != 0 && record.Sum != sum) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return SubcontractRecord{}, false, fmt.Errorf("load subcontract [%s]: %w", key, err)
+		}
+		body, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return SubcontractRecord{}, false, fmt.Errorf("load subcontract [%s]: %w", key, err)
+		}
+		if record.Sum == 0 {
+			record.Sum = sum
+			record.Bodies = make([][]byte, sum)
+		}
+		record.Bodies[no-1] = body
+		if modTime := info.ModTime(); modTime.After(record.UpdateTime) {
+			record.UpdateTime = modTime
+		}
+	}
+	if record.Sum == 0 {
+		return SubcontractRecord{}, false, nil
+	}
+	if record.UpdateTime.IsZero() {
+		record.UpdateTime = time.Now()
+	}
+	return record, true, nil
+}
+
+func (f *FileSubcontractStore) Delete(key SubcontractKey) error {
+	if err := os.RemoveAll(f.path(key)); err != nil {
+		return fmt.Errorf("delete subcontract [%s]: %w", key, err)
+	}
+	return nil
+}
+
+func (f *FileSubcontractStore) path(key SubcontractKey) string {
+	return filepath.Join(f.dir, filepath.Base(key.String()))
+}
+
+// parseSubcontractFileName 文件名是 包序号-总包数.
+func parseSubcontractFileName(name string) (uint16, uint16, bool) {
+	noStr, sumStr, ok := strings.Cut(name, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	no, err := strconv.ParseUint(noStr, 10, 16)
+	if err != nil || no == 0 {
+		return 0, 0, false
+	}
+	sum, err := strconv.ParseUint(sumStr, 10, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(no), uint16(sum), true
+}
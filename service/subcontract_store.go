@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSubcontractTimeout 分包多久没有完成就丢弃.
+	defaultSubcontractTimeout = 60 * time.Second
+	// defaultSubcontractReissueInterval 分包多久没有收到新的包就请求补传(0x8003).
+	defaultSubcontractReissueInterval = 5 * time.Second
+)
+
+type (
+	// SubcontractStore 分包的存储 连接断开后保留已经收到的分包 终端重连后继续上传.
+	SubcontractStore interface {
+		// Save 保存收到的一个分包 no是包序号 从1开始.
+		Save(key SubcontractKey, sum uint16, no uint16, body []byte) error
+		// Load 获取已经收到的分包 不存在的情况返回false.
+		Load(key SubcontractKey) (SubcontractRecord, bool, error)
+		// Delete 分包完成或者过期后删除.
+		Delete(key SubcontractKey) error
+	}
+
+	// SubcontractKey 一个分包消息的标识.
+	SubcontractKey struct {
+		// Phone 终端手机号
+		Phone string `json:"phone"`
+		// ID 消息ID
+		ID uint16 `json:"id"`
+		// SerialNumber 第一个分包的流水号
+		SerialNumber uint16 `json:"serialNumber"`
+	}
+
+	// SubcontractRecord 已经收到的分包.
+	SubcontractRecord struct {
+		// Sum 消息总包数
+		Sum uint16
+		// Bodies 分包的消息体 下标是包序号-1 没有收到的为空
+		Bodies [][]byte
+		// UpdateTime 最后一次收到分包的时间
+		UpdateTime time.Time
+	}
+)
+
+func (k SubcontractKey) String() string {
+	return fmt.Sprintf("%s-%04x-%d", k.Phone, k.ID, k.SerialNumber)
+}
+
+// MemorySubcontractStore 内存存储的分包 服务重启后丢失.
+type MemorySubcontractStore struct {
+	mu     sync.Mutex
+	record map[SubcontractKey]*SubcontractRecord
+}
+
+func NewMemorySubcontractStore() *MemorySubcontractStore {
+	return &MemorySubcontractStore{
+		record: make(map[SubcontractKey]*SubcontractRecord),
+	}
+}
+
+func (m *MemorySubcontractStore) Save(key SubcontractKey, sum uint16, no uint16, body []byte) error {
+	if no == 0 || no > sum {
+		return fmt.Errorf("subcontract [%s] no [%d] sum [%d]", key, no, sum)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.record[key]
+	if !ok || v.Sum != sum {
+		v = &SubcontractRecord{
+			Sum:    sum,
+			Bodies: make([][]byte, sum),
+		}
+		m.record[key] = v
+	}
+	v.Bodies[no-1] = slices.Clone(body)
+	v.UpdateTime = time.Now()
+	return nil
+}
+
+func (m *MemorySubcontractStore) Load(key SubcontractKey) (SubcontractRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.record[key]
+	if !ok {
+		return SubcontractRecord{}, false, nil
+	}
+	record := *v
+	record.Bodies = make([][]byte, len(v.Bodies))
+	for i, body := range v.Bodies {
+		record.Bodies[i] = slices.Clone(body)
+	}
+	return record, true, nil
+}
+
+func (m *MemorySubcontractStore) Delete(key SubcontractKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.record, key)
+	return nil
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// udpPendingMaxSize 还没有完整报文的远程地址 最多缓存的字节数
+	udpPendingMaxSize = 64 * 1024
+	// udpPendingTimeout 还没有完整报文的远程地址 多久没有数据就丢弃
+	udpPendingTimeout = 30 * time.Second
+)
+
+// udpConn 把udp的一个终端会话模拟成net.Conn 这样可以复用connection的读写逻辑.
+type udpConn struct {
+	pc net.PacketConn
+	// key 会话标识 终端的手机号
+	key string
+	// route 最后收到完整报文的远程地址 由udpSessions的mu保护
+	route string
+	// mu 保护remote lastActive pending readDeadline
+	mu         sync.Mutex
+	remote     net.Addr
+	lastActive time.Time
+	// pending 上一次读取剩余的数据
+	pending      []byte
+	readDeadline time.Time
+	dataChan     chan []byte
+	// expired 会话过期了 读取返回超时
+	expired   atomic.Bool
+	closeOnce sync.Once
+	closeChan chan struct{}
+	onClose   func(u *udpConn)
+	logger    *slog.Logger
+}
+
+func newUDPConn(pc net.PacketConn, key string, remote net.Addr, logger *slog.Logger, onClose func(u *udpConn)) *udpConn {
+	return &udpConn{
+		pc:         pc,
+		key:        key,
+		remote:     remote,
+		lastActive: time.Now(),
+		dataChan:   make(chan []byte, 16),
+		closeChan:  make(chan struct{}),
+		onClose:    onClose,
+		logger:     logger,
+	}
+}
+
+// deliver 收到终端的数据 记录终端最后的地址 后续回复都发往这个地址.
+func (u *udpConn) deliver(data []byte, remote net.Addr) {
+	u.mu.Lock()
+	u.remote = remote
+	u.lastActive = time.Now()
+	u.mu.Unlock()
+	select {
+	case u.dataChan <- data:
+	case <-u.closeChan:
+	default:
+		u.logger.Warn("udp data discard",
+			slog.String("key", u.key),
+			slog.Any("remote", remote),
+			slog.Int("len", len(data)))
+	}
+}
+
+func (u *udpConn) idle(now time.Time, timeout time.Duration) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return now.Sub(u.lastActive) > timeout
+}
+
+func (u *udpConn) Read(b []byte) (int, error) {
+	u.mu.Lock()
+	if len(u.pending) > 0 {
+		n := copy(b, u.pending)
+		u.pending = u.pending[n:]
+		u.mu.Unlock()
+		return n, nil
+	}
+	deadline := u.readDeadline
+	u.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case data := <-u.dataChan:
+		n := copy(b, data)
+		if n < len(data) {
+			u.mu.Lock()
+			u.pending = data[n:]
+			u.mu.Unlock()
+		}
+		return n, nil
+	case <-u.closeChan:
+		if u.expired.Load() {
+			return 0, os.ErrDeadlineExceeded
+		}
+		return 0, net.ErrClosed
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func (u *udpConn) Write(b []byte) (int, error) {
+	select {
+	case <-u.closeChan:
+		return 0, net.ErrClosed
+	default:
+	}
+	u.mu.Lock()
+	remote := u.remote
+	u.mu.Unlock()
+	return u.pc.WriteTo(b, remote)
+}
+
+// expire 会话过期 关闭后读取返回超时的错误.
+func (u *udpConn) expire() {
+	u.expired.Store(true)
+	_ = u.Close()
+}
+
+func (u *udpConn) Close() error {
+	u.closeOnce.Do(func() {
+		close(u.closeChan)
+		if u.onClose != nil {
+			u.onClose(u)
+		}
+	})
+	return nil
+}
+
+func (u *udpConn) LocalAddr() net.Addr {
+	return u.pc.LocalAddr()
+}
+
+func (u *udpConn) RemoteAddr() net.Addr {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.remote
+}
+
+func (u *udpConn) SetDeadline(t time.Time) error {
+	return u.SetReadDeadline(t)
+}
+
+func (u *udpConn) SetReadDeadline(t time.Time) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline udp写入不会阻塞 忽略.
+func (u *udpConn) SetWriteDeadline(_ time.Time) error {
+	return nil
+}
+
+// udpSessionKey 获取udp会话的标识 使用第一个能解析的完整报文的手机号 没有完整报文的返回false.
+// 数据可能是上一个报文剩余的部分 所以每两个相邻的7e之间都尝试解析.
+func udpSessionKey(data []byte) (string, bool) {
+	const sign = 0x7e
+	start := -1
+	for i, v := range data {
+		if v != sign {
+			continue
+		}
+		if start != -1 && i > start+1 {
+			jtMsg := jt808.NewJTMessage()
+			if err := jtMsg.Decode(data[start : i+1]); err == nil {
+				return jtMsg.Header.TerminalPhoneNo, true
+			}
+		}
+		start = i
+	}
+	return "", false
+}
+
+type (
+	// udpSessions udp的全部会话.
+	udpSessions struct {
+		pc     net.PacketConn
+		mu     sync.Mutex
+		record map[string]*udpConn
+		// routes 远程地址最近对应的会话 不完整的报文发往这个会话
+		routes map[string]*udpConn
+		// pending 还没有对应会话的远程地址 缓存数据直到有完整的报文
+		pending map[string]*udpPending
+		logger  *slog.Logger
+	}
+
+	udpPending struct {
+		data       []byte
+		lastActive time.Time
+	}
+)
+
+func newUDPSessions(pc net.PacketConn, logger *slog.Logger) *udpSessions {
+	return &udpSessions{
+		pc:      pc,
+		logger:  logger,
+		record:  make(map[string]*udpConn),
+		routes:  make(map[string]*udpConn),
+		pending: make(map[string]*udpPending),
+	}
+}
+
+// load 获取数据对应的会话 不存在的情况创建一个 返回需要交给会话的数据.
+// 没有完整报文并且远程地址还没有会话的 先缓存起来 返回的会话为空.
+func (s *udpSessions) load(data []byte, remote net.Addr) (*udpConn, []byte, bool) {
+	addr := remote.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pending[addr]; ok {
+		data = append(p.data, data...)
+		delete(s.pending, addr)
+	}
+	key, ok := udpSessionKey(data)
+	if !ok {
+		if u, ok := s.routes[addr]; ok {
+			return u, data, false
+		}
+		if len(data) > udpPendingMaxSize {
+			s.logger.Warn("udp pending data discard",
+				slog.Any("remote", remote),
+				slog.Int("len", len(data)))
+			return nil, nil, false
+		}
+		s.pending[addr] = &udpPending{data: data, lastActive: time.Now()}
+		return nil, nil, false
+	}
+	u, exist := s.record[key]
+	if !exist {
+		u = newUDPConn(s.pc, key, remote, s.logger, s.remove)
+		s.record[key] = u
+	}
+	if u.route != addr {
+		// 终端的地址变化了(如NAT重新映射) 只保留最后的地址
+		if s.routes[u.route] == u {
+			delete(s.routes, u.route)
+		}
+		u.route = addr
+		s.routes[addr] = u
+	}
+	return u, data, !exist
+}
+
+func (s *udpSessions) remove(u *udpConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.record[u.key]; ok && v == u {
+		delete(s.record, u.key)
+	}
+	if s.routes[u.route] == u {
+		delete(s.routes, u.route)
+	}
+}
+
+// runExpire 定时关闭长时间没有数据的会话 丢弃长时间没有完整报文的缓存.
+func (s *udpSessions) runExpire(timeout time.Duration, stopChan <-chan struct{}) {
+	interval := udpPendingTimeout / 2
+	if timeout > 0 {
+		interval = min(interval, timeout/2)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopChan:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for addr, p := range s.pending {
+				if now.Sub(p.lastActive) > udpPendingTimeout {
+					delete(s.pending, addr)
+				}
+			}
+			if timeout <= 0 {
+				s.mu.Unlock()
+				continue
+			}
+			expired := make([]*udpConn, 0)
+			for _, u := range s.record {
+				if u.idle(now, timeout) {
+					expired = append(expired, u)
+				}
+			}
+			s.mu.Unlock()
+			for _, u := range expired {
+				s.logger.Debug("udp session expire",
+					slog.String("key", u.key),
+					slog.Any("remote", u.RemoteAddr()))
+				u.expire()
+			}
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultUpgradeFragmentSize 升级包每个分包的消息体长度 和jt808自动分包的一致.
+	defaultUpgradeFragmentSize = 1000
+	// maxUpgradeFragmentSize 消息体长度最大为10bit.
+	maxUpgradeFragmentSize         = 1023
+	defaultUpgradeFragmentTimeout  = 10 * time.Second
+	defaultUpgradeRetries          = 3
+	defaultUpgradeReconnectTimeout = 5 * time.Minute
+	defaultUpgradeResultTimeout    = 10 * time.Minute
+)
+
+// UpgradeStage 远程升级任务的阶段.
+type UpgradeStage uint8
+
+const (
+	// UpgradeSending 升级包分包下发中.
+	UpgradeSending UpgradeStage = iota + 1
+	// UpgradeReconnecting 终端离线了 等待终端重新加入后继续下发.
+	UpgradeReconnecting
+	// UpgradeWaitingResult 分包全部应答了 等待终端的升级结果(0x0108).
+	UpgradeWaitingResult
+	// UpgradeSuccess 升级成功.
+	UpgradeSuccess
+	// UpgradeFail 升级失败 失败原因见UpgradeProgress.Err.
+	UpgradeFail
+)
+
+func (u UpgradeStage) String() string {
+	switch u {
+	case UpgradeSending:
+		return "下发中"
+	case UpgradeReconnecting:
+		return "等待终端重新加入"
+	case UpgradeWaitingResult:
+		return "等待升级结果"
+	case UpgradeSuccess:
+		return "升级成功"
+	case UpgradeFail:
+		return "升级失败"
+	default:
+	}
+	return "未知的升级阶段"
+}
+
+type (
+	// UpgradeConfig 远程升级任务的配置 零值使用默认的.
+	UpgradeConfig struct {
+		// FragmentSize 每个分包的消息体长度 默认1000 最大1023
+		FragmentSize int
+		// FragmentTimeout 每个分包等待终端通用应答的时间 默认10秒
+		FragmentTimeout time.Duration
+		// Retries 每个分包超时或者终端应答失败后重新下发的次数 默认3次 小于0不重新下发
+		Retries int
+		// ReconnectTimeout 终端离线后等待重新加入的时间 默认5分钟 超过后任务失败
+		ReconnectTimeout time.Duration
+		// ResultTimeout 分包全部应答后等待终端升级结果(0x0108)的时间 默认10分钟 小于0不等待
+		ResultTimeout time.Duration
+		// OnProgress 进度变化的回调 在任务的协程中执行
+		OnProgress func(progress UpgradeProgress)
+	}
+
+	// UpgradeProgress 远程升级任务的进度.
+	UpgradeProgress struct {
+		// Key 唯一标识符 默认手机号
+		Key string `json:"key"`
+		// Stage 当前的阶段
+		Stage UpgradeStage `json:"stage"`
+		// Acked 终端已经应答的分包数量
+		Acked int `json:"acked"`
+		// Total 分包的总数
+		Total int `json:"total"`
+		// Retransmissions 超时或者终端应答失败后重新下发的次数
+		Retransmissions int `json:"retransmissions"`
+		// Reconnects 终端离线后重新加入继续下发的次数
+		Reconnects int `json:"reconnects"`
+		// Result 终端上传的升级结果 还没有上传的为空
+		Result *model.T0x0108 `json:"result,omitempty"`
+		// Err 失败的原因
+		Err error `json:"err,omitempty"`
+	}
+
+	// UpgradeJob 远程升级任务 升级包(0x8108)的分包逐个下发 每个分包等待终端的通用应答.
+	UpgradeJob struct {
+		g         *GoJT808
+		key       string
+		config    UpgradeConfig
+		fragments [][]byte
+		// group 分包共用的流水号 断线重连后重新预留
+		group *subPackageGroup
+		// notice 终端上传的升级结果 ready 终端重新加入
+		notice chan *model.T0x0108
+		ready  chan struct{}
+		done   chan struct{}
+
+		mu       sync.Mutex
+		progress UpgradeProgress
+	}
+
+	// upgradeJobs 进行中的远程升级任务 一个终端同时只有一个.
+	upgradeJobs struct {
+		mu     sync.Mutex
+		record map[string]*UpgradeJob
+	}
+)
+
+// Upgrade 远程升级 升级包(0x8108)按照分包逐个下发 每个分包等待终端通用应答(0x0001).
+// 超时或者终端应答失败的重新下发 终端请求补传(0x0005)的分包直接重新下发
+// 终端离线的情况等待重新加入 从没有应答的分包继续下发(包序号不变 流水号重新分配)
+// 分包全部应答后等待终端的升级结果(0x0108) ctx结束的情况任务失败.
+func (g *GoJT808) Upgrade(ctx context.Context, key string, p8108 *model.P0x8108, config UpgradeConfig) (*UpgradeJob, error) {
+	config = config.withDefault()
+	body := p8108.Encode()
+	fragments := make([][]byte, 0, len(body)/config.FragmentSize+1)
+	for start := 0; start < len(body); start += config.FragmentSize {
+		fragments = append(fragments, body[start:min(start+config.FragmentSize, len(body))])
+	}
+	if len(fragments) > 0xffff {
+		return nil, errors.Join(ErrUpgradeFail, fmt.Errorf("fragment sum [%d] too large", len(fragments)))
+	}
+	job := &UpgradeJob{
+		g:         g,
+		key:       key,
+		config:    config,
+		fragments: fragments,
+		group:     &subPackageGroup{},
+		notice:    make(chan *model.T0x0108, 1),
+		ready:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		progress: UpgradeProgress{
+			Key:   key,
+			Stage: UpgradeSending,
+			Total: len(fragments),
+		},
+	}
+	if g.shuttingDown() {
+		return nil, ErrServerClosed
+	}
+	if err := g.upgrades.add(job); err != nil {
+		return nil, err
+	}
+	go job.run(ctx)
+	return job, nil
+}
+
+// UpgradeJob 查询终端进行中的远程升级任务.
+func (g *GoJT808) UpgradeJob(key string) (*UpgradeJob, bool) {
+	return g.upgrades.load(key)
+}
+
+// onUpgradeNotice 终端上传的升级结果(0x0108) 交给进行中的升级任务.
+func (g *GoJT808) onUpgradeNotice(key string, notice *model.T0x0108) {
+	if job, ok := g.upgrades.load(key); ok {
+		job.onNotice(notice)
+	}
+}
+
+func (c UpgradeConfig) withDefault() UpgradeConfig {
+	if c.FragmentSize <= 0 {
+		c.FragmentSize = defaultUpgradeFragmentSize
+	}
+	c.FragmentSize = min(c.FragmentSize, maxUpgradeFragmentSize)
+	if c.FragmentTimeout <= 0 {
+		c.FragmentTimeout = defaultUpgradeFragmentTimeout
+	}
+	if c.Retries == 0 {
+		c.Retries = defaultUpgradeRetries
+	}
+	c.Retries = max(c.Retries, 0)
+	if c.ReconnectTimeout <= 0 {
+		c.ReconnectTimeout = defaultUpgradeReconnectTimeout
+	}
+	if c.ResultTimeout == 0 {
+		c.ResultTimeout = defaultUpgradeResultTimeout
+	}
+	return c
+}
+
+// Progress 当前的进度.
+func (j *UpgradeJob) Progress() UpgradeProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+// Done 任务结束(成功或者失败)后关闭.
+func (j *UpgradeJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait 阻塞直到任务结束 返回最终的进度.
+func (j *UpgradeJob) Wait() UpgradeProgress {
+	<-j.done
+	return j.Progress()
+}
+
+func (j *UpgradeJob) run(ctx context.Context) {
+	for next := 0; next < len(j.fragments); {
+		err := j.send(ctx, next)
+		switch {
+		case err == nil:
+			next++
+			j.update(func(p *UpgradeProgress) {
+				p.Acked = next
+			})
+		case errors.Is(err, ErrNotExistKey), errors.Is(err, ErrConnectionClosed), errors.Is(err, ErrWriteDataFail):
+			if err := j.waitReady(ctx); err != nil {
+				j.finish(nil, err)
+				return
+			}
+		default:
+			j.finish(nil, err)
+			return
+		}
+	}
+	if j.config.ResultTimeout < 0 {
+		j.finish(nil, nil)
+		return
+	}
+	j.update(func(p *UpgradeProgress) {
+		p.Stage = UpgradeWaitingResult
+	})
+	j.waitResult(ctx)
+}
+
+// send 下发第index个分包 超时或者终端应答失败的重新下发.
+func (j *UpgradeJob) send(ctx context.Context, index int) error {
+	for retry := 0; ; retry++ {
+		activeMsg := NewActiveMessage(j.key, consts.P8108DistributeTerminalUpgradePackage,
+			j.fragments[index], j.config.FragmentTimeout)
+		activeMsg.subPackage = &activeSubPackage{
+			group: j.group,
+			no:    uint16(index + 1),
+			sum:   uint16(len(j.fragments)),
+		}
+		msg := <-j.g.sendActiveMessage(ctx, activeMsg, false)
+		err := msg.ExtensionFields.Err
+		if err == nil {
+			// 应答解析失败或者不是这个分包的 都当作失败重新下发
+			t0x0001 := &model.T0x0001{}
+			if parseErr := t0x0001.Parse(msg.JTMessage); parseErr != nil {
+				err = fmt.Errorf("fragment no=[%d] %w", index+1, parseErr)
+			} else if t0x0001.SerialNumber != msg.ExtensionFields.PlatformSeq {
+				err = fmt.Errorf("fragment no=[%d] reply seq=[%d] want=[%d]",
+					index+1, t0x0001.SerialNumber, msg.ExtensionFields.PlatformSeq)
+			} else if t0x0001.Result == 0 {
+				return nil
+			} else if t0x0001.Result == 3 {
+				return errors.Join(ErrUpgradeFail, fmt.Errorf("fragment no=[%d] not supported", index+1))
+			} else {
+				err = fmt.Errorf("fragment no=[%d] result=[%d]", index+1, t0x0001.Result)
+			}
+		} else if !errors.Is(err, ErrWriteDataOverTime) {
+			return err
+		}
+		if retry >= j.config.Retries {
+			return errors.Join(ErrUpgradeFail, fmt.Errorf("retries [%d]", retry), err)
+		}
+		j.g.opts.Logger.Debug("upgrade retransmission",
+			slog.String("key", j.key),
+			slog.Int("no", index+1),
+			slog.Int("retry", retry+1),
+			slog.Any("err", err))
+		j.update(func(p *UpgradeProgress) {
+			p.Retransmissions++
+		})
+	}
+}
+
+// waitReady 终端离线了 等待终端重新加入.
+func (j *UpgradeJob) waitReady(ctx context.Context) error {
+	j.update(func(p *UpgradeProgress) {
+		p.Stage = UpgradeReconnecting
+	})
+	timer := time.NewTimer(j.config.ReconnectTimeout)
+	defer timer.Stop()
+	select {
+	case <-j.ready:
+	case <-timer.C:
+		return errors.Join(ErrUpgradeFail, ErrNotExistKey,
+			fmt.Errorf("reconnect timeout [%.2f]second", j.config.ReconnectTimeout.Seconds()))
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-j.g.sessionManager.stopChan:
+		return ErrServerClosed
+	}
+	j.update(func(p *UpgradeProgress) {
+		p.Stage = UpgradeSending
+		p.Reconnects++
+	})
+	return nil
+}
+
+// waitResult 等待终端的升级结果(0x0108) 终端升级后一般会重启 重新加入后才上传.
+func (j *UpgradeJob) waitResult(ctx context.Context) {
+	timer := time.NewTimer(j.config.ResultTimeout)
+	defer timer.Stop()
+	select {
+	case notice := <-j.notice:
+		var err error
+		if notice.Result != 0 {
+			err = errors.Join(ErrUpgradeFail, fmt.Errorf("upgrade type=[%d] result=[%d]", notice.UpgradeType, notice.Result))
+		}
+		j.finish(notice, err)
+	case <-timer.C:
+		j.finish(nil, errors.Join(ErrUpgradeFail,
+			fmt.Errorf("result timeout [%.2f]second", j.config.ResultTimeout.Seconds())))
+	case <-ctx.Done():
+		j.finish(nil, ctx.Err())
+	case <-j.g.sessionManager.stopChan:
+		j.finish(nil, ErrServerClosed)
+	}
+}
+
+func (j *UpgradeJob) finish(notice *model.T0x0108, err error) {
+	j.g.upgrades.remove(j)
+	j.update(func(p *UpgradeProgress) {
+		p.Stage = UpgradeSuccess
+		p.Result = notice
+		if err != nil {
+			p.Stage = UpgradeFail
+			p.Err = err
+		}
+	})
+	if err != nil {
+		j.g.opts.Logger.Warn("upgrade fail",
+			slog.String("key", j.key),
+			slog.Any("err", err))
+	}
+	close(j.done)
+}
+
+func (j *UpgradeJob) update(f func(p *UpgradeProgress)) {
+	j.mu.Lock()
+	f(&j.progress)
+	progress := j.progress
+	j.mu.Unlock()
+	if j.config.OnProgress != nil {
+		j.config.OnProgress(progress)
+	}
+}
+
+// onNotice 只保留最新的升级结果.
+func (j *UpgradeJob) onNotice(notice *model.T0x0108) {
+	for {
+		select {
+		case j.notice <- notice:
+			return
+		default:
+		}
+		select {
+		case <-j.notice:
+		default:
+		}
+	}
+}
+
+func (j *UpgradeJob) onReady() {
+	select {
+	case j.ready <- struct{}{}:
+	default:
+	}
+}
+
+func newUpgradeJobs() *upgradeJobs {
+	return &upgradeJobs{record: make(map[string]*UpgradeJob)}
+}
+
+func (u *upgradeJobs) add(job *UpgradeJob) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.record[job.key]; ok {
+		return errors.Join(ErrUpgradeRunning, fmt.Errorf("key=[%s]", job.key))
+	}
+	u.record[job.key] = job
+	return nil
+}
+
+func (u *upgradeJobs) remove(job *UpgradeJob) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.record[job.key] == job {
+		delete(u.record, job.key)
+	}
+}
+
+func (u *upgradeJobs) load(key string) (*UpgradeJob, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	job, ok := u.record[key]
+	return job, ok
+}
+
+// ready 终端加入(设置了鉴权的情况是鉴权成功) 通知等待中的升级任务继续下发.
+func (u *upgradeJobs) ready(key string) {
+	if job, ok := u.load(key); ok {
+		job.onReady()
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"github.com/cuteLittleDevil/go-jt808/protocol/jt808"
+	"github.com/cuteLittleDevil/go-jt808/protocol/model"
+	"github.com/cuteLittleDevil/go-jt808/shared/consts"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upgradeTerminal 模拟终端 平台下发的报文可能一起读到 按照7e拆分.
+type upgradeTerminal struct {
+	t      *testing.T
+	conn   net.Conn
+	header *jt808.Header
+	buf    []byte
+}
+
+func newUpgradeTerminal(t *testing.T, addr string) *upgradeTerminal {
+	t.Helper()
+	jtMsg := jt808.NewJTMessage()
+	data, _ := hex.DecodeString(_heartBeatMsg)
+	_ = jtMsg.Decode(data)
+	return &upgradeTerminal{t: t, conn: dialAndSend(t, addr, _heartBeatMsg), header: jtMsg.Header}
+}
+
+// next 下一个平台下发的0x8108 忽略通用应答.
+func (u *upgradeTerminal) next() *jt808.JTMessage {
+	u.t.Helper()
+	for {
+		for _, frame := range splitFrames(u.buf) {
+			u.buf = u.buf[len(frame):]
+			jtMsg := jt808.NewJTMessage()
+			if err := jtMsg.Decode(frame); err != nil {
+				u.t.Fatalf("decode [%x] err = %v", frame, err)
+			}
+			if jtMsg.Header.ID == uint16(consts.P8108DistributeTerminalUpgradePackage) {
+				return jtMsg
+			}
+		}
+		_ = u.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		data := make([]byte, 2048)
+		n, err := u.conn.Read(data)
+		if err != nil {
+			u.t.Fatal(err)
+		}
+		u.buf = append(u.buf, data[:n]...)
+	}
+}
+
+func (u *upgradeTerminal) write(command consts.JT808CommandType, body []byte) {
+	u.t.Helper()
+	u.header.ReplyID = uint16(command)
+	if _, err := u.conn.Write(u.header.Encode(body)); err != nil {
+		u.t.Fatal(err)
+	}
+}
+
+func (u *upgradeTerminal) ack(jtMsg *jt808.JTMessage) {
+	u.t.Helper()
+	t0x0001 := &model.T0x0001{SerialNumber: jtMsg.Header.SerialNumber, ID: jtMsg.Header.ID}
+	u.write(consts.T0001GeneralRespond, t0x0001.Encode())
+}
+
+func TestGoJT808Upgrade(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 2)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	terminal := newUpgradeTerminal(t, addr)
+	key := <-event.join
+
+	var (
+		mu     sync.Mutex
+		stages []UpgradeStage
+	)
+	p8108 := &model.P0x8108{ManufacturerID: "ABCDE", Version: "V1.2", Package: bytes.Repeat([]byte{0x7e}, 250)}
+	job, err := goJt808.Upgrade(context.Background(), key, p8108, UpgradeConfig{
+		FragmentSize:    100,
+		FragmentTimeout: 200 * time.Millisecond,
+		OnProgress: func(progress UpgradeProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if len(stages) == 0 || stages[len(stages)-1] != progress.Stage {
+				stages = append(stages, progress.Stage)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := goJt808.Upgrade(context.Background(), key, p8108, UpgradeConfig{}); !errors.Is(err, ErrUpgradeRunning) {
+		t.Errorf("Upgrade() error = %v", err)
+	}
+
+	// 分包逐个下发 每个分包应答后才下发下一个
+	first := terminal.next()
+	if h := first.Header; h.SubPackageSum != 3 || h.SubPackageNo != 1 {
+		t.Fatalf("sub package sum=[%d] no=[%d]", h.SubPackageSum, h.SubPackageNo)
+	}
+	terminal.ack(first)
+	// 不应答的重新下发 流水号不变
+	second := terminal.next()
+	if again := terminal.next(); again.Header.SerialNumber != second.Header.SerialNumber || again.Header.SubPackageNo != 2 {
+		t.Fatalf("retransmission seq=[%d] no=[%d]", again.Header.SerialNumber, again.Header.SubPackageNo)
+	}
+	terminal.ack(second)
+	third := terminal.next()
+	if third.Header.SerialNumber != first.Header.SerialNumber+2 {
+		t.Errorf("third seq=[%d] first seq=[%d]", third.Header.SerialNumber, first.Header.SerialNumber)
+	}
+	// 终端请求补传
+	t0x0005 := &model.T0x0005{OriginalSerialNumber: first.Header.SerialNumber, AgainPackageCount: 1, AgainPackageList: []uint16{2}}
+	terminal.write(consts.T0005ReissueSubcontractingRequest, t0x0005.Encode())
+	if again := terminal.next(); !bytes.Equal(again.Body, second.Body) || again.Header.SubPackageNo != 2 {
+		t.Errorf("reissue no=[%d]", again.Header.SubPackageNo)
+	}
+
+	// 断开后重新加入 从没有应答的分包继续下发
+	_ = terminal.conn.Close()
+	deadline := time.Now().Add(3 * time.Second)
+	for job.Progress().Stage != UpgradeReconnecting {
+		if time.Now().After(deadline) {
+			t.Fatalf("progress = %+v", job.Progress())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	terminal = newUpgradeTerminal(t, addr)
+	defer func() {
+		_ = terminal.conn.Close()
+	}()
+	<-event.join
+	resume := terminal.next()
+	if h := resume.Header; h.SubPackageSum != 3 || h.SubPackageNo != 3 || !bytes.Equal(resume.Body, third.Body) {
+		t.Fatalf("resume sum=[%d] no=[%d]", h.SubPackageSum, h.SubPackageNo)
+	}
+	terminal.ack(resume)
+
+	// 终端上传升级结果
+	t0x0108 := &model.T0x0108{UpgradeType: 0, Result: 0}
+	terminal.write(consts.T0108UpgradeNotice, t0x0108.Encode())
+	select {
+	case <-job.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait upgrade timeout progress = %+v", job.Progress())
+	}
+	progress := job.Wait()
+	if progress.Stage != UpgradeSuccess || progress.Err != nil || progress.Acked != 3 ||
+		progress.Retransmissions != 1 || progress.Reconnects != 1 || progress.Result == nil {
+		t.Errorf("progress = %+v", progress)
+	}
+	if _, ok := goJt808.UpgradeJob(key); ok {
+		t.Errorf("UpgradeJob() still running")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []UpgradeStage{UpgradeSending, UpgradeReconnecting, UpgradeSending, UpgradeWaitingResult, UpgradeSuccess}
+	if len(stages) != len(want) {
+		t.Fatalf("stages = %v want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] {
+			t.Errorf("stages = %v want %v", stages, want)
+		}
+	}
+}
+
+func TestGoJT808UpgradeFail(t *testing.T) {
+	goJt808 := New(WithHostPorts(freeAddr(t)))
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	// 终端一直不在线
+	job, err := goJt808.Upgrade(context.Background(), "14419999999", &model.P0x8108{}, UpgradeConfig{
+		ReconnectTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress := job.Wait(); progress.Stage != UpgradeFail || !errors.Is(progress.Err, ErrUpgradeFail) ||
+		!errors.Is(progress.Err, ErrNotExistKey) {
+		t.Errorf("progress = %+v", progress)
+	}
+}
+
+// anyReplyCorrelator 终端的通用应答都关联到等待中的指令 不检查应答流水号.
+type anyReplyCorrelator struct{}
+
+func (anyReplyCorrelator) Correlate(msg *Message, pending map[uint16]ReplyMatcher) (uint16, bool, error) {
+	for seq := range pending {
+		if msg.Command == consts.T0001GeneralRespond {
+			return seq, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func TestGoJT808UpgradeAck(t *testing.T) {
+	addr := freeAddr(t)
+	event := &lifecycleTerminal{join: make(chan string, 1)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithCorrelator(anyReplyCorrelator{}),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	terminal := newUpgradeTerminal(t, addr)
+	defer func() {
+		_ = terminal.conn.Close()
+	}()
+	key := <-event.join
+
+	p8108 := &model.P0x8108{ManufacturerID: "ABCDE", Version: "V1.2", Package: bytes.Repeat([]byte{1}, 50)}
+	job, err := goJt808.Upgrade(context.Background(), key, p8108, UpgradeConfig{
+		FragmentTimeout: time.Second,
+		ResultTimeout:   -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := terminal.next()
+	// 应答解析失败的重新下发
+	terminal.write(consts.T0001GeneralRespond, []byte{0, 0, 0x81})
+	if again := terminal.next(); again.Header.SerialNumber != first.Header.SerialNumber {
+		t.Fatalf("retransmission seq=[%d] want [%d]", again.Header.SerialNumber, first.Header.SerialNumber)
+	}
+	// 应答流水号不一样的重新下发
+	wrong := &model.T0x0001{SerialNumber: first.Header.SerialNumber + 1, ID: first.Header.ID}
+	terminal.write(consts.T0001GeneralRespond, wrong.Encode())
+	again := terminal.next()
+	terminal.ack(again)
+
+	select {
+	case <-job.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait upgrade timeout progress = %+v", job.Progress())
+	}
+	if progress := job.Wait(); progress.Stage != UpgradeSuccess || progress.Acked != 1 || progress.Retransmissions != 2 {
+		t.Errorf("progress = %+v", progress)
+	}
+}
+
+func TestGoJT808UpgradeNoticeAuth(t *testing.T) {
+	addr := freeAddr(t)
+	auth := NewMemoryAuthenticator()
+	_ = auth.AllowTerminals("12345678901")
+	event := &lifecycleTerminal{join: make(chan string, 2)}
+	goJt808 := New(
+		WithHostPorts(addr),
+		WithAuthenticator(auth),
+		WithCustomTerminalEventer(func() TerminalEventer {
+			return event
+		}),
+	)
+	go func() {
+		_ = goJt808.Start(context.Background())
+	}()
+	defer func() {
+		_ = goJt808.Shutdown(context.Background())
+	}()
+	conn := dialAndSend(t, addr, _registerMsg)
+	key := <-event.join
+	jtMsg := readJTMessage(t, conn)
+	p8100 := &model.P0x8100{}
+	if err := p8100.Parse(jtMsg); err != nil {
+		t.Fatal(err)
+	}
+	terminal := &upgradeTerminal{t: t, conn: conn, header: jtMsg.Header}
+	terminal.write(consts.T0102RegisterAuth, []byte(p8100.AuthCode))
+	if p8001 := readP8001(t, conn); p8001.Result != 0 {
+		t.Fatalf("auth result = %d", p8001.Result)
+	}
+
+	p8108 := &model.P0x8108{ManufacturerID: "ABCDE", Version: "V1.2", Package: []byte{1, 2, 3}}
+	job, err := goJt808.Upgrade(context.Background(), key, p8108, UpgradeConfig{ResultTimeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminal.ack(terminal.next())
+	deadline := time.Now().Add(3 * time.Second)
+	for job.Progress().Stage != UpgradeWaitingResult {
+		if time.Now().After(deadline) {
+			t.Fatalf("progress = %+v", job.Progress())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = conn.Close()
+	_ = event.waitLeaveReason(t, 3*time.Second)
+
+	// 未鉴权的连接冒充终端上传升级结果 不影响升级任务
+	header := jtMsg.Header
+	header.ReplyID = uint16(consts.T0108UpgradeNotice)
+	other := dialAndSend(t, addr, hex.EncodeToString(header.Encode((&model.T0x0108{Result: 0}).Encode())))
+	defer func() {
+		_ = other.Close()
+	}()
+	if p8001 := readP8001(t, other); p8001.Result != 1 {
+		t.Errorf("0x0108 result = %d, want 1", p8001.Result)
+	}
+	if progress := job.Progress(); progress.Stage != UpgradeWaitingResult {
+		t.Errorf("progress = %+v", progress)
+	}
+}
@@ -8,6 +8,8 @@ const (
 	T0001GeneralRespond JT808CommandType = 0x0001
 	// T0002HeartBeat 终端-心跳.
 	T0002HeartBeat JT808CommandType = 0x0002
+	// T0005ReissueSubcontractingRequest 终端-补传分包请求.
+	T0005ReissueSubcontractingRequest JT808CommandType = 0x0005
 	// T0100Register 终端-注册.
 	T0100Register JT808CommandType = 0x0100
 	// T0102RegisterAuth 终端-注册鉴权.
@@ -44,6 +46,8 @@ const (
 	T0805CameraShootImmediately JT808CommandType = 0x0805
 	// T0900DataUpTransparentTransmission 终端-数据上行透传.
 	T0900DataUpTransparentTransmission JT808CommandType = 0x0900
+	// T0A00TerminalRSAPublicKey 终端-RSA公钥.
+	T0A00TerminalRSAPublicKey JT808CommandType = 0x0A00
 
 	// P8001GeneralRespond 平台-通用应答.
 	P8001GeneralRespond JT808CommandType = 0x8001
@@ -89,14 +93,20 @@ const (
 	P8500VehicleControl JT808CommandType = 0x8500
 	// P8600SetCircularArea 平台-设置圆形区域.
 	P8600SetCircularArea JT808CommandType = 0x8600
-	// P8601DeleteArea 平台-删除区域.
+	// P8601DeleteArea 平台-删除圆形区域.
 	P8601DeleteArea JT808CommandType = 0x8601
 	// P8602SetRectArea 平台-设置矩形区域.
 	P8602SetRectArea JT808CommandType = 0x8602
+	// P8603DeleteRectArea 平台-删除矩形区域.
+	P8603DeleteRectArea JT808CommandType = 0x8603
 	// P8604PolygonArea 平台-设置多边形区域.
 	P8604PolygonArea JT808CommandType = 0x8604
+	// P8605DeletePolygonArea 平台-删除多边形区域.
+	P8605DeletePolygonArea JT808CommandType = 0x8605
 	// P8606SetRoute 平台-设置路线.
 	P8606SetRoute JT808CommandType = 0x8606
+	// P8607DeleteRoute 平台-删除路线.
+	P8607DeleteRoute JT808CommandType = 0x8607
 	// P8608QueryAreaOrRouteData 平台-查询区域或路线数据.
 	P8608QueryAreaOrRouteData JT808CommandType = 0x8608
 	// P8701DrivingRecordParamDistribution 平台-行驶记录仪参数下发.
@@ -115,6 +125,8 @@ const (
 	P8805SingleMultimediaDataRetrieval JT808CommandType = 0x8805
 	// P8900DataDownTransparentTransmission 平台-数据下行透传.
 	P8900DataDownTransparentTransmission JT808CommandType = 0x8900
+	// P8A00PlatformRSAPublicKey 平台-RSA公钥.
+	P8A00PlatformRSAPublicKey JT808CommandType = 0x8A00
 )
 
 func (j JT808CommandType) String() string {
@@ -123,6 +135,8 @@ func (j JT808CommandType) String() string {
 		return "终端-通用应答"
 	case T0002HeartBeat:
 		return "终端-心跳"
+	case T0005ReissueSubcontractingRequest:
+		return "终端-补传分包请求"
 	case T0100Register:
 		return "终端-注册"
 	case T0102RegisterAuth:
@@ -159,6 +173,8 @@ func (j JT808CommandType) String() string {
 		return "终端-摄像头立即拍照"
 	case T0900DataUpTransparentTransmission:
 		return "终端-数据上行透传"
+	case T0A00TerminalRSAPublicKey:
+		return "终端-RSA公钥"
 	case P8001GeneralRespond:
 		return "平台-通用应答"
 	case P8003ReissueSubcontractingRequest:
@@ -204,13 +220,19 @@ func (j JT808CommandType) String() string {
 	case P8600SetCircularArea:
 		return "平台-设置圆形区域"
 	case P8601DeleteArea:
-		return "平台-删除区域"
+		return "平台-删除圆形区域"
 	case P8602SetRectArea:
 		return "平台-设置矩形区域"
+	case P8603DeleteRectArea:
+		return "平台-删除矩形区域"
 	case P8604PolygonArea:
 		return "平台-设置多边形区域"
+	case P8605DeletePolygonArea:
+		return "平台-删除多边形区域"
 	case P8606SetRoute:
 		return "平台-设置路线"
+	case P8607DeleteRoute:
+		return "平台-删除路线"
 	case P8608QueryAreaOrRouteData:
 		return "平台-查询区域或路线数据"
 	case P8701DrivingRecordParamDistribution:
@@ -229,6 +251,8 @@ func (j JT808CommandType) String() string {
 		return "平台-单条多媒体数据检索"
 	case P8900DataDownTransparentTransmission:
 		return "平台-数据下行透传"
+	case P8A00PlatformRSAPublicKey:
+		return "平台-RSA公钥"
 	}
 
 	switch j {
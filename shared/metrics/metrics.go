@@ -0,0 +1,333 @@
+// Package metrics 简单的指标统计 使用Prometheus的文本格式输出 不依赖第三方库.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDurationBuckets 默认的耗时分布 单位秒.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type (
+	// Registry 指标的集合 实现了http.Handler 输出Prometheus文本格式.
+	// 同一个名称重复注册的返回之前的 多个服务可以共用一个Registry.
+	Registry struct {
+		mu         sync.Mutex
+		collectors map[string]collector
+	}
+
+	collector interface {
+		write(w *bufio.Writer)
+	}
+
+	// desc 指标的描述.
+	desc struct {
+		name   string
+		help   string
+		kind   string
+		labels []string
+	}
+
+	// CounterVec 只增加的计数 按照标签值区分.
+	CounterVec struct {
+		desc
+		mu     sync.Mutex
+		values map[string]*counterValue
+	}
+
+	counterValue struct {
+		labelValues []string
+		value       float64
+	}
+
+	// GaugeFunc 当前值 输出的时候调用fs获取 多个的时候求和.
+	GaugeFunc struct {
+		desc
+		mu sync.Mutex
+		fs []func() float64
+	}
+
+	// HistogramVec 数值的分布 按照标签值区分.
+	HistogramVec struct {
+		desc
+		buckets []float64
+		mu      sync.Mutex
+		values  map[string]*histogramValue
+	}
+
+	histogramValue struct {
+		labelValues []string
+		counts      []uint64
+		sum         float64
+		count       uint64
+	}
+)
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// NewCounter 注册计数 labels是标签名称.
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.collectors[name].(*CounterVec); ok {
+		return v
+	}
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]*counterValue),
+	}
+	r.collectors[name] = c
+	return c
+}
+
+// NewGaugeFunc 注册当前值 输出的时候调用f获取.
+// 同一个名称重复注册的 f加入之前的 输出的时候求和 比如多个服务的在线数量.
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) *GaugeFunc {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.collectors[name].(*GaugeFunc); ok {
+		v.mu.Lock()
+		v.fs = append(v.fs, f)
+		v.mu.Unlock()
+		return v
+	}
+	g := &GaugeFunc{
+		desc: desc{name: name, help: help, kind: "gauge"},
+		fs:   []func() float64{f},
+	}
+	r.collectors[name] = g
+	return g
+}
+
+// NewHistogram 注册数值分布 buckets为空的使用DefaultDurationBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.collectors[name].(*HistogramVec); ok {
+		return v
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	r.collectors[name] = h
+	return h
+}
+
+// WriteTo 按照名称排序输出全部指标.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// Inc 标签值的数量和顺序要和注册的标签名称一致.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if c == nil {
+		return
+	}
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		value = &counterValue{labelValues: slices.Clone(labelValues)}
+		c.values[key] = value
+	}
+	value.value += v
+}
+
+// Value 标签值对应的当前计数.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return v.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHead(w)
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		writeSample(w, c.name, c.labelPairs(v.labelValues, "", ""), v.value)
+	}
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mu.Lock()
+	fs := slices.Clone(g.fs)
+	g.mu.Unlock()
+	sum := 0.0
+	for _, f := range fs {
+		sum += f()
+	}
+	g.writeHead(w)
+	writeSample(w, g.name, "", sum)
+}
+
+// Observe 标签值的数量和顺序要和注册的标签名称一致.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{
+			labelValues: slices.Clone(labelValues),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = value
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.sum += v
+	value.count++
+}
+
+// Count 标签值对应的观察次数.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if v, ok := h.values[strings.Join(labelValues, "\xff")]; ok {
+		return v.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHead(w)
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		for i, upper := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labelPairs(v.labelValues, "le", formatFloat(upper)), float64(v.counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labelPairs(v.labelValues, "le", "+Inf"), float64(v.count))
+		writeSample(w, h.name+"_sum", h.labelPairs(v.labelValues, "", ""), v.sum)
+		writeSample(w, h.name+"_count", h.labelPairs(v.labelValues, "", ""), float64(v.count))
+	}
+}
+
+func (d *desc) writeHead(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", d.name, escape(d.help, false))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.kind)
+}
+
+// labelPairs 生成{a="1",b="2"} extraName不为空的时候追加在最后.
+func (d *desc) labelPairs(labelValues []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, name := range d.labels {
+		value := ""
+		if i < len(labelValues) {
+			value = labelValues[i]
+		}
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escape(value, true)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	_, _ = w.WriteString(name + labels + " " + formatFloat(value) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape 帮助信息转义\和换行 标签值还需要转义".
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	messages := r.NewCounter("jt808_messages_total", "消息数量", "direction", "command")
+	messages.Inc("in", "0x0002")
+	messages.Add(2, "in", "0x0002")
+	messages.Inc("out", `a"b`)
+	if r.NewCounter("jt808_messages_total", "重复注册") != messages {
+		t.Errorf("NewCounter() not return registered")
+	}
+	sessions := r.NewGaugeFunc("jt808_sessions", "在线会话", func() float64 { return 1 })
+	if r.NewGaugeFunc("jt808_sessions", "重复注册", func() float64 { return 2 }) != sessions {
+		t.Errorf("NewGaugeFunc() not return registered")
+	}
+	duration := r.NewHistogram("jt808_duration_seconds", "耗时", []float64{1, 0.1}, "command")
+	duration.Observe(0.05, "0x8104")
+	duration.Observe(0.5, "0x8104")
+	duration.Observe(5, "0x8104")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	data, _ := io.ReadAll(rec.Body)
+	want := strings.Join([]string{
+		"# HELP jt808_duration_seconds 耗时",
+		"# TYPE jt808_duration_seconds histogram",
+		`jt808_duration_seconds_bucket{command="0x8104",le="0.1"} 1`,
+		`jt808_duration_seconds_bucket{command="0x8104",le="1"} 2`,
+		`jt808_duration_seconds_bucket{command="0x8104",le="+Inf"} 3`,
+		`jt808_duration_seconds_sum{command="0x8104"} 5.55`,
+		`jt808_duration_seconds_count{command="0x8104"} 3`,
+		"# HELP jt808_messages_total 消息数量",
+		"# TYPE jt808_messages_total counter",
+		`jt808_messages_total{direction="in",command="0x0002"} 3`,
+		`jt808_messages_total{direction="out",command="a\"b"} 1`,
+		"# HELP jt808_sessions 在线会话",
+		"# TYPE jt808_sessions gauge",
+		"jt808_sessions 3",
+		"",
+	}, "\n")
+	if string(data) != want {
+		t.Errorf("ServeHTTP() = \n%s\nwant\n%s", data, want)
+	}
+	if v := messages.Value("in", "0x0002"); v != 3 {
+		t.Errorf("Value() = %v", v)
+	}
+	if v := duration.Count("0x8104"); v != 3 {
+		t.Errorf("Count() = %v", v)
+	}
+}
@@ -17,6 +17,8 @@ type Option struct {
 type Options struct {
 	Header                   *jt808.Header
 	CustomProtocolHandleFunc func() map[consts.JT808CommandType]Handler
+	// Logger 终端的日志 默认slog.Default()
+	Logger *slog.Logger
 }
 
 func newOptions(opts []Option) *Options {
@@ -24,9 +26,18 @@ func newOptions(opts []Option) *Options {
 	for _, op := range opts {
 		op.F(options)
 	}
+	options.Logger = options.logger()
 	return options
 }
 
+// logger 还没有设置日志的时候使用slog.Default().
+func (o *Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
+
 // WithCustomHeader 设置自定义header.
 func WithCustomHeader(header *jt808.Header) Option {
 	return Option{F: func(o *Options) {
@@ -61,7 +72,7 @@ func WithHeader(protocolVersion consts.ProtocolVersionType, phone string) Option
 		var jtMsg *jt808.JTMessage
 		jtMsg = jt808.NewJTMessage()
 		if err := jtMsg.Decode(data); err != nil {
-			slog.Error("decode",
+			o.logger().Error("decode",
 				slog.String("phone", phone),
 				slog.Any("err", err))
 		}
@@ -78,3 +89,10 @@ func WithCustomProtocolHandleFunc(customFunc func() map[consts.JT808CommandType]
 		o.CustomProtocolHandleFunc = customFunc
 	}}
 }
+
+// WithLogger 设置日志 默认slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return Option{F: func(o *Options) {
+		o.Logger = logger
+	}}
+}
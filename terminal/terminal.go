@@ -13,6 +13,7 @@ type Terminal struct {
 	TerminalPhoneNo string
 	header          *jt808.Header
 	protocolHandles map[consts.JT808CommandType]Handler
+	logger          *slog.Logger
 }
 
 func New(opts ...Option) *Terminal {
@@ -38,6 +39,7 @@ func New(opts ...Option) *Terminal {
 		TerminalPhoneNo: header.TerminalPhoneNo,
 		header:          header,
 		protocolHandles: protocolHandles,
+		logger:          options.Logger,
 	}
 }
 
@@ -49,7 +51,8 @@ func (t *Terminal) CreateDefaultCommandData(commandType consts.JT808CommandType)
 		t.header.PlatformSerialNumber++
 		return t.header.Encode(body)
 	}
-	slog.Warn("not found command",
+	t.logger.Warn("not found command",
+		slog.String("phone", t.TerminalPhoneNo),
 		slog.String("command", commandType.String()))
 	return nil
 }
@@ -66,7 +69,7 @@ func (t *Terminal) ExpectedReply(seq uint16, msg string) []byte {
 	jtMsg := jt808.NewJTMessage()
 	data, _ := hex.DecodeString(msg)
 	if err := jtMsg.Decode(data); err != nil {
-		slog.Warn("decode fail",
+		t.logger.Warn("decode fail",
 			slog.String("msg", msg),
 			slog.Any("err", err))
 		return nil
@@ -87,7 +90,7 @@ func (t *Terminal) ProtocolDetails(msg string) string {
 	jtMsg := jt808.NewJTMessage()
 	data, _ := hex.DecodeString(msg)
 	if err := jtMsg.Decode(data); err != nil {
-		slog.Warn("decode fail",
+		t.logger.Warn("decode fail",
 			slog.String("msg", msg),
 			slog.Any("err", err))
 		return ""
@@ -95,8 +98,12 @@ func (t *Terminal) ProtocolDetails(msg string) string {
 	commandType := consts.JT808CommandType(jtMsg.Header.ID)
 	if v, ok := t.protocolHandles[commandType]; ok {
 		if err := v.Parse(jtMsg); err != nil {
-			slog.Warn("parse fail",
-				slog.String("msg", msg))
+			t.logger.Warn("parse fail",
+				slog.String("phone", jtMsg.Header.TerminalPhoneNo),
+				slog.String("command", commandType.String()),
+				slog.Any("seq", jtMsg.Header.SerialNumber),
+				slog.String("msg", msg),
+				slog.Any("err", err))
 			return ""
 		}
 		return strings.Join([]string{
@@ -107,7 +114,10 @@ func (t *Terminal) ProtocolDetails(msg string) string {
 			"[7e]结束: 126",
 		}, "\n")
 	}
-	slog.Warn("not parse msg",
+	t.logger.Warn("not parse msg",
+		slog.String("phone", jtMsg.Header.TerminalPhoneNo),
+		slog.String("command", commandType.String()),
+		slog.Any("seq", jtMsg.Header.SerialNumber),
 		slog.String("msg", msg))
 	return ""
 }